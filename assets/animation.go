@@ -1,62 +1,297 @@
 package assets
 
 import (
+	"encoding/json"
 	"fmt"
+	"image"
 	"io/fs"
 	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 var Bundles map[string]Bundle
 
+// Cosmetics are the layers drawn over the gopher, by "slot/name". Each
+// cosmetic has the same animations of the gopher bundle, with a layer
+// for each frame.
+var Cosmetics map[string]Bundle
+
 type Bundle map[string]*Animation
 
-func init() {
+// loadEmbedded loads the animations and the cosmetics embedded in the
+// binary, packing their frames in the atlas.
+func loadEmbedded(at *atlas) {
 	Bundles = make(map[string]Bundle)
-	Bundles["gopher"] = MustLoadAnimation("gopher")
+	dirs, err := fs.ReadDir(Assets, "animations")
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		bundle, err := loadBundle(at, "animations/"+d.Name(), true)
+		if err != nil {
+			panic(err)
+		}
+		Bundles[d.Name()] = bundle
+	}
+
+	Cosmetics = make(map[string]Bundle)
+	cosmetics, err := fs.Glob(Assets, "cosmetics/*/*")
+	if err != nil {
+		panic(err)
+	}
+	for _, c := range cosmetics {
+		bundle, err := loadBundle(at, c, false)
+		if err != nil {
+			panic(err)
+		}
+		Cosmetics[strings.TrimPrefix(c, "cosmetics/")] = bundle
+	}
 }
 
 type Animation struct {
 	Frames []*ebiten.Image
 	Skins  []*ebiten.Image
+
+	Mode PlayMode
+
+	// Durations is how long each frame is shown. Animations without
+	// durations use DefaultFrameDuration.
+	Durations []time.Duration
+
+	Events []FrameEvent
+
+	// Anchor is the point of the frame placed at the feet of the
+	// character, by default the bottom center.
+	Anchor image.Point
+
+	// Hitbox is the solid area of the character, in frame coordinates.
+	// It is empty to use the default hitbox.
+	Hitbox image.Rectangle
+
+	// FrameFiles and SkinFiles are the paths of the images in Assets, for
+	// the web renderer. They are empty for asset packs.
+	FrameFiles []string
+	SkinFiles  []string
+}
+
+// DefaultFrameDuration is how long the frames of animations without
+// durations are shown.
+var DefaultFrameDuration = 100 * time.Millisecond
+
+// PlayMode is how an animation goes on after its last frame.
+type PlayMode int
+
+const (
+	PlayLoop PlayMode = iota
+	// PlayOnce stops on the last frame.
+	PlayOnce
+	// PlayPingPong plays the frames forth and back.
+	PlayPingPong
+)
+
+var playModes = map[string]PlayMode{"loop": PlayLoop, "once": PlayOnce, "pingpong": PlayPingPong}
+
+func (m *PlayMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	mode, ok := playModes[s]
+	if !ok {
+		return fmt.Errorf("unknown mode %q, expected loop, once or pingpong", s)
+	}
+	*m = mode
+	return nil
+}
+
+// FrameEvent is a named event of an animation frame, like the "hit" of an
+// attack, dispatched when the frame is shown.
+type FrameEvent struct {
+	Frame int    `json:"frame"`
+	Name  string `json:"name"`
+}
+
+// FrameDuration returns how long the frame is shown.
+func (a *Animation) FrameDuration(frame int) time.Duration {
+	if frame < len(a.Durations) {
+		return a.Durations[frame]
+	}
+	return DefaultFrameDuration
+}
+
+// Duration returns how long a cycle of the animation plays.
+func (a *Animation) Duration() (d time.Duration) {
+	for i := range a.Frames {
+		d += a.FrameDuration(i)
+	}
+	return d
+}
+
+// EventsAt returns the names of the events of the frame.
+func (a *Animation) EventsAt(frame int) (names []string) {
+	for _, e := range a.Events {
+		if e.Frame == frame {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// Next returns the frame shown after the frame, and whether the animation
+// finished: one-shot animations finish after the last frame, and the
+// others at the end of each cycle. Backwards is the direction of
+// ping-pong animations.
+func (a *Animation) Next(frame int, backwards bool) (next int, nextBackwards, finished bool) {
+	last := len(a.Frames) - 1
+	switch {
+	case a.Mode == PlayOnce:
+		if frame >= last {
+			return last, false, true
+		}
+	case a.Mode == PlayPingPong && last > 0:
+		if backwards {
+			return frame - 1, frame > 1, frame <= 1
+		}
+		if frame >= last {
+			return last - 1, last > 1, last <= 1
+		}
+	case frame >= last:
+		return 0, false, true
+	}
+	return frame + 1, false, false
+}
+
+// AnimationConfig is read from the optional animation.json file in the
+// animation directory, and from the animations of pack manifests.
+type AnimationConfig struct {
+	// Loop false is the same as the mode "once".
+	Loop *bool     `json:"loop"`
+	Mode *PlayMode `json:"mode"`
+
+	// Durations are in milliseconds, one for each frame or a single one
+	// for all of them.
+	Durations []int `json:"durations"`
+
+	Events []FrameEvent `json:"events"`
+}
+
+// configure validates the config and applies it to the animation.
+func (a *Animation) configure(cfg AnimationConfig) error {
+	if cfg.Loop != nil && !*cfg.Loop {
+		a.Mode = PlayOnce
+	}
+	if cfg.Mode != nil {
+		a.Mode = *cfg.Mode
+	}
+	switch len(cfg.Durations) {
+	case 0:
+	case 1, len(a.Frames):
+		a.Durations = nil
+		for i := range a.Frames {
+			ms := cfg.Durations[min(i, len(cfg.Durations)-1)]
+			if ms <= 0 {
+				return fmt.Errorf("duration of frame %d must be positive, got %d", i, ms)
+			}
+			a.Durations = append(a.Durations, time.Duration(ms)*time.Millisecond)
+		}
+	default:
+		return fmt.Errorf("%d durations for %d frames", len(cfg.Durations), len(a.Frames))
+	}
+	for _, e := range cfg.Events {
+		if e.Name == "" || e.Frame < 0 || e.Frame >= len(a.Frames) {
+			return fmt.Errorf("invalid event %q on frame %d of %d", e.Name, e.Frame, len(a.Frames))
+		}
+	}
+	a.Events = cfg.Events
+	return nil
 }
 
 func LoadAnimation(path string) (bundle Bundle, err error) {
+	at := &atlas{}
+	if bundle, err = loadBundle(at, "animations/"+path, true); err != nil {
+		return nil, err
+	}
+	at.build()
+	return bundle, nil
+}
+
+// loadBundle loads the animations in the subdirectories of dir, adding
+// their images to the atlas. Tinted frames are loaded in grayscale, to be
+// colored when drawn.
+func loadBundle(at *atlas, dir string, tinted bool) (bundle Bundle, err error) {
 	bundle = make(map[string]*Animation)
-	frames, err := fs.Glob(Assets, "animations/"+path+"/*/frame*.png")
+	frames, err := fs.Glob(Assets, dir+"/*/frame*.png")
 	if err != nil {
 		return nil, err
 	}
-	skins, err := fs.Glob(Assets, "animations/"+path+"/*/skin*.png")
+	skins, err := fs.Glob(Assets, dir+"/*/skin*.png")
 	if err != nil {
 		return nil, err
 	}
+	frameImgs := make(map[string][]image.Image)
+	skinImgs := make(map[string][]image.Image)
+	frameFiles := make(map[string][]string)
+	skinFiles := make(map[string][]string)
 	matches := []string{}
 	matches = append(matches, frames...)
 	matches = append(matches, skins...)
 	for _, f := range matches {
-		pathParts := strings.Split(f, "/")
-		if len(pathParts) != 4 {
+		pathParts := strings.Split(strings.TrimPrefix(f, dir+"/"), "/")
+		if len(pathParts) != 2 {
 			return nil, fmt.Errorf("animation: invalid path parts: %#v", pathParts)
 		}
-		anim, fileName := pathParts[2], pathParts[3]
-		a, ok := bundle[anim]
-		if !ok {
-			a = &Animation{}
-		}
+		anim, fileName := pathParts[0], pathParts[1]
 		if strings.HasPrefix(fileName, "frame") {
-			img := LoadEbitenImg(f, true)
-			a.Frames = append(a.Frames, img)
+			frameImgs[anim] = append(frameImgs[anim], LoadImg(f, tinted))
+			frameFiles[anim] = append(frameFiles[anim], f)
 		} else {
-			img := LoadEbitenImg(f, false)
-			a.Skins = append(a.Skins, img)
+			skinImgs[anim] = append(skinImgs[anim], LoadImg(f, false))
+			skinFiles[anim] = append(skinFiles[anim], f)
+		}
+	}
+	for _, anim := range sortedKeys(frameImgs) {
+		a := &Animation{
+			Anchor:     defaultAnchor(frameImgs[anim][0]),
+			FrameFiles: frameFiles[anim],
+			SkinFiles:  skinFiles[anim],
 		}
+		at.addAnimation(a, frameImgs[anim], skinImgs[anim])
 		bundle[anim] = a
 	}
+	configs, err := fs.Glob(Assets, dir+"/*/animation.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range configs {
+		a, ok := bundle[strings.Split(strings.TrimPrefix(f, dir+"/"), "/")[0]]
+		if !ok {
+			continue
+		}
+		var cfg AnimationConfig
+		b, err := fs.ReadFile(Assets, f)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return nil, fmt.Errorf("animation: invalid config %v: %v", f, err)
+		}
+		if err := a.configure(cfg); err != nil {
+			return nil, fmt.Errorf("animation: %v: %v", f, err)
+		}
+	}
 	return bundle, nil
 }
 
+func defaultAnchor(frame image.Image) image.Point {
+	b := frame.Bounds()
+	return image.Pt(b.Dx()/2, b.Dy())
+}
+
 func MustLoadAnimation(path string) Bundle {
 	bundle, err := LoadAnimation(path)
 	if err != nil {
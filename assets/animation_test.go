@@ -2,7 +2,12 @@ package assets
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
 )
 
 type loadAnimTestCase struct {
@@ -11,21 +16,53 @@ type loadAnimTestCase struct {
 	animationCount int
 	frameCount     map[string]int
 	skinsCount     map[string]int
+	oneShot        []string
 }
 
 var loadAnimTestCases = []loadAnimTestCase{
 	{
 		path: "gopher",
 
-		animationCount: 3,
+		animationCount: 11,
 		frameCount: map[string]int{
-			"standing":      5,
-			"walking_left":  8,
-			"walking_right": 8,
+			"attack":         6,
+			"dance":          8,
+			"fight_standing": 6,
+			"highfive":       6,
+			"hug":            6,
+			"sit":            4,
+			"sleep":          6,
+			"standing":       5,
+			"walking_left":   8,
+			"walking_right":  8,
+			"wave":           8,
 		},
 		skinsCount: map[string]int{
-			"standing": 5,
+			"attack":         6,
+			"dance":          8,
+			"fight_standing": 6,
+			"highfive":       6,
+			"hug":            6,
+			"sit":            4,
+			"sleep":          6,
+			"standing":       5,
+			"wave":           8,
 		},
+		oneShot: []string{"attack", "dance", "highfive", "hug", "sit", "sleep", "wave"},
+	},
+	{
+		path: "hadouken",
+
+		animationCount: 1,
+		frameCount:     map[string]int{"flying": 4},
+		skinsCount:     map[string]int{"flying": 4},
+	},
+	{
+		path: "spear",
+
+		animationCount: 1,
+		frameCount:     map[string]int{"flying": 2},
+		skinsCount:     map[string]int{"flying": 2},
 	},
 }
 
@@ -70,6 +107,11 @@ func TestLoadAnimation(t *testing.T) {
 					}
 				}
 			}
+			for anim, a := range bundle {
+				if oneShot := slices.Contains(tc.oneShot, anim); (a.Mode == PlayOnce) != oneShot {
+					t.Errorf("%v: unexpected mode: %v", anim, a.Mode)
+				}
+			}
 			// extra map keys were loaded
 			for anim := range bundle {
 				if _, ok := tc.frameCount[anim]; !ok {
@@ -79,3 +121,78 @@ func TestLoadAnimation(t *testing.T) {
 		})
 	}
 }
+
+func TestAnimationConfig(t *testing.T) {
+	a := Bundles["gopher"]["attack"]
+	if slices.Compare(a.EventsAt(3), []string{"hit"}) != 0 || len(a.EventsAt(2)) != 0 {
+		t.Errorf("expected a hit on frame 3, got %v", a.Events)
+	}
+	if a.FrameDuration(1) != 160*time.Millisecond {
+		t.Errorf("unexpected duration: %v", a.FrameDuration(1))
+	}
+	if d := Bundles["gopher"]["standing"].FrameDuration(0); d != DefaultFrameDuration {
+		t.Errorf("expected the default duration, got %v", d)
+	}
+	if len(a.FrameFiles) != len(a.Frames) || a.FrameFiles[3] != "animations/gopher/attack/frame_0003.png" {
+		t.Errorf("unexpected frame files: %v", a.FrameFiles)
+	}
+}
+
+func TestNext(t *testing.T) {
+	for tn, tc := range []struct {
+		mode   PlayMode
+		frames int
+		// expect is the sequence of frames from the first one, and "|"
+		// when the animation finishes
+		expect string
+	}{
+		{mode: PlayLoop, frames: 3, expect: "0 1 2 | 0 1 2 | 0"},
+		{mode: PlayOnce, frames: 3, expect: "0 1 2 | 2 | 2 | 2"},
+		{mode: PlayPingPong, frames: 4, expect: "0 1 2 3 2 1 | 0 1"},
+		{mode: PlayPingPong, frames: 2, expect: "0 1 | 0 1 | 0 1"},
+		{mode: PlayPingPong, frames: 1, expect: "0 | 0 | 0 | 0"},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			a := &Animation{Mode: tc.mode, Frames: make([]*ebiten.Image, tc.frames)}
+			frame, backwards := 0, false
+			got := []string{"0"}
+			for len(got) < len(strings.Fields(tc.expect)) {
+				var finished bool
+				frame, backwards, finished = a.Next(frame, backwards)
+				if finished {
+					got = append(got, "|")
+				}
+				got = append(got, fmt.Sprint(frame))
+			}
+			if s := strings.Join(got, " "); !strings.HasPrefix(s, tc.expect) {
+				t.Errorf("expected %q, got %q", tc.expect, s)
+			}
+		})
+	}
+}
+
+func TestBundles(t *testing.T) {
+	for _, name := range []string{"gopher", "hadouken", "shoryuken", "kamehameha", "spear"} {
+		if _, ok := Bundles[name]; !ok {
+			t.Errorf("missing bundle: %v", name)
+		}
+	}
+}
+
+func TestCosmetics(t *testing.T) {
+	gopher := Bundles["gopher"]
+	for _, name := range []string{"hat/mario", "outfit/sonic", "eyes/angry", "accessory/ninja"} {
+		c, ok := Cosmetics[name]
+		if !ok {
+			t.Errorf("missing cosmetic: %v", name)
+			continue
+		}
+		// Layers are aligned with the gopher frames
+		for anim, a := range c {
+			if len(a.Frames) != len(gopher[anim].Frames) {
+				t.Errorf("%v: %v: expected %d layers, got %d",
+					name, anim, len(gopher[anim].Frames), len(a.Frames))
+			}
+		}
+	}
+}
@@ -5,13 +5,14 @@ import (
 	"image"
 	"image/color"
 	_ "image/png"
+	"io/fs"
 
 	"github.com/codigolandia/live-quest/log"
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 var (
-	//go:embed img animations web
+	//go:embed img animations cosmetics web
 	Assets embed.FS
 )
 
@@ -22,39 +23,66 @@ var (
 
 	YoutubeIcon *ebiten.Image
 	TwitchIcon  *ebiten.Image
+
+	// Pixel is a white pixel, scaled and tinted to draw rectangles.
+	Pixel *ebiten.Image
 )
 
 func init() {
-	BarBG = LoadEbitenImg("img/hp_bar_bg.png", false)
-	HPBarFG = LoadEbitenImg("img/hp_bar_fg.png", false)
-	XPBarFG = LoadEbitenImg("img/xp_bar_fg.png", false)
+	// All the embedded images share a few textures
+	at := &atlas{}
+	at.add(LoadImg("img/hp_bar_bg.png", false), &BarBG)
+	at.add(LoadImg("img/hp_bar_fg.png", false), &HPBarFG)
+	at.add(LoadImg("img/xp_bar_fg.png", false), &XPBarFG)
+
+	at.add(LoadImg("img/youtube_icon.png", false), &YoutubeIcon)
+	at.add(LoadImg("img/twitch_icon.png", false), &TwitchIcon)
 
-	YoutubeIcon = LoadEbitenImg("img/youtube_icon.png", false)
-	TwitchIcon = LoadEbitenImg("img/twitch_icon.png", false)
+	pixel := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	pixel.Set(0, 0, color.White)
+	at.add(pixel, &Pixel)
+
+	loadEmbedded(at)
+	at.build()
+	log.D("assets: embedded images packed in %d textures", Textures)
 }
 func LoadImg(path string, asGrayScale bool) image.Image {
-	r, _ := Assets.Open(path)
-	img, _, err := image.Decode(r)
+	img, err := decodeImg(Assets, path)
 	if err != nil {
 		log.E("image not found: %v", err)
 	}
 
 	if asGrayScale {
-		gray := image.NewRGBA(img.Bounds())
-		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
-			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-				r, g, b, a := img.At(x, y).RGBA()
-				clr := uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
-				rgba := color.RGBA{R: clr, G: clr, B: clr, A: uint8(a)}
-				gray.Set(x, y, rgba)
-			}
-		}
-		return gray
+		return grayScale(img)
 	}
 
 	return img
 }
 
+func decodeImg(fsys fs.FS, path string) (image.Image, error) {
+	r, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// grayScale converts the image, so it can be tinted when drawn.
+func grayScale(img image.Image) image.Image {
+	gray := image.NewRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			clr := uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+			rgba := color.RGBA{R: clr, G: clr, B: clr, A: uint8(a)}
+			gray.Set(x, y, rgba)
+		}
+	}
+	return gray
+}
+
 func LoadEbitenImg(path string, asGrayScale bool) *ebiten.Image {
 	return ebiten.NewImageFromImage(LoadImg(path, asGrayScale))
 }
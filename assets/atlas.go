@@ -0,0 +1,114 @@
+package assets
+
+import (
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// AtlasSize is the maximum width and height of the atlas textures.
+// Larger images get a texture of their own.
+var AtlasSize = 2048
+
+// atlasPadding is the space between the images, so they do not bleed into
+// each other when filtered.
+const atlasPadding = 1
+
+// Textures is the number of atlas textures loaded, to check how well the
+// draw calls can be batched.
+var Textures int
+
+// atlas packs images in a few large textures, so the GPU draws the ones
+// that share a texture in a single batch.
+type atlas struct {
+	images []image.Image
+	slots  []**ebiten.Image
+}
+
+// add queues the image, to be stored in the slot when the atlas is built.
+func (at *atlas) add(img image.Image, slot **ebiten.Image) {
+	at.images = append(at.images, img)
+	at.slots = append(at.slots, slot)
+}
+
+// addAnimation queues the frames and the skins of the animation.
+func (at *atlas) addAnimation(a *Animation, frames, skins []image.Image) {
+	a.Frames = make([]*ebiten.Image, len(frames))
+	for i, img := range frames {
+		at.add(img, &a.Frames[i])
+	}
+	a.Skins = make([]*ebiten.Image, len(skins))
+	for i, img := range skins {
+		at.add(img, &a.Skins[i])
+	}
+}
+
+// build packs the images, pointing each slot to its sub-image.
+func (at *atlas) build() {
+	sizes := make([]image.Point, len(at.images))
+	for i, img := range at.images {
+		sizes[i] = img.Bounds().Size()
+	}
+	placements, pages := packShelves(sizes, AtlasSize)
+	canvases := make([]*image.RGBA, len(pages))
+	for i, size := range pages {
+		canvases[i] = image.NewRGBA(image.Rectangle{Max: size})
+	}
+	for i, p := range placements {
+		img := at.images[i]
+		draw.Draw(canvases[p.Page], p.Rect, img, img.Bounds().Min, draw.Src)
+	}
+	textures := make([]*ebiten.Image, len(pages))
+	for i, c := range canvases {
+		textures[i] = ebiten.NewImageFromImage(c)
+	}
+	for i, p := range placements {
+		*at.slots[i] = textures[p.Page].SubImage(p.Rect).(*ebiten.Image)
+	}
+	Textures += len(textures)
+	at.images, at.slots = nil, nil
+}
+
+type atlasPlacement struct {
+	Page int
+	Rect image.Rectangle
+}
+
+// packShelves places rectangles of the sizes in pages of at most size x
+// size pixels, in shelves of similar heights. Larger rectangles get a
+// page of their own. It returns where each rectangle goes, and the size
+// of each page.
+func packShelves(sizes []image.Point, size int) (placements []atlasPlacement, pages []image.Point) {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return sizes[order[i]].Y > sizes[order[j]].Y
+	})
+
+	placements = make([]atlasPlacement, len(sizes))
+	page, x, y, shelf := -1, 0, 0, 0
+	for _, i := range order {
+		s := sizes[i]
+		if s.X+atlasPadding > size || s.Y+atlasPadding > size {
+			pages = append(pages, s)
+			placements[i] = atlasPlacement{len(pages) - 1, image.Rectangle{Max: s}}
+			continue
+		}
+		w, h := s.X+atlasPadding, s.Y+atlasPadding
+		if page >= 0 && x+w > size {
+			x, y, shelf = 0, y+shelf, 0
+		}
+		if page < 0 || y+h > size {
+			pages = append(pages, image.Point{})
+			page, x, y, shelf = len(pages)-1, 0, 0, 0
+		}
+		placements[i] = atlasPlacement{page, image.Rect(x, y, x+s.X, y+s.Y)}
+		x, shelf = x+w, max(shelf, h)
+		pages[page] = image.Pt(max(pages[page].X, x), max(pages[page].Y, y+h))
+	}
+	return placements, pages
+}
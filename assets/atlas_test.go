@@ -0,0 +1,41 @@
+package assets
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+func TestPackShelves(t *testing.T) {
+	for tn, tc := range []struct {
+		sizes []image.Point
+		size  int
+		pages int
+	}{
+		{sizes: []image.Point{{128, 128}, {128, 128}, {128, 128}}, size: 512, pages: 1},
+		{sizes: []image.Point{{128, 128}, {128, 128}, {128, 128}, {128, 128}}, size: 258, pages: 1},
+		{sizes: []image.Point{{128, 128}, {128, 128}, {128, 128}, {128, 128}, {2, 2}}, size: 258, pages: 2},
+		{sizes: []image.Point{{16, 8}, {300, 20}, {16, 16}, {1, 1}, {64, 2}}, size: 256, pages: 2},
+		{sizes: []image.Point{{64, 96}, {100, 14}, {20, 20}, {1, 1}, {64, 96}, {64, 96}}, size: 128, pages: 3},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			placements, pages := packShelves(tc.sizes, tc.size)
+			if len(pages) != tc.pages {
+				t.Errorf("expected %d pages, got %v", tc.pages, pages)
+			}
+			for i, p := range placements {
+				if p.Rect.Size() != tc.sizes[i] {
+					t.Errorf("#%d: expected the size %v, got %v", i, tc.sizes[i], p.Rect)
+				}
+				if !p.Rect.In(image.Rectangle{Max: pages[p.Page]}) {
+					t.Errorf("#%d: %v is outside of the page %v", i, p.Rect, pages[p.Page])
+				}
+				for j, other := range placements[:i] {
+					if other.Page == p.Page && other.Rect.Overlaps(p.Rect) {
+						t.Errorf("#%d overlaps #%d: %v and %v", i, j, p.Rect, other.Rect)
+					}
+				}
+			}
+		})
+	}
+}
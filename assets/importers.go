@@ -0,0 +1,262 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportedAnimation is an animation decoded from a single file, like a
+// GIF or an Aseprite export, before its frames are uploaded to the GPU.
+type ImportedAnimation struct {
+	Frames []image.Image
+
+	// Durations are empty when the file has no timing, like spritesheets.
+	Durations []time.Duration
+
+	Loop bool
+}
+
+// SliceSheet cuts a spritesheet into frames of w x h pixels, from left to
+// right and top to bottom; horizontal strips are grids with a single row.
+// Count is the number of frames of grids with empty cells at the end, and
+// zero uses all the cells.
+func SliceSheet(sheet image.Image, w, h, count int) ([]image.Image, error) {
+	b := sheet.Bounds()
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", w, h)
+	}
+	if b.Dx()%w != 0 || b.Dy()%h != 0 {
+		return nil, fmt.Errorf("sheet size %v is not a multiple of the frame size %dx%d", b.Size(), w, h)
+	}
+	cols, cells := b.Dx()/w, (b.Dx()/w)*(b.Dy()/h)
+	if count == 0 {
+		count = cells
+	}
+	if count < 0 || count > cells {
+		return nil, fmt.Errorf("%d frames in a sheet with %d cells", count, cells)
+	}
+	frames := make([]image.Image, 0, count)
+	for i := range count {
+		at := b.Min.Add(image.Pt(i%cols*w, i/cols*h))
+		frames = append(frames, crop(sheet, image.Rectangle{at, at.Add(image.Pt(w, h))}))
+	}
+	return frames, nil
+}
+
+// crop copies the rectangle of the image to a new image at the origin.
+func crop(img image.Image, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// gifDefaultDelay is used by browsers for frames with delays of 0 or 1,
+// in hundredths of a second.
+const gifDefaultDelay = 10
+
+// DecodeGIF decodes the frames of an animated GIF, drawing each one over
+// the previous as browsers do. GIFs that play only once do not loop.
+func DecodeGIF(r io.Reader) (*ImportedAnimation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	a := &ImportedAnimation{Loop: g.LoopCount != -1}
+	for i, p := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		previous := canvas
+		if disposal == gif.DisposalPrevious {
+			previous = crop(canvas, bounds)
+		}
+		draw.Draw(canvas, p.Bounds(), p, p.Bounds().Min, draw.Over)
+		a.Frames = append(a.Frames, crop(canvas, bounds))
+
+		delay := gifDefaultDelay
+		if i < len(g.Delay) && g.Delay[i] > 1 {
+			delay = g.Delay[i]
+		}
+		a.Durations = append(a.Durations, time.Duration(delay)*10*time.Millisecond)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, p.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return a, nil
+}
+
+// asepriteSheet is the JSON exported by Aseprite along with the sheet
+// image, with the frames as an array or as a hash.
+type asepriteSheet struct {
+	Frames asepriteFrames `json:"frames"`
+	Meta   struct {
+		Image     string        `json:"image"`
+		FrameTags []asepriteTag `json:"frameTags"`
+	} `json:"meta"`
+}
+
+type asepriteFrame struct {
+	Filename         string       `json:"filename"`
+	Frame            ManifestRect `json:"frame"`
+	Rotated          bool         `json:"rotated"`
+	SpriteSourceSize ManifestRect `json:"spriteSourceSize"`
+	SourceSize       struct {
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"sourceSize"`
+	Duration int `json:"duration"`
+}
+
+type asepriteFrames []asepriteFrame
+
+func (f *asepriteFrames) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		return json.Unmarshal(b, (*[]asepriteFrame)(f))
+	}
+	// The order of the hash is the order of the frames, which is lost
+	// when decoded to a map.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var frame asepriteFrame
+		if err := dec.Decode(&frame); err != nil {
+			return err
+		}
+		frame.Filename, _ = key.(string)
+		*f = append(*f, frame)
+	}
+	return nil
+}
+
+type asepriteTag struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Direction string `json:"direction"`
+	Repeat    string `json:"repeat"`
+}
+
+// indices returns the frames of the tag in playing order.
+func (t asepriteTag) indices() (frames []int) {
+	forward := func(from, to int) {
+		for i := from; i <= to; i++ {
+			frames = append(frames, i)
+		}
+	}
+	backward := func(from, to int) {
+		for i := from; i >= to; i-- {
+			frames = append(frames, i)
+		}
+	}
+	switch t.Direction {
+	case "reverse":
+		backward(t.To, t.From)
+	case "pingpong":
+		forward(t.From, t.To)
+		backward(t.To-1, t.From+1)
+	case "pingpong_reverse":
+		backward(t.To, t.From)
+		forward(t.From+1, t.To-1)
+	default:
+		forward(t.From, t.To)
+	}
+	return frames
+}
+
+// asepriteDefaultDuration is the duration of new frames in Aseprite.
+const asepriteDefaultDuration = 100 * time.Millisecond
+
+// DecodeAseprite decodes the JSON and the sheet exported by Aseprite; the
+// image path in the JSON is relative to it. Each tag becomes an animation
+// named after it, and exports without tags have a single animation named
+// after the file. Tags with a repeat count play once.
+func DecodeAseprite(fsys fs.FS, name string) (map[string]*ImportedAnimation, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var sheet asepriteSheet
+	if err := json.Unmarshal(b, &sheet); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if len(sheet.Frames) == 0 {
+		return nil, fmt.Errorf("no frames")
+	}
+	imgPath := path.Join(path.Dir(name), sheet.Meta.Image)
+	img, err := decodeImg(fsys, imgPath)
+	if err != nil {
+		return nil, fmt.Errorf("image %q: %w", imgPath, err)
+	}
+
+	frames := make([]image.Image, len(sheet.Frames))
+	durations := make([]time.Duration, len(sheet.Frames))
+	for i, f := range sheet.Frames {
+		r := image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H).Add(img.Bounds().Min)
+		switch {
+		case f.Rotated:
+			return nil, fmt.Errorf("frame %q: rotated frames are not supported", f.Filename)
+		case r.Empty() || !r.In(img.Bounds()):
+			return nil, fmt.Errorf("frame %q: %v is outside of the image %v", f.Filename, r, img.Bounds())
+		}
+		// Trimmed frames are placed back in the size of the sprite
+		size := image.Rect(0, 0, f.SourceSize.W, f.SourceSize.H)
+		if size.Empty() {
+			size = image.Rect(0, 0, r.Dx(), r.Dy())
+		}
+		dst := image.NewRGBA(size)
+		at := image.Pt(f.SpriteSourceSize.X, f.SpriteSourceSize.Y)
+		draw.Draw(dst, image.Rectangle{at, at.Add(r.Size())}, img, r.Min, draw.Src)
+		frames[i] = dst
+
+		durations[i] = asepriteDefaultDuration
+		if f.Duration > 0 {
+			durations[i] = time.Duration(f.Duration) * time.Millisecond
+		}
+	}
+
+	tags := sheet.Meta.FrameTags
+	if len(tags) == 0 {
+		base := path.Base(name)
+		tags = []asepriteTag{{Name: strings.TrimSuffix(base, path.Ext(base)), To: len(frames) - 1}}
+	}
+	anims := make(map[string]*ImportedAnimation)
+	for _, t := range tags {
+		if _, ok := anims[t.Name]; ok {
+			return nil, fmt.Errorf("duplicated tag %q", t.Name)
+		}
+		if t.From < 0 || t.To >= len(frames) || t.From > t.To {
+			return nil, fmt.Errorf("tag %q: frames %d-%d of %d", t.Name, t.From, t.To, len(frames))
+		}
+		repeat, _ := strconv.Atoi(t.Repeat)
+		a := &ImportedAnimation{Loop: repeat == 0}
+		for _, i := range t.indices() {
+			a.Frames = append(a.Frames, frames[i])
+			a.Durations = append(a.Durations, durations[i])
+		}
+		anims[t.Name] = a
+	}
+	return anims, nil
+}
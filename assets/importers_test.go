@@ -0,0 +1,264 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// stripe returns a w x h sheet with a column n pixels wide for each
+// color, so the slices are easy to tell apart.
+func stripe(w, h, n int, colors ...color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		for y := range h {
+			img.Set(x, y, colors[x/n%len(colors)])
+		}
+	}
+	return img
+}
+
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	green = color.RGBA{0, 0xff, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+)
+
+func TestSliceSheet(t *testing.T) {
+	grid := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i, c := range []color.RGBA{red, green, blue} {
+		grid.Set(i%2*4, i/2*4, c)
+	}
+	for tn, tc := range []struct {
+		sheet  image.Image
+		w, h   int
+		count  int
+		expect []color.RGBA
+		err    bool
+	}{
+		{sheet: stripe(12, 4, 4, red, green, blue), w: 4, h: 4, expect: []color.RGBA{red, green, blue}},
+		{sheet: grid, w: 4, h: 4, count: 3, expect: []color.RGBA{red, green, blue}},
+		{sheet: grid, w: 4, h: 4, expect: []color.RGBA{red, green, blue, {}}},
+		{sheet: grid, w: 4, h: 4, count: 5, err: true},
+		{sheet: grid, w: 3, h: 4, err: true},
+		{sheet: grid, w: 0, h: 4, err: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			frames, err := SliceSheet(tc.sheet, tc.w, tc.h, tc.count)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %d frames", len(frames))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(frames) != len(tc.expect) {
+				t.Fatalf("expected %d frames, got %d", len(tc.expect), len(frames))
+			}
+			for i, f := range frames {
+				if f.Bounds() != image.Rect(0, 0, tc.w, tc.h) {
+					t.Errorf("frame %d: unexpected bounds %v", i, f.Bounds())
+				}
+				if c := color.RGBAModel.Convert(f.At(0, 0)); c != tc.expect[i] {
+					t.Errorf("frame %d: expected %v, got %v", i, tc.expect[i], c)
+				}
+			}
+		})
+	}
+}
+
+func testGIF(loopCount int, delays []int, disposal []byte) []byte {
+	g := &gif.GIF{LoopCount: loopCount, Delay: delays, Disposal: disposal}
+	g.Config.Width, g.Config.Height = 4, 4
+	for i := range delays {
+		// Each frame paints one more pixel of the diagonal
+		p := image.NewPaletted(image.Rect(i, i, i+1, i+1), palette.Plan9)
+		p.Set(i, i, red)
+		g.Image = append(g.Image, p)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeGIF(t *testing.T) {
+	for tn, tc := range []struct {
+		gif       []byte
+		loop      bool
+		durations []time.Duration
+		// painted is the number of pixels of the diagonal in each frame
+		painted []int
+	}{
+		{
+			gif:       testGIF(0, []int{5, 20, 0}, nil),
+			loop:      true,
+			durations: []time.Duration{50 * time.Millisecond, 200 * time.Millisecond, 100 * time.Millisecond},
+			painted:   []int{1, 2, 3},
+		},
+		{
+			gif:       testGIF(-1, []int{10, 10, 10}, []byte{gif.DisposalBackground, gif.DisposalNone, gif.DisposalNone}),
+			durations: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond},
+			painted:   []int{1, 1, 2},
+		},
+		{
+			gif:       testGIF(0, []int{10, 10, 10}, []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone}),
+			loop:      true,
+			durations: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond},
+			painted:   []int{1, 2, 2},
+		},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			a, err := DecodeGIF(bytes.NewReader(tc.gif))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if a.Loop != tc.loop {
+				t.Errorf("expected loop %v", tc.loop)
+			}
+			if fmt.Sprint(a.Durations) != fmt.Sprint(tc.durations) {
+				t.Errorf("expected durations %v, got %v", tc.durations, a.Durations)
+			}
+			for i, f := range a.Frames {
+				painted := 0
+				for d := range 4 {
+					if _, _, _, alpha := f.At(d, d).RGBA(); alpha > 0 {
+						painted++
+					}
+				}
+				if painted != tc.painted[i] {
+					t.Errorf("frame %d: expected %d painted pixels, got %d", i, tc.painted[i], painted)
+				}
+			}
+		})
+	}
+}
+
+func testAseprite(json string) fstest.MapFS {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, stripe(12, 4, 4, red, green, blue)); err != nil {
+		panic(err)
+	}
+	return fstest.MapFS{
+		"robot/robot.json": {Data: []byte(json)},
+		"robot/robot.png":  {Data: buf.Bytes()},
+	}
+}
+
+func TestDecodeAseprite(t *testing.T) {
+	const frames = `
+		{"filename": "robot 0.aseprite", "frame": {"x": 0, "y": 0, "w": 4, "h": 4}, "duration": 50},
+		{"filename": "robot 1.aseprite", "frame": {"x": 4, "y": 0, "w": 4, "h": 4}, "duration": 120},
+		{"filename": "robot 2.aseprite", "frame": {"x": 8, "y": 0, "w": 4, "h": 4}}`
+
+	for tn, tc := range []struct {
+		json   string
+		expect map[string][]color.RGBA
+		loop   map[string]bool
+		err    bool
+	}{
+		{
+			json:   `{"frames": [` + frames + `], "meta": {"image": "robot.png"}}`,
+			expect: map[string][]color.RGBA{"robot": {red, green, blue}},
+			loop:   map[string]bool{"robot": true},
+		},
+		{
+			json: `{"frames": {
+				"robot 2.aseprite": {"frame": {"x": 8, "y": 0, "w": 4, "h": 4}},
+				"robot 0.aseprite": {"frame": {"x": 0, "y": 0, "w": 4, "h": 4}}
+			}, "meta": {"image": "robot.png"}}`,
+			expect: map[string][]color.RGBA{"robot": {blue, red}},
+			loop:   map[string]bool{"robot": true},
+		},
+		{
+			json: `{"frames": [` + frames + `], "meta": {"image": "robot.png", "frameTags": [
+				{"name": "standing", "from": 0, "to": 0, "direction": "forward"},
+				{"name": "walking", "from": 0, "to": 2, "direction": "pingpong"},
+				{"name": "back", "from": 1, "to": 2, "direction": "reverse", "repeat": "1"}
+			]}}`,
+			expect: map[string][]color.RGBA{
+				"standing": {red},
+				"walking":  {red, green, blue, green},
+				"back":     {blue, green},
+			},
+			loop: map[string]bool{"standing": true, "walking": true, "back": false},
+		},
+		{
+			json: `{"frames": [` + frames + `], "meta": {"image": "robot.png", "frameTags": [
+				{"name": "walking", "from": 1, "to": 3}]}}`,
+			err: true,
+		},
+		{
+			json: `{"frames": [{"frame": {"x": 8, "y": 0, "w": 8, "h": 4}}], "meta": {"image": "robot.png"}}`,
+			err:  true,
+		},
+		{json: `{"frames": [` + frames + `], "meta": {"image": "missing.png"}}`, err: true},
+		{json: `{"frames": []}`, err: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			anims, err := DecodeAseprite(testAseprite(tc.json), "robot/robot.json")
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(anims) != len(tc.expect) {
+				t.Fatalf("expected %d animations, got %d", len(tc.expect), len(anims))
+			}
+			for name, colors := range tc.expect {
+				a, ok := anims[name]
+				if !ok {
+					t.Fatalf("missing animation %q", name)
+				}
+				if a.Loop != tc.loop[name] {
+					t.Errorf("%v: expected loop %v", name, tc.loop[name])
+				}
+				if len(a.Frames) != len(colors) || len(a.Durations) != len(colors) {
+					t.Fatalf("%v: expected %d frames, got %d", name, len(colors), len(a.Frames))
+				}
+				for i, f := range a.Frames {
+					if c := color.RGBAModel.Convert(f.At(0, 0)); c != colors[i] {
+						t.Errorf("%v: frame %d: expected %v, got %v", name, i, colors[i], c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeAsepriteTrimmed(t *testing.T) {
+	anims, err := DecodeAseprite(testAseprite(`{"frames": [{
+		"frame": {"x": 4, "y": 0, "w": 4, "h": 4},
+		"spriteSourceSize": {"x": 2, "y": 4, "w": 4, "h": 4},
+		"sourceSize": {"w": 8, "h": 8},
+		"duration": 70
+	}], "meta": {"image": "robot.png"}}`), "robot/robot.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a := anims["robot"]
+	f := a.Frames[0]
+	if f.Bounds() != image.Rect(0, 0, 8, 8) || a.Durations[0] != 70*time.Millisecond {
+		t.Fatalf("unexpected frame %v, duration %v", f.Bounds(), a.Durations[0])
+	}
+	if _, _, _, alpha := f.At(1, 3).RGBA(); alpha != 0 {
+		t.Errorf("expected transparent space around the trimmed sprite")
+	}
+	if c := color.RGBAModel.Convert(f.At(2, 4)); c != green {
+		t.Errorf("expected the trimmed sprite at (2,4), got %v", c)
+	}
+}
@@ -0,0 +1,317 @@
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/codigolandia/live-quest/log"
+)
+
+// ManifestFile is the name of the manifest in the root of an asset pack.
+const ManifestFile = "pack.json"
+
+// CharacterAnimations are required in the bundles of playable characters.
+var CharacterAnimations = []string{"standing", "walking_left", "walking_right", "fight_standing"}
+
+// Characters are the bundles viewers can play with.
+var Characters = []string{"gopher"}
+
+// Manifest describes the bundles of an asset pack.
+type Manifest struct {
+	Name    string                    `json:"name"`
+	Author  string                    `json:"author"`
+	Bundles map[string]BundleManifest `json:"bundles"`
+}
+
+// BundleManifest describes a bundle; characters must have the animations
+// in CharacterAnimations.
+type BundleManifest struct {
+	Character  bool                         `json:"character"`
+	Animations map[string]AnimationManifest `json:"animations"`
+
+	// Aseprite are JSON exports of Aseprite, whose tags become animations
+	// of the bundle. Animations without frames with the name of a tag
+	// configure it, like its anchor and hitbox.
+	Aseprite []string `json:"aseprite"`
+}
+
+// AnimationManifest describes an animation. Frames are tinted with the
+// character color, while skins are drawn over them as they are. Paths are
+// relative to the pack directory and may be glob patterns, like
+// "robot/standing_*.png". Animated GIFs add all their frames, with their
+// durations.
+type AnimationManifest struct {
+	Frames []string `json:"frames"`
+	Skins  []string `json:"skins"`
+
+	// FrameSize makes the PNG images spritesheets, sliced from left to
+	// right and top to bottom.
+	FrameSize *ManifestSize `json:"frameSize"`
+
+	// Count is the number of frames, for sheets with empty cells at the
+	// end.
+	Count int `json:"count"`
+
+	// AnimationConfig sets the mode, the durations and the frame events;
+	// the animations loop by default.
+	AnimationConfig
+
+	Anchor *ManifestPoint `json:"anchor"`
+	Hitbox *ManifestRect  `json:"hitbox"`
+}
+
+type ManifestPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type ManifestSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type ManifestRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Pack is an asset pack loaded from disk.
+type Pack struct {
+	Manifest Manifest
+	Bundles  map[string]Bundle
+}
+
+// LoadPackDir loads the asset pack in the directory.
+func LoadPackDir(dir string) (*Pack, error) {
+	return LoadPack(os.DirFS(dir))
+}
+
+// LoadPack reads and validates the manifest, and loads the images of the
+// pack. All the problems found are returned together.
+func LoadPack(fsys fs.FS) (*Pack, error) {
+	b, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("pack: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("pack: invalid %v: %w", ManifestFile, err)
+	}
+	if m.Name == "" {
+		return nil, fmt.Errorf("pack: %v: missing name", ManifestFile)
+	}
+	if len(m.Bundles) == 0 {
+		return nil, fmt.Errorf("pack %q: no bundles", m.Name)
+	}
+
+	p := &Pack{Manifest: m, Bundles: make(map[string]Bundle)}
+	at := &atlas{}
+	var errs []error
+	for _, name := range sortedKeys(m.Bundles) {
+		bm := m.Bundles[name]
+		imported := make(map[string]*ImportedAnimation)
+		for _, f := range bm.Aseprite {
+			anims, err := DecodeAseprite(fsys, f)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pack %q: bundle %q: aseprite %q: %w", m.Name, name, f, err))
+				continue
+			}
+			for tag, a := range anims {
+				if _, ok := imported[tag]; ok {
+					errs = append(errs, fmt.Errorf("pack %q: bundle %q: aseprite %q: tag %q is also in another file", m.Name, name, f, tag))
+				}
+				imported[tag] = a
+			}
+		}
+		anims := sortedKeys(bm.Animations)
+		for _, tag := range sortedKeys(imported) {
+			if _, ok := bm.Animations[tag]; !ok {
+				anims = append(anims, tag)
+			}
+		}
+
+		bundle := make(Bundle)
+		for _, anim := range anims {
+			a, err := loadPackAnimation(at, fsys, bm.Animations[anim], imported[anim])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pack %q: bundle %q: animation %q: %w", m.Name, name, anim, err))
+				continue
+			}
+			bundle[anim] = a
+		}
+		if bm.Character {
+			for _, anim := range CharacterAnimations {
+				if !slices.Contains(anims, anim) {
+					errs = append(errs, fmt.Errorf("pack %q: bundle %q: characters require the animation %q", m.Name, name, anim))
+				}
+			}
+		}
+		p.Bundles[name] = bundle
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	at.build()
+	return p, nil
+}
+
+// loadPackAnimation loads the animation described in the manifest. The
+// imported animation, if any, is used when the manifest has no frames.
+func loadPackAnimation(at *atlas, fsys fs.FS, am AnimationManifest, imported *ImportedAnimation) (*Animation, error) {
+	src := imported
+	if src == nil || len(am.Frames) > 0 {
+		var err error
+		if src, err = loadPackImages(fsys, am.Frames, am.FrameSize, am.Count); err != nil {
+			return nil, err
+		}
+	}
+	frames := src.Frames
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames")
+	}
+	skinSrc, err := loadPackImages(fsys, am.Skins, am.FrameSize, am.Count)
+	if err != nil {
+		return nil, err
+	}
+	skins := skinSrc.Frames
+	if len(skins) > 0 && len(skins) != len(frames) {
+		return nil, fmt.Errorf("%d skins for %d frames", len(skins), len(frames))
+	}
+	size := frames[0].Bounds().Size()
+	for i, img := range append(frames, skins...) {
+		if img.Bounds().Size() != size {
+			return nil, fmt.Errorf("image %d is %v, expected %v like the first frame",
+				i, img.Bounds().Size(), size)
+		}
+	}
+	bounds := image.Rectangle{Max: size}
+
+	a := &Animation{Durations: src.Durations, Anchor: image.Pt(size.X/2, size.Y)}
+	grays := make([]image.Image, len(frames))
+	for i, img := range frames {
+		grays[i] = grayScale(img)
+	}
+	at.addAnimation(a, grays, skins)
+	if !src.Loop {
+		a.Mode = PlayOnce
+	}
+	if err := a.configure(am.AnimationConfig); err != nil {
+		return nil, err
+	}
+	if am.Anchor != nil {
+		a.Anchor = image.Pt(am.Anchor.X, am.Anchor.Y)
+		if a.Anchor.X < 0 || a.Anchor.Y < 0 || a.Anchor.X > size.X || a.Anchor.Y > size.Y {
+			return nil, fmt.Errorf("anchor %v is outside of the frame %v", a.Anchor, size)
+		}
+	}
+	if am.Hitbox != nil {
+		h := am.Hitbox
+		a.Hitbox = image.Rect(h.X, h.Y, h.X+h.W, h.Y+h.H)
+		if h.W <= 0 || h.H <= 0 || !a.Hitbox.In(bounds) {
+			return nil, fmt.Errorf("hitbox %v must have a positive size inside of the frame %v", a.Hitbox, size)
+		}
+	}
+	return a, nil
+}
+
+// loadPackImages decodes the images, expanding the glob patterns, the
+// GIFs and the spritesheets. The durations are only kept if all the
+// frames come from GIFs.
+func loadPackImages(fsys fs.FS, paths []string, frameSize *ManifestSize, count int) (*ImportedAnimation, error) {
+	imp := &ImportedAnimation{Loop: true}
+	timed := true
+	for _, p := range paths {
+		files := []string{p}
+		if strings.ContainsAny(p, "*?[") {
+			var err error
+			if files, err = fs.Glob(fsys, p); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no files match %q", p)
+			}
+		}
+		for _, f := range files {
+			if strings.EqualFold(path.Ext(f), ".gif") {
+				a, err := decodePackGIF(fsys, f)
+				if err != nil {
+					return nil, fmt.Errorf("image %q: %w", f, err)
+				}
+				imp.Frames = append(imp.Frames, a.Frames...)
+				imp.Durations = append(imp.Durations, a.Durations...)
+				imp.Loop = imp.Loop && a.Loop
+				continue
+			}
+			timed = false
+			img, err := decodeImg(fsys, f)
+			if err != nil {
+				return nil, fmt.Errorf("image %q: %w", f, err)
+			}
+			if frameSize == nil {
+				imp.Frames = append(imp.Frames, img)
+				continue
+			}
+			sliced, err := SliceSheet(img, frameSize.W, frameSize.H, 0)
+			if err != nil {
+				return nil, fmt.Errorf("sheet %q: %w", f, err)
+			}
+			imp.Frames = append(imp.Frames, sliced...)
+		}
+	}
+	if !timed {
+		imp.Durations = nil
+	}
+	if count > 0 && len(imp.Frames) > 0 {
+		if count > len(imp.Frames) {
+			return nil, fmt.Errorf("count is %d, but there are %d frames", count, len(imp.Frames))
+		}
+		imp.Frames = imp.Frames[:count]
+		if imp.Durations != nil {
+			imp.Durations = imp.Durations[:count]
+		}
+	}
+	return imp, nil
+}
+
+func decodePackGIF(fsys fs.FS, name string) (*ImportedAnimation, error) {
+	r, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return DecodeGIF(r)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AddPack makes the bundles of the pack available to the game, replacing
+// the bundles with the same name.
+func AddPack(p *Pack) {
+	for _, name := range sortedKeys(p.Bundles) {
+		if _, ok := Bundles[name]; ok {
+			log.I("assets: pack %q replaces the bundle %q", p.Manifest.Name, name)
+		}
+		Bundles[name] = p.Bundles[name]
+		if p.Manifest.Bundles[name].Character && !slices.Contains(Characters, name) {
+			Characters = append(Characters, name)
+		}
+	}
+	log.I("assets: loaded pack %q with %d bundles", p.Manifest.Name, len(p.Bundles))
+}
@@ -0,0 +1,194 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testPNG(w, h int) *fstest.MapFile {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		panic(err)
+	}
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func testPack(manifest string) fstest.MapFS {
+	return fstest.MapFS{
+		"pack.json":           {Data: []byte(manifest)},
+		"robot/stand_0.png":   testPNG(64, 96),
+		"robot/stand_1.png":   testPNG(64, 96),
+		"robot/skin_0.png":    testPNG(64, 96),
+		"robot/walk_0.png":    testPNG(64, 96),
+		"robot/fight_0.png":   testPNG(64, 96),
+		"robot/big.png":       testPNG(128, 128),
+		"robot/not_image.png": {Data: []byte("oops")},
+		"robot/walk.png":      testSheet(3*64, 96),
+		"robot/wave.gif":      {Data: testGIF(-1, []int{10, 20, 30}, nil)},
+	}
+}
+
+func testSheet(w, h int) *fstest.MapFile {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, stripe(w, h, 64, red, green, blue)); err != nil {
+		panic(err)
+	}
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+const robotAnimations = `
+	"walking_left": {"frames": ["robot/walk_0.png"]},
+	"walking_right": {"frames": ["robot/walk_0.png"]},
+	"fight_standing": {"frames": ["robot/fight_0.png"], "loop": false}`
+
+func TestLoadPack(t *testing.T) {
+	p, err := LoadPack(testPack(`{
+		"name": "robots",
+		"bundles": {"robot": {"character": true, "animations": {
+			"standing": {
+				"frames": ["robot/stand_*.png"],
+				"durations": [100, 250],
+				"mode": "pingpong",
+				"events": [{"frame": 1, "name": "blink"}],
+				"anchor": {"x": 32, "y": 90},
+				"hitbox": {"x": 8, "y": 16, "w": 48, "h": 74}
+			},` + robotAnimations + `
+		}}}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	robot := p.Bundles["robot"]
+	if len(robot) != 4 {
+		t.Fatalf("expected 4 animations, got %v", robot)
+	}
+	s := robot["standing"]
+	if len(s.Frames) != 2 || s.Mode != PlayPingPong || len(s.EventsAt(1)) != 1 {
+		t.Errorf("unexpected standing animation: %d frames, mode %v", len(s.Frames), s.Mode)
+	}
+	if len(s.Durations) != 2 || s.Durations[1] != 250*time.Millisecond {
+		t.Errorf("unexpected durations: %v", s.Durations)
+	}
+	if s.Anchor != image.Pt(32, 90) || s.Hitbox != image.Rect(8, 16, 56, 90) {
+		t.Errorf("unexpected anchor %v and hitbox %v", s.Anchor, s.Hitbox)
+	}
+	if f := robot["fight_standing"]; f.Mode != PlayOnce || f.Anchor != image.Pt(32, 96) {
+		t.Errorf("unexpected defaults: mode %v, anchor %v", f.Mode, f.Anchor)
+	}
+}
+
+func TestLoadPackImports(t *testing.T) {
+	fsys := testPack(`{
+		"name": "robots",
+		"bundles": {"robot": {
+			"aseprite": ["robot/robot.json"],
+			"animations": {
+				"walking": {"frames": ["robot/walk.png"], "frameSize": {"w": 64, "h": 96}, "count": 2},
+				"wave": {"frames": ["robot/wave.gif"]},
+				"standing": {"anchor": {"x": 2, "y": 4}}
+			}
+		}}
+	}`)
+	for name, f := range testAseprite(`{"frames": [
+		{"frame": {"x": 0, "y": 0, "w": 4, "h": 4}, "duration": 80},
+		{"frame": {"x": 4, "y": 0, "w": 4, "h": 4}, "duration": 80}
+	], "meta": {"image": "robot.png", "frameTags": [
+		{"name": "standing", "from": 0, "to": 1},
+		{"name": "jump", "from": 1, "to": 1}
+	]}}`) {
+		fsys[name] = f
+	}
+	p, err := LoadPack(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	robot := p.Bundles["robot"]
+	for name, frames := range map[string]int{"walking": 2, "wave": 3, "standing": 2, "jump": 1} {
+		if a, ok := robot[name]; !ok || len(a.Frames) != frames {
+			t.Errorf("%v: expected %d frames, got %v", name, frames, a)
+		}
+	}
+	if w := robot["walking"]; w.Mode != PlayLoop || len(w.Durations) != 0 {
+		t.Errorf("sheets should loop with the default speed, got %v", w.Durations)
+	}
+	if w := robot["wave"]; w.Mode != PlayOnce || len(w.Durations) != 3 || w.Durations[2] != 300*time.Millisecond {
+		t.Errorf("expected the GIF timing, got mode %v and %v", w.Mode, w.Durations)
+	}
+	if s := robot["standing"]; s.Anchor != image.Pt(2, 4) || s.Durations[0] != 80*time.Millisecond {
+		t.Errorf("expected the configured Aseprite tag, got %v and %v", s.Anchor, s.Durations)
+	}
+}
+
+func TestLoadPackErrors(t *testing.T) {
+	for tn, tc := range []struct {
+		manifest string
+		expect   []string
+	}{
+		{manifest: `{"name": `, expect: []string{"invalid pack.json"}},
+		{manifest: `{"bundles": {}}`, expect: []string{"missing name"}},
+		{
+			manifest: `{"name": "robots", "bundles": {"fx": {"animations": {"a": {"mode": "bounce"}}}}}`,
+			expect:   []string{`unknown mode "bounce"`},
+		},
+		{manifest: `{"name": "robots"}`, expect: []string{`pack "robots": no bundles`}},
+		{
+			manifest: `{"name": "robots", "bundles": {"robot": {"character": true, "animations": {
+				"standing": {"frames": ["robot/stand_0.png"]}}}}}`,
+			expect: []string{
+				`bundle "robot": characters require the animation "walking_left"`,
+				`characters require the animation "fight_standing"`,
+			},
+		},
+		{
+			manifest: `{"name": "robots", "bundles": {"fx": {"animations": {
+				"a": {"frames": []},
+				"b": {"frames": ["robot/missing.png"]},
+				"c": {"frames": ["robot/nothing_*.png"]},
+				"d": {"frames": ["robot/not_image.png"]},
+				"e": {"frames": ["robot/stand_0.png", "robot/big.png"]},
+				"f": {"frames": ["robot/stand_*.png"], "skins": ["robot/skin_0.png"]},
+				"g": {"frames": ["robot/stand_*.png"], "durations": [100, 100, 100]},
+				"h": {"frames": ["robot/stand_0.png"], "durations": [0]},
+				"i": {"frames": ["robot/stand_0.png"], "anchor": {"x": 65, "y": 0}},
+				"j": {"frames": ["robot/stand_0.png"], "hitbox": {"x": 32, "y": 0, "w": 48, "h": 10}},
+				"k": {"frames": ["robot/walk.png"], "frameSize": {"w": 50, "h": 96}},
+				"l": {"frames": ["robot/walk.png"], "frameSize": {"w": 64, "h": 96}, "count": 4},
+				"m": {"frames": ["robot/stand_0.png"], "events": [{"frame": 5, "name": "hit"}]}
+			}, "aseprite": ["robot/missing.json"]}}}`,
+			expect: []string{
+				`animation "a": no frames`,
+				`animation "b": image "robot/missing.png"`,
+				`animation "c": no files match "robot/nothing_*.png"`,
+				`animation "d": image "robot/not_image.png"`,
+				`animation "e": image 1 is (128,128), expected (64,96)`,
+				`animation "f": 1 skins for 2 frames`,
+				`animation "g": 3 durations for 2 frames`,
+				`animation "h": duration of frame 0 must be positive`,
+				`animation "i": anchor (65,0) is outside of the frame`,
+				`animation "j": hitbox (32,0)-(80,10) must have a positive size inside`,
+				`animation "k": sheet "robot/walk.png": sheet size (192,96) is not a multiple`,
+				`animation "l": count is 4, but there are 3 frames`,
+				`animation "m": invalid event "hit" on frame 5 of 1`,
+				`aseprite "robot/missing.json"`,
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			_, err := LoadPack(testPack(tc.manifest))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			for _, e := range tc.expect {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("expected %q in the error:\n%v", e, err)
+				}
+			}
+		})
+	}
+}
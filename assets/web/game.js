@@ -0,0 +1,303 @@
+// Draws the game state streamed by the server on a canvas, so OBS can
+// capture the game with a Browser Source instead of the game window.
+const canvas = document.getElementById("game");
+const ctx = canvas.getContext("2d");
+
+// Layers drawn over the gopher skin, as in the game
+const cosmeticSlots = ["eyes", "accessory", "hat"];
+const gradientBands = 8;
+
+let sprites = null;
+let state = null;
+
+// Images by path; body frames are converted to gray scale to be tinted.
+const images = new Map();
+// Tinted frames by path and colors.
+const tinted = new Map();
+
+function loadImage(path, gray) {
+	const key = `${path}|${gray}`;
+	if (images.has(key)) {
+		return images.get(key);
+	}
+	let entry = {ready: false, img: null};
+	images.set(key, entry);
+	let img = new Image();
+	img.onload = () => {
+		entry.img = gray ? grayScale(img) : img;
+		entry.ready = true;
+	};
+	img.src = `/sprites/${path}`;
+	return entry;
+}
+
+function grayScale(img) {
+	let c = document.createElement("canvas");
+	c.width = img.width;
+	c.height = img.height;
+	let cc = c.getContext("2d");
+	cc.drawImage(img, 0, 0);
+	let data = cc.getImageData(0, 0, c.width, c.height);
+	let px = data.data;
+	for (let i = 0; i < px.length; i += 4) {
+		let l = (19595 * px[i] + 38470 * px[i + 1] + 7471 * px[i + 2] + 32768) >> 16;
+		px[i] = px[i + 1] = px[i + 2] = l;
+	}
+	cc.putImageData(data, 0, 0);
+	return c;
+}
+
+function parseColor(hex) {
+	return [1, 3, 5].map(i => parseInt(hex.substr(i, 2), 16));
+}
+
+function lerpColor(a, b, t) {
+	let ca = parseColor(a), cb = parseColor(b);
+	let c = ca.map((v, i) => Math.round(v + (cb[i] - v) * t));
+	return `rgb(${c[0]}, ${c[1]}, ${c[2]})`;
+}
+
+// tint multiplies the gray frame by the color, or by a gradient from
+// color to color2 in horizontal bands, like the game does.
+function tint(path, color, color2) {
+	const key = `${path}|${color}|${color2}`;
+	if (tinted.has(key)) {
+		return tinted.get(key);
+	}
+	let src = loadImage(path, true);
+	if (!src.ready) {
+		return null;
+	}
+	let c = document.createElement("canvas");
+	c.width = src.img.width;
+	c.height = src.img.height;
+	let cc = c.getContext("2d");
+	cc.drawImage(src.img, 0, 0);
+	cc.globalCompositeOperation = "multiply";
+	if (color2) {
+		let band = Math.floor(c.height / gradientBands);
+		for (let i = 0; i < gradientBands; i++) {
+			cc.fillStyle = lerpColor(color, color2, i / (gradientBands - 1));
+			let h = i == gradientBands - 1 ? c.height - i * band : band;
+			cc.fillRect(0, i * band, c.width, h);
+		}
+	} else {
+		cc.fillStyle = color;
+		cc.fillRect(0, 0, c.width, c.height);
+	}
+	// Restore the transparency of the frame
+	cc.globalCompositeOperation = "destination-in";
+	cc.drawImage(src.img, 0, 0);
+	tinted.set(key, c);
+	return c;
+}
+
+function drawImage(img, x, y) {
+	if (img) {
+		ctx.drawImage(img, x, y);
+	}
+}
+
+function plain(path) {
+	let entry = loadImage(path, false);
+	return entry.ready ? entry.img : null;
+}
+
+function drawSprite(s, scale, outfit) {
+	let a = sprites.bundles[s.bundle] && sprites.bundles[s.bundle][s.animation];
+	if (!a || s.frame >= a.frames.length) {
+		return;
+	}
+	ctx.save();
+	ctx.translate(s.x, s.y);
+	ctx.scale(scale, scale);
+	if (s.flip) {
+		ctx.translate(state.size, 0);
+		ctx.scale(-1, 1);
+	}
+	ctx.translate(s.offsetX, s.offsetY);
+	drawImage(tint(a.frames[s.frame], s.color || "#ffffff", s.color2), 0, 0);
+	let layer = (slot) => {
+		let name = outfit && outfit[slot];
+		let c = name && sprites.cosmetics[`${slot}/${name}`];
+		let frames = c && c[s.animation];
+		if (frames && s.frame < frames.frames.length) {
+			drawImage(plain(frames.frames[s.frame]), 0, 0);
+		}
+	};
+	layer("outfit");
+	if (s.frame < (a.skins || []).length) {
+		drawImage(plain(a.skins[s.frame]), 0, 0);
+	}
+	cosmeticSlots.forEach(layer);
+	ctx.restore();
+}
+
+function drawBar(img, x, y, fill) {
+	if (img) {
+		ctx.drawImage(img, x, y, img.width * fill, img.height);
+	}
+}
+
+function drawBars(v) {
+	let barH = 8;
+	let hpY = v.y - state.lineHeight - barH;
+	let xpY = hpY - barH;
+	drawBar(plain(sprites.images.barBG), v.x, hpY - 1, 1);
+	drawBar(plain(sprites.images.hpBar), v.x, hpY, v.hp / 100);
+	drawImage(plain(sprites.images[v.platform.toLowerCase()]), v.x - 18, v.y - 40);
+	drawBar(plain(sprites.images.barBG), v.x, xpY - 1, 1);
+	drawBar(plain(sprites.images.xpBar), v.x, xpY, v.xp);
+}
+
+function drawText(text, x, y, color, center) {
+	ctx.font = `${Math.round(state.lineHeight * 0.9)}px monospace`;
+	if (center) {
+		x -= ctx.measureText(text).width / 2;
+	}
+	ctx.lineWidth = 4;
+	ctx.strokeStyle = "black";
+	ctx.strokeText(text, x, y);
+	ctx.fillStyle = color || "white";
+	ctx.fillText(text, x, y);
+}
+
+function drawBubble(v) {
+	ctx.font = `${Math.round(state.lineHeight * 0.9)}px monospace`;
+	let w = Math.max(...v.bubble.map(l => ctx.measureText(l).width)) + 16;
+	let h = v.bubble.length * 24 + 16;
+	let x = v.x + (state.size - w) / 2;
+	let y = v.y - 56 - h;
+	ctx.fillStyle = "#202020";
+	ctx.fillRect(x - 2, y - 2, w + 4, h + 4);
+	ctx.fillStyle = "rgba(255, 255, 255, 0.94)";
+	ctx.fillRect(x, y, w, h);
+	ctx.fillStyle = "#202020";
+	v.bubble.forEach((l, i) => ctx.fillText(l, x + 8, y + 8 + (i + 1) * 24 - 6));
+}
+
+// Stands of the arena, filled down to the floor with the seats on top.
+function drawStands() {
+	(state.stands || []).forEach(r => {
+		ctx.fillStyle = "#402910";
+		ctx.fillRect(r.x, r.y, r.w, r.h);
+		ctx.fillStyle = "#4a2c0a";
+		ctx.fillRect(r.x, r.y, r.w, 8);
+	});
+}
+
+// HUD panel laid out by the game, with the right aligned texts ending at x.
+function drawHUDPanel(p) {
+	ctx.globalAlpha = p.opacity;
+	ctx.fillStyle = p.background;
+	ctx.fillRect(p.x, p.y, p.w, p.h);
+	ctx.globalAlpha = 1;
+	p.texts.forEach(t => {
+		ctx.font = `${Math.round(state.lineHeight * 0.9 * p.scale)}px monospace`;
+		let x = t.right ? t.x - ctx.measureText(t.text).width : t.x;
+		ctx.lineWidth = 4;
+		ctx.strokeStyle = "black";
+		ctx.strokeText(t.text, x, t.y);
+		ctx.fillStyle = t.color;
+		ctx.fillText(t.text, x, t.y);
+	});
+}
+
+// Full screen panel of the leader board and credits scenes.
+function drawPanel(p) {
+	let pw = Math.min(state.width - 200, 1000);
+	let px = (state.width - pw) / 2;
+	let rh = Math.round(state.lineHeight * 1.5);
+	ctx.fillStyle = "#202030";
+	ctx.fillRect(px, 0, pw, state.height);
+	if (p.title) {
+		drawText(p.title, state.width / 2, state.height / 4 - rh + state.lineHeight, "white", true);
+	}
+	(p.rows || []).forEach((row, i) => {
+		let y = p.y + i * rh + state.lineHeight;
+		if (y < 0 || y > state.height + rh) {
+			return;
+		}
+		if (row.length == 1 || !p.columns) {
+			drawText(row.join(" "), state.width / 2, y, "white", true);
+			return;
+		}
+		let x = px + 32;
+		row.forEach((cell, j) => {
+			drawText(cell, x, y);
+			x += (p.columns[j] || 0) * (pw - 64);
+		});
+	});
+}
+
+function drawCurtain(c) {
+	ctx.fillStyle = `rgba(0, 0, 0, ${c.alpha})`;
+	ctx.fillRect(c.x, 0, c.w, state.height);
+}
+
+function draw() {
+	requestAnimationFrame(draw);
+	if (!sprites || !state) {
+		return;
+	}
+	if (canvas.width != state.width || canvas.height != state.height) {
+		canvas.width = state.width;
+		canvas.height = state.height;
+	}
+	ctx.clearRect(0, 0, canvas.width, canvas.height);
+	if (state.panel) {
+		drawPanel(state.panel);
+	} else {
+		drawScene();
+	}
+	if (state.curtain) {
+		drawCurtain(state.curtain);
+	}
+}
+
+function drawScene() {
+	drawStands();
+	let viewers = state.viewers || [];
+	viewers.forEach(v => drawSprite(v, 1, v.outfit));
+	viewers.forEach(drawBars);
+	viewers.forEach(v => {
+		let tag = `${v.name} [${v.level}]`;
+		drawText(tag, v.x + state.size / 2, v.y - 2, "white", true);
+	});
+	if (state.boss) {
+		let b = state.boss;
+		let size = state.size * b.scale;
+		drawSprite(b, b.scale);
+		ctx.fillStyle = "#404040";
+		ctx.fillRect(b.x, b.y - 16, size, 16);
+		ctx.fillStyle = b.color;
+		ctx.fillRect(b.x, b.y - 16, size * b.hp, 16);
+		drawText(b.title, b.x + size / 2, b.y - 48, "white", true);
+	}
+	(state.projectiles || []).forEach(p => drawSprite(p, 1));
+	(state.texts || []).forEach(t => drawText(t.text, t.x, t.y, t.color, t.center));
+	viewers.filter(v => v.bubble).forEach(drawBubble);
+
+	(state.hud || []).forEach(drawHUDPanel);
+}
+
+function connect() {
+	let proto = location.protocol == "https:" ? "wss" : "ws";
+	let ws = new WebSocket(`${proto}://${location.host}/stream`);
+	ws.onmessage = (e) => {
+		state = JSON.parse(e.data);
+	};
+	ws.onclose = () => {
+		state = null;
+		setTimeout(connect, 1000);
+	};
+}
+
+async function start() {
+	const resp = await fetch("/sprites.json");
+	sprites = await resp.json();
+	connect();
+	requestAnimationFrame(draw);
+}
+
+start();
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/bitmapfont/v3"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+)
+
+// Achievement is a goal viewers can reach beyond raw XP.
+//
+// Achievements are evaluated when one of the events in On happens to the
+// viewer; Check may be nil when the event alone grants the achievement.
+type Achievement struct {
+	ID          string
+	Name        string
+	Description string
+
+	On    []EventType
+	Check func(g *Game, v *Viewer) bool
+
+	// Badge is the icon drawn next to the viewer name tag.
+	Badge       color.RGBA
+	BadgeSymbol string
+}
+
+var Achievements = []Achievement{
+	{
+		ID:          "first-message",
+		Name:        "Olá, mundo!",
+		Description: "enviou a primeira mensagem",
+		On:          []EventType{EventMessage},
+		Badge:       color.RGBA{0x00, 0xad, 0xd8, 0xff},
+		BadgeSymbol: "1",
+	},
+	{
+		ID:          "first-chatter",
+		Name:        "Madrugador",
+		Description: "foi o primeiro a falar no chat hoje",
+		On:          []EventType{EventFirstChat},
+		Badge:       color.RGBA{0xff, 0xd7, 0x00, 0xff},
+		BadgeSymbol: "!",
+	},
+	{
+		ID:          "fighter",
+		Name:        "Lutador",
+		Description: "venceu 10 lutas",
+		On:          []EventType{EventFightWon},
+		Check: func(g *Game, v *Viewer) bool {
+			return v.FightsWon >= 10
+		},
+		Badge:       color.RGBA{0xe0, 0x30, 0x30, 0xff},
+		BadgeSymbol: "F",
+	},
+	{
+		ID:          "all-challenges",
+		Name:        "Gopher Sênior",
+		Description: "resolveu todos os desafios",
+		On:          []EventType{EventChallengeSolved},
+		Check: func(g *Game, v *Viewer) bool {
+			return len(Challenges) > 0 && v.CompletedCount() >= len(Challenges)
+		},
+		Badge:       color.RGBA{0x2e, 0xb8, 0x57, 0xff},
+		BadgeSymbol: "C",
+	},
+	{
+		ID:          "stream-streak",
+		Name:        "Assíduo",
+		Description: "participou de 5 lives seguidas",
+		On:          []EventType{EventMessage},
+		Check: func(g *Game, v *Viewer) bool {
+			return v.StreamStreak >= 5
+		},
+		Badge:       color.RGBA{0x94, 0x4d, 0xff, 0xff},
+		BadgeSymbol: "5",
+	},
+	{
+		ID:          "tournament-champion",
+		Name:        "Campeão",
+		Description: "venceu um torneio",
+		On:          []EventType{EventTournamentWon},
+		Badge:       color.RGBA{0xff, 0xa5, 0x00, 0xff},
+		BadgeSymbol: "T",
+	},
+}
+
+func (a Achievement) triggeredBy(t EventType) bool {
+	for _, on := range a.On {
+		if on == t {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAchievements grants to the viewer the achievements reached after
+// the event.
+func (g *Game) CheckAchievements(v *Viewer, e Event) {
+	for _, a := range Achievements {
+		if v.HasAchievement(a.ID) || !a.triggeredBy(e.Type) {
+			continue
+		}
+		if a.Check != nil && !a.Check(g, v) {
+			continue
+		}
+		log.I("achievements: %v earned %v", v.Name, a.ID)
+		v.GrantAchievement(a.ID)
+		v.Jump()
+		g.SendMessage(v.Platform,
+			fmt.Sprintf("@%s conquistou \"%s\": %s!", v.Name, a.Name, a.Description))
+	}
+}
+
+// ReplyAchievements lists the achievements of the viewer in the chat.
+func (g *Game) ReplyAchievements(v *Viewer) {
+	names := []string{}
+	for _, a := range Achievements {
+		if v.HasAchievement(a.ID) {
+			names = append(names, a.Name)
+		}
+	}
+	msg := fmt.Sprintf("@%s: conquistas (%d/%d)", v.Name, len(names), len(Achievements))
+	if len(names) > 0 {
+		msg += ": " + strings.Join(names, ", ")
+	}
+	g.SendMessage(v.Platform, msg)
+}
+
+func (v *Viewer) HasAchievement(id string) bool {
+	_, ok := v.Achievements[id]
+	return ok
+}
+
+func (v *Viewer) GrantAchievement(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.Achievements[id] = time.Now()
+}
+
+func (v *Viewer) CompletedCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return len(v.CompletedChallenges)
+}
+
+// badgeSize is the size in pixels of the achievement badges.
+const badgeSize = 16
+
+// DrawBadges draws the achievement badges of the viewer, starting at px
+// and vertically centered with the text baseline at py.
+func (v *Viewer) DrawBadges(screen *ebiten.Image, px, py float64) {
+	opts := &ebiten.DrawImageOptions{}
+	for _, a := range Achievements {
+		if !v.HasAchievement(a.ID) {
+			continue
+		}
+		// Border
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(badgeSize, badgeSize)
+		opts.GeoM.Translate(px, py-badgeSize)
+		opts.ColorScale.Reset()
+		opts.ColorScale.Scale(0, 0, 0, 1)
+		screen.DrawImage(pixel, opts)
+		// Badge
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(badgeSize-4, badgeSize-4)
+		opts.GeoM.Translate(px+2, py-badgeSize+2)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(a.Badge)
+		screen.DrawImage(pixel, opts)
+		// Symbol
+		opts.GeoM.Reset()
+		opts.GeoM.Translate(px+5, py-3)
+		opts.ColorScale.Reset()
+		opts.ColorScale.Scale(0, 0, 0, 1)
+		text.DrawWithOptions(screen, a.BadgeSymbol, bitmapfont.Face, opts)
+
+		px += badgeSize + 2
+	}
+}
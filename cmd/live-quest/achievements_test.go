@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckAchievements(t *testing.T) {
+	testCases := []struct {
+		event  Event
+		setup  func(v *Viewer)
+		wanted []string
+	}{
+		{
+			event:  Event{Type: EventMessage},
+			wanted: []string{"first-message"},
+		},
+		{
+			event:  Event{Type: EventFightWon},
+			setup:  func(v *Viewer) { v.FightsWon = 9 },
+			wanted: []string{},
+		},
+		{
+			event:  Event{Type: EventFightWon},
+			setup:  func(v *Viewer) { v.FightsWon = 10 },
+			wanted: []string{"fighter"},
+		},
+		{
+			event:  Event{Type: EventMessage},
+			setup:  func(v *Viewer) { v.StreamStreak = 5 },
+			wanted: []string{"first-message", "stream-streak"},
+		},
+		{
+			event:  Event{Type: EventFirstChat},
+			wanted: []string{"first-chatter"},
+		},
+	}
+	for tn, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			g := New()
+			v := NewViewer()
+			if tc.setup != nil {
+				tc.setup(v)
+			}
+			g.CheckAchievements(v, tc.event)
+			t.Logf("CheckAchievements(%v) => %v", tc.event.Type, v.Achievements)
+			if len(v.Achievements) != len(tc.wanted) {
+				t.Errorf("unexpected achievements: want: %v, got: %v", tc.wanted, v.Achievements)
+			}
+			for _, id := range tc.wanted {
+				if !v.HasAchievement(id) {
+					t.Errorf("missing achievement: %v", id)
+				}
+			}
+		})
+	}
+}
+
+func TestAttend(t *testing.T) {
+	v := NewViewer()
+	v.Attend("2024-01-01", "")
+	v.Attend("2024-01-01", "")
+	v.Attend("2024-01-03", "2024-01-01")
+	if v.StreamStreak != 2 {
+		t.Errorf("unexpected streak: want: 2, got: %v", v.StreamStreak)
+	}
+	v.Attend("2024-01-10", "2024-01-05")
+	if v.StreamStreak != 1 {
+		t.Errorf("unexpected streak after missing a stream: want: 1, got: %v", v.StreamStreak)
+	}
+}
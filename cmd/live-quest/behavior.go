@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Behavior is what the gopher is doing while its viewer is not playing.
+type Behavior string
+
+const (
+	BehaviorIdle      Behavior = "idle"
+	BehaviorWander    Behavior = "wander"
+	BehaviorFollow    Behavior = "follow"
+	BehaviorSit       Behavior = "sit"
+	BehaviorSleep     Behavior = "sleep"
+	BehaviorCelebrate Behavior = "celebrate"
+)
+
+var (
+	// SleepTimeout is the inactivity time before the gopher falls asleep.
+	SleepTimeout = 10 * time.Minute
+
+	// BehaviorFrames is how long each behavior lasts, in frames, before
+	// the gopher picks another one. Sleeping lasts until the viewer chats.
+	BehaviorFrames = map[Behavior]int{
+		BehaviorIdle:      200,
+		BehaviorWander:    300,
+		BehaviorFollow:    400,
+		BehaviorSit:       600,
+		BehaviorCelebrate: 120,
+	}
+
+	// FollowDistance is how close a gopher gets to the friend it follows.
+	FollowDistance = float64(gopherSize)
+)
+
+// SetBehavior changes the gopher behavior, resetting its duration.
+func (v *Viewer) SetBehavior(b Behavior) {
+	if v.Behavior != b {
+		log.D("behavior: %v is now %v", v.Name, b)
+	}
+	v.Behavior = b
+	v.behaviorFrames = BehaviorFrames[b]
+	switch b {
+	case BehaviorIdle, BehaviorCelebrate:
+		v.VelX = 0
+		v.Stop()
+	case BehaviorSit, BehaviorSleep:
+		// Holds the last frame of the animation
+		v.VelX = 0
+		v.Play(string(b))
+	case BehaviorWander:
+		if rand.Intn(2) == 0 {
+			v.VelX = WalkSpeed
+			v.WalkRight()
+		} else {
+			v.VelX = -WalkSpeed
+			v.WalkLeft()
+		}
+	}
+}
+
+// NextBehavior picks the behavior of a gopher that is awake, after the
+// current one is over.
+func NextBehavior(r *rand.Rand, hasFriend bool) Behavior {
+	n := r.Intn(10)
+	switch {
+	case n < 3:
+		return BehaviorIdle
+	case n < 6:
+		return BehaviorWander
+	case n < 8 && hasFriend:
+		return BehaviorFollow
+	case n < 8:
+		return BehaviorWander
+	}
+	return BehaviorSit
+}
+
+// WakeUp is called when the viewer chats.
+func (v *Viewer) WakeUp() {
+	if v.Behavior == BehaviorSleep {
+		v.SetBehavior(BehaviorIdle)
+		v.Jump()
+	}
+}
+
+// friend returns the friend to follow, if it is awake and on screen.
+func (g *Game) friend(v *Viewer) *Viewer {
+	f, ok := g.Viewers[v.Friend]
+	if !ok || f.Behavior == BehaviorSleep || !g.IsVisible(f) {
+		return nil
+	}
+	return f
+}
+
+// Think updates the behavior of the gopher.
+func (g *Game) Think(v *Viewer) {
+	if v.Behavior == "" {
+		v.SetBehavior(BehaviorIdle)
+	}
+	if v.Behavior != BehaviorSleep && time.Since(g.LastActivity(v)) > SleepTimeout {
+		v.SetBehavior(BehaviorSleep)
+	}
+	if g.UpdateEmote(v) {
+		return
+	}
+	// Spectators stay on the stands while the arena is on screen
+	if g.Seated(v) {
+		return
+	}
+
+	switch v.Behavior {
+	case BehaviorSleep:
+		v.sleepFrame++
+		return
+	case BehaviorWander:
+		// Turn around when reaching the screen borders
+		switch v.Wall {
+		case 1:
+			v.VelX = -TurnSpeed
+			v.WalkLeft()
+		case -1:
+			v.VelX = TurnSpeed
+			v.WalkRight()
+		}
+	case BehaviorFollow:
+		g.Follow(v)
+	case BehaviorCelebrate:
+		v.Jump()
+	}
+
+	v.behaviorFrames--
+	if v.behaviorFrames <= 0 {
+		v.SetBehavior(NextBehavior(behaviorRand, g.friend(v) != nil))
+	}
+}
+
+var behaviorRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// Follow walks towards the friend, stopping close to it.
+func (g *Game) Follow(v *Viewer) {
+	f := g.friend(v)
+	if f == nil {
+		v.SetBehavior(BehaviorIdle)
+		return
+	}
+	v.WalkTowards(f.PosX, FollowDistance)
+}
+
+// UpdateFriend makes the viewer follow the last viewer mentioned in the
+// chat.
+func (g *Game) UpdateFriend(v *Viewer, text string) {
+	for _, f := range strings.Fields(text) {
+		if !strings.HasPrefix(f, "@") {
+			continue
+		}
+		if other := g.FindViewerByName(f, v.Platform); other != nil && other.UID != v.UID {
+			v.Friend = other.UID
+		}
+	}
+}
+
+// DrawZzz draws the letters floating above a sleeping gopher.
+func (v *Viewer) DrawZzz(screen *ebiten.Image) {
+	if v.Behavior != BehaviorSleep {
+		return
+	}
+	for i, z := range []string{"z", "z", "Z"} {
+		t := float64((v.sleepFrame + i*40) % 120)
+		px := v.PosX + float64(gopherSize)*0.7 + 8*math.Sin(t/15)
+		py := v.PosY + float64(gopherSize)/2 - t/2
+		DrawColoredTextAt(screen, z, px, py, ColorDefend)
+	}
+}
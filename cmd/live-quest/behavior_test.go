@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestNextBehavior(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	seen := make(map[Behavior]int)
+	for i := 0; i < 1000; i++ {
+		seen[NextBehavior(r, false)]++
+	}
+	if seen[BehaviorFollow] > 0 {
+		t.Errorf("gophers without friends should not follow anyone")
+	}
+	for _, b := range []Behavior{BehaviorIdle, BehaviorWander, BehaviorSit} {
+		if seen[b] == 0 {
+			t.Errorf("behavior %v never picked", b)
+		}
+	}
+	if NextBehavior(rand.New(rand.NewSource(1)), true) == BehaviorSleep {
+		t.Errorf("awake gophers should not fall asleep at random")
+	}
+}
+
+func newTestViewers(names ...string) *Game {
+	g := New()
+	for _, name := range names {
+		v := NewViewer()
+		v.UID, v.Name = name, name
+		v.LastSeen = time.Now()
+		g.Viewers[v.UID] = v
+		g.UIDs = append(g.UIDs, v.UID)
+	}
+	return g
+}
+
+func TestSleep(t *testing.T) {
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+	g.Think(v)
+	if v.Behavior == BehaviorSleep {
+		t.Fatalf("active gopher should be awake")
+	}
+
+	v.LastSeen = time.Now().Add(-SleepTimeout - time.Second)
+	g.Think(v)
+	if v.Behavior != BehaviorSleep {
+		t.Fatalf("inactive gopher should be sleeping, got %v", v.Behavior)
+	}
+	if !g.IsVisible(v) {
+		t.Errorf("sleeping gopher should still be on screen")
+	}
+	v.Attend(g.CurrentStream())
+	v.LastSeen = time.Now().Add(-GopherDrawingTimeout - time.Minute)
+	if !g.IsVisible(v) {
+		t.Errorf("sleeping gopher should stay on screen during the stream")
+	}
+	v.LastStream = "2000-01-01"
+	if g.IsVisible(v) {
+		t.Errorf("gopher of a previous stream should not be on screen")
+	}
+	for i := 0; i < 1000; i++ {
+		g.Think(v)
+	}
+	if v.Behavior != BehaviorSleep {
+		t.Errorf("gopher should sleep until the viewer chats, got %v", v.Behavior)
+	}
+
+	v.LastSeen = time.Now()
+	v.WakeUp()
+	if v.Behavior != BehaviorIdle {
+		t.Errorf("gopher should wake up, got %v", v.Behavior)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	g := newTestViewers("a", "b")
+	a, b := g.Viewers["a"], g.Viewers["b"]
+	g.UpdateFriend(a, "oi @B, bora lutar?")
+	if a.Friend != "b" {
+		t.Fatalf("expected b as friend, got %q", a.Friend)
+	}
+
+	a.PosX, b.PosX = 0, 1000
+	a.SetBehavior(BehaviorFollow)
+	g.Think(a)
+	if a.VelX <= 0 {
+		t.Errorf("expected to walk towards the friend, got %v", a.VelX)
+	}
+	a.PosX = b.PosX - FollowDistance/2
+	g.Think(a)
+	if a.VelX != 0 {
+		t.Errorf("expected to stop close to the friend, got %v", a.VelX)
+	}
+
+	// Sleeping friends are not followed
+	b.SetBehavior(BehaviorSleep)
+	g.Think(a)
+	if a.Behavior == BehaviorFollow {
+		t.Errorf("should not follow a sleeping friend")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/message"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Bench is the number of fake viewers of the benchmark scene; zero plays
+// the stream as usual.
+var Bench int
+
+// BenchReportDelay is how often the frame times are logged, in frames.
+var BenchReportDelay = 60 * 5
+
+var benchBehaviors = []Behavior{BehaviorIdle, BehaviorWander, BehaviorSit, BehaviorCelebrate}
+
+// SpawnBench adds n fake viewers with random colors, outfits, positions
+// and behaviors, to measure how the game performs on busy streams.
+func (g *Game) SpawnBench(n int, r *rand.Rand) {
+	for i := range n {
+		v := NewViewer()
+		v.UID = fmt.Sprintf("bench-%04d", i)
+		v.Name = v.UID
+		v.Platform = message.PlatformYoutube
+		if i%2 == 1 {
+			v.Platform = message.PlatformTwitch
+		}
+		v.XP = r.Intn(5000)
+		v.SpriteColor = RandomColor(r)
+		if r.Intn(3) == 0 {
+			v.SpriteColor2 = RandomColor(r)
+		}
+		for _, c := range Cosmetics {
+			if r.Intn(4) == 0 {
+				v.Wear(c.Slot, c.Name)
+			}
+		}
+		v.PosX = float64(r.Intn(Width - gopherSize))
+		v.PosY = float64(r.Intn(Height / 2))
+		v.SetBehavior(benchBehaviors[r.Intn(len(benchBehaviors))])
+		v.LastSeen = time.Now()
+
+		g.Viewers[v.UID] = v
+		g.UIDs = append(g.UIDs, v.UID)
+	}
+	log.I("bench: spawned %d viewers", n)
+}
+
+// frameStats measures the time between frames.
+type frameStats struct {
+	last   time.Time
+	frames int
+	total  time.Duration
+	worst  time.Duration
+
+	// avg and max are the results of the last report.
+	avg, max time.Duration
+}
+
+// Tick records a frame drawn now, and reports the average and the worst
+// frame time every BenchReportDelay frames.
+func (s *frameStats) Tick(now time.Time) {
+	if !s.last.IsZero() {
+		d := now.Sub(s.last)
+		s.frames++
+		s.total += d
+		s.worst = max(s.worst, d)
+	}
+	s.last = now
+	if s.frames < BenchReportDelay {
+		return
+	}
+	s.avg, s.max = s.total/time.Duration(s.frames), s.worst
+	s.frames, s.total, s.worst = 0, 0, 0
+	log.I("bench: frame time avg %v, max %v, %.1f FPS", s.avg, s.max, ebiten.ActualFPS())
+}
+
+// DrawBenchStats shows the frame rate and the frame times of the last
+// report on the top left corner.
+func (g *Game) DrawBenchStats(screen *ebiten.Image) {
+	g.frameStats.Tick(time.Now())
+	txt := fmt.Sprintf("%d gophers - %.1f FPS / %.1f TPS\nframe avg %v max %v\n%d textures",
+		len(g.UIDs), ebiten.ActualFPS(), ebiten.ActualTPS(),
+		g.frameStats.avg.Round(time.Microsecond), g.frameStats.max.Round(time.Microsecond),
+		assets.Textures)
+	DrawTextAt(screen, txt, 12, 12)
+}
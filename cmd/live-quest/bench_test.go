@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSpawnBench(t *testing.T) {
+	g := New()
+	g.SpawnBench(100, rand.New(rand.NewSource(1)))
+	if len(g.UIDs) != 100 || len(g.Viewers) != 100 {
+		t.Fatalf("expected 100 viewers, got %d", len(g.UIDs))
+	}
+	for _, uid := range g.UIDs {
+		v := g.Viewers[uid]
+		if !g.IsVisible(v) {
+			t.Errorf("%v: expected a visible gopher", uid)
+		}
+		if v.PosX < 0 || v.PosX > float64(Width-gopherSize) {
+			t.Errorf("%v: off screen at %v", uid, v.PosX)
+		}
+	}
+}
+
+func TestFrameStats(t *testing.T) {
+	defer func(d int) { BenchReportDelay = d }(BenchReportDelay)
+	BenchReportDelay = 3
+
+	var s frameStats
+	now := time.Now()
+	for _, d := range []time.Duration{0, 10, 20, 30, 10} {
+		now = now.Add(d * time.Millisecond)
+		s.Tick(now)
+	}
+	if s.avg != 20*time.Millisecond || s.max != 30*time.Millisecond {
+		t.Errorf("expected avg 20ms and max 30ms, got %v and %v", s.avg, s.max)
+	}
+	if s.frames != 1 || s.worst != 10*time.Millisecond {
+		t.Errorf("expected a new window after the report, got %d frames", s.frames)
+	}
+}
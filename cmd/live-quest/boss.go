@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/message"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+var (
+	// BossHP is the default HP of the boss spawned with the hotkey.
+	BossHP = 2000
+
+	// BossDuration is how long the chat has to defeat the boss before it
+	// escapes.
+	BossDuration = 3 * time.Minute
+
+	// BossAttackCooldown is the minimum time between two attacks from the
+	// same viewer.
+	BossAttackCooldown = 2 * time.Second
+
+	// BossCounterInterval is the time between boss counterattacks.
+	BossCounterInterval = 8 * time.Second
+
+	// BossRewardXP is shared among the participants by damage dealt; only
+	// half of it is shared if the boss escapes.
+	BossRewardXP = 1000
+	BossMVPBonus = 100
+
+	BossScale = 3.0
+	BossColor = color.RGBA{0xd0, 0x30, 0x30, 0xff}
+
+	// BossKey spawns a boss with the default HP.
+	BossKey = ebiten.KeyB
+)
+
+// Boss is a giant bug that the whole chat attacks together with !attack.
+type Boss struct {
+	Name  string `json:"name"`
+	HP    int    `json:"hp"`
+	MaxHP int    `json:"maxHP"`
+
+	// Damage is the total damage dealt by each participant.
+	Damage     map[string]int       `json:"damage"`
+	LastAttack map[string]time.Time `json:"lastAttack"`
+
+	Expires     time.Time `json:"expires"`
+	NextCounter time.Time `json:"nextCounter"`
+}
+
+func NewBoss(hp int) *Boss {
+	now := time.Now()
+	return &Boss{
+		Name:        "Bug Gigante",
+		HP:          hp,
+		MaxHP:       hp,
+		Damage:      make(map[string]int),
+		LastAttack:  make(map[string]time.Time),
+		Expires:     now.Add(BossDuration),
+		NextCounter: now.Add(BossCounterInterval),
+	}
+}
+
+// Size returns the size of the boss sprite on screen.
+func (b *Boss) Size() float64 {
+	return BossScale * float64(gopherSize)
+}
+
+// Pos returns the position of the boss, at the center of the arena.
+func (b *Boss) Pos() (px, py float64) {
+	arena := float64(Width) - 400
+	return (arena - b.Size()) / 2, float64(Height) - b.Size()
+}
+
+// BossDamage returns the damage of an attack by a viewer of the given
+// level.
+func BossDamage(level int) int {
+	return 5 + 2*level + rand.Intn(5)
+}
+
+// BossRewards shares the XP pool among the participants, proportional to
+// the damage dealt.
+func BossRewards(damage map[string]int, pool int) map[string]int {
+	total := 0
+	for _, d := range damage {
+		total += d
+	}
+	rewards := make(map[string]int, len(damage))
+	if total == 0 {
+		return rewards
+	}
+	for uid, d := range damage {
+		rewards[uid] = pool * d / total
+	}
+	return rewards
+}
+
+// MVP returns the participant that dealt the most damage.
+func (b *Boss) MVP() (mvp string) {
+	uids := make([]string, 0, len(b.Damage))
+	for uid := range b.Damage {
+		uids = append(uids, uid)
+	}
+	// Ties go to the first in alphabetical order, to be deterministic
+	sort.Strings(uids)
+	for _, uid := range uids {
+		if mvp == "" || b.Damage[uid] > b.Damage[mvp] {
+			mvp = uid
+		}
+	}
+	return mvp
+}
+
+// SpawnBoss starts the boss raid.
+func (g *Game) SpawnBoss(hp int) {
+	if g.Boss != nil {
+		log.D("boss: %v is already here", g.Boss.Name)
+		return
+	}
+	g.Boss = NewBoss(hp)
+	log.I("boss: spawned with %d HP", hp)
+	g.Broadcast(fmt.Sprintf("Um %s apareceu com %d HP! Usem !attack para derrotá-lo em %v",
+		g.Boss.Name, hp, BossDuration))
+}
+
+// BossCommand handles the moderator command: !boss [hp]
+func (g *Game) BossCommand(m message.Message, v *Viewer) {
+	if !m.Moderator {
+		log.D("boss: %v is not a moderator", v.Name)
+		return
+	}
+	hp := BossHP
+	fields := strings.Fields(m.Text)
+	for i, f := range fields {
+		if f == "!boss" && i+1 < len(fields) {
+			n, err := strconv.Atoi(fields[i+1])
+			if err != nil || n <= 0 {
+				g.SendMessage(v.Platform, fmt.Sprintf("@%s: HP inválido: %v", v.Name, fields[i+1]))
+				return
+			}
+			hp = n
+		}
+	}
+	g.SpawnBoss(hp)
+}
+
+// AttackBoss registers the viewer attack on the boss.
+func (g *Game) AttackBoss(v *Viewer) {
+	b := g.Boss
+	if b == nil || b.HP <= 0 {
+		return
+	}
+	if v.HP <= 0 {
+		log.D("boss: %v is knocked out", v.Name)
+		return
+	}
+	if last, ok := b.LastAttack[v.UID]; ok && time.Since(last) < BossAttackCooldown {
+		return
+	}
+	dmg := min(BossDamage(v.Level()), b.HP)
+	b.LastAttack[v.UID] = time.Now()
+	b.Damage[v.UID] += dmg
+	b.HP -= dmg
+	log.D("boss: %v hit for %d, %d HP left", v.Name, dmg, b.HP)
+
+	px, py := b.Pos()
+	txt := fmt.Sprintf("-%d", dmg)
+	g.AddFloatingTextAt(px+rand.Float64()*b.Size(), py+b.Size()/3, txt, ColorDamage)
+	v.Jump()
+	if b.HP <= 0 {
+		g.EndBossRaid(true)
+	}
+}
+
+// BossCounterattack hits a random participant that is still standing.
+func (g *Game) BossCounterattack() {
+	b := g.Boss
+	targets := make([]*Viewer, 0, len(b.Damage))
+	for uid := range b.Damage {
+		v, ok := g.Viewers[uid]
+		if ok && v.HP > 0 && !g.FightState.IsFighting(uid) {
+			targets = append(targets, v)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].UID < targets[j].UID })
+	v := targets[rand.Intn(len(targets))]
+	dmg := min(15+rand.Intn(16), v.HP)
+	v.Damage(dmg)
+	px, _ := b.Pos()
+	v.KnockbackFrom(px + b.Size()/2)
+	log.D("boss: counterattack on %v for %d", v.Name, dmg)
+	g.AddFloatingText(v, fmt.Sprintf("-%d", dmg), ColorDamage)
+	if v.HP <= 0 {
+		g.AddFloatingText(v, "K.O.", ColorMiss)
+	}
+}
+
+func (g *Game) UpdateBoss() {
+	if inpututil.IsKeyJustPressed(BossKey) {
+		g.SpawnBoss(BossHP)
+	}
+	b := g.Boss
+	if b == nil {
+		return
+	}
+	now := time.Now()
+	if now.After(b.Expires) {
+		g.EndBossRaid(false)
+		return
+	}
+	if now.After(b.NextCounter) {
+		b.NextCounter = now.Add(BossCounterInterval)
+		g.BossCounterattack()
+	}
+}
+
+// EndBossRaid shares the XP among the participants and restores their HP.
+func (g *Game) EndBossRaid(defeated bool) {
+	b := g.Boss
+	g.Boss = nil
+	pool := BossRewardXP
+	if !defeated {
+		pool /= 2
+	}
+	for uid, xp := range BossRewards(b.Damage, pool) {
+		v, ok := g.Viewers[uid]
+		if !ok {
+			continue
+		}
+		v.HP = 100
+		v.IncXP(xp)
+	}
+
+	msg := fmt.Sprintf("O %s fugiu! %d XP divididos entre %d participantes",
+		b.Name, pool, len(b.Damage))
+	if defeated {
+		msg = fmt.Sprintf("O %s foi derrotado! %d XP divididos entre %d participantes",
+			b.Name, pool, len(b.Damage))
+	}
+	if v, ok := g.Viewers[b.MVP()]; ok {
+		v.IncXP(BossMVPBonus)
+		v.Unlock(BossMVPCosmetic)
+		v.Celebrate()
+		msg += fmt.Sprintf("; MVP: @%s com %d de dano (+%d XP e o visual %s)",
+			v.Name, b.Damage[v.UID], BossMVPBonus, BossMVPCosmetic.Name)
+	}
+	log.I("boss: raid is over: %v", msg)
+	g.Broadcast(msg)
+}
+
+// DrawBoss draws the giant bug with its HP bar and the time left.
+func (g *Game) DrawBoss(screen *ebiten.Image) {
+	b := g.Boss
+	if b == nil {
+		return
+	}
+	a := assets.Bundles["gopher"]["standing"]
+	frame := g.BossFrame()
+	px, py := b.Pos()
+
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(BossScale, BossScale)
+	opts.GeoM.Translate(px, py)
+	opts.ColorScale.ScaleWithColor(BossColor)
+	screen.DrawImage(a.Frames[frame], opts)
+	if frame < len(a.Skins) {
+		opts.ColorScale.Reset()
+		screen.DrawImage(a.Skins[frame], opts)
+	}
+
+	// HP bar
+	barW, barH := b.Size(), 16.0
+	barOpts := &ebiten.DrawImageOptions{}
+	barOpts.GeoM.Scale(barW, barH)
+	barOpts.GeoM.Translate(px, py-barH)
+	barOpts.ColorScale.ScaleWithColor(color.RGBA{0x40, 0x40, 0x40, 0xff})
+	screen.DrawImage(pixel, barOpts)
+	barOpts.GeoM.Reset()
+	barOpts.GeoM.Scale(barW*float64(b.HP)/float64(b.MaxHP), barH)
+	barOpts.GeoM.Translate(px, py-barH)
+	barOpts.ColorScale.Reset()
+	barOpts.ColorScale.ScaleWithColor(BossColor)
+	screen.DrawImage(pixel, barOpts)
+
+	title := b.Title()
+	DrawTextAt(screen, title, px+(b.Size()-TextWidth(title))/2, py-barH-32)
+}
+
+// BossFrame returns the frame of the boss animation.
+func (g *Game) BossFrame() int {
+	return (g.Count / 6) % len(assets.Bundles["gopher"]["standing"].Frames)
+}
+
+// Title returns the name, the HP and the remaining time of the boss.
+func (b *Boss) Title() string {
+	remaining := max(0, int(time.Until(b.Expires).Seconds()))
+	return fmt.Sprintf("%s %d/%d - %ds", b.Name, b.HP, b.MaxHP, remaining)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBossRewards(t *testing.T) {
+	for tn, tc := range []struct {
+		damage map[string]int
+		pool   int
+		expect map[string]int
+	}{
+		{
+			damage: map[string]int{"a": 300, "b": 100},
+			pool:   1000,
+			expect: map[string]int{"a": 750, "b": 250},
+		},
+		{
+			damage: map[string]int{"a": 1, "b": 1, "c": 1},
+			pool:   100,
+			expect: map[string]int{"a": 33, "b": 33, "c": 33},
+		},
+		{
+			damage: map[string]int{},
+			pool:   1000,
+			expect: map[string]int{},
+		},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			got := BossRewards(tc.damage, tc.pool)
+			if fmt.Sprint(got) != fmt.Sprint(tc.expect) {
+				t.Errorf("expected %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestBossMVP(t *testing.T) {
+	b := NewBoss(100)
+	if mvp := b.MVP(); mvp != "" {
+		t.Errorf("unexpected MVP without participants: %v", mvp)
+	}
+	b.Damage = map[string]int{"c": 10, "b": 30, "a": 30}
+	if mvp := b.MVP(); mvp != "a" {
+		t.Errorf("expected a as MVP, got %v", mvp)
+	}
+}
+
+func TestAttackBoss(t *testing.T) {
+	g := New()
+	v := NewViewer()
+	v.UID, v.Name = "a", "a"
+	g.Viewers[v.UID] = v
+	g.UIDs = append(g.UIDs, v.UID)
+
+	g.SpawnBoss(10)
+	g.AttackBoss(v)
+	if g.Boss == nil || g.Boss.Damage["a"] != 10-g.Boss.HP {
+		t.Fatalf("unexpected boss state: %+v", g.Boss)
+	}
+	// Attacks during the cooldown are ignored
+	hp := g.Boss.HP
+	g.AttackBoss(v)
+	if g.Boss.HP != hp {
+		t.Errorf("attack ignored the cooldown: %d HP", g.Boss.HP)
+	}
+
+	// BossDamage is at least 5, so two attacks defeat the boss
+	delete(g.Boss.LastAttack, v.UID)
+	g.AttackBoss(v)
+	if g.Boss != nil {
+		t.Errorf("boss should be defeated, has %d HP", g.Boss.HP)
+	}
+	if v.XP != BossRewardXP+BossMVPBonus {
+		t.Errorf("unexpected XP reward: %d", v.XP)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"image/color"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var (
+	// BubbleFrames is how long a speech bubble stays on screen.
+	BubbleFrames = 300
+
+	// BubbleMaxChars and BubbleMaxLines limit the bubble size; longer
+	// messages are truncated.
+	BubbleMaxChars = 24
+	BubbleMaxLines = 3
+
+	ColorBubble       = color.RGBA{0xff, 0xff, 0xff, 0xf0}
+	ColorBubbleBorder = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	ColorBubbleText   = color.RGBA{0x20, 0x20, 0x20, 0xff}
+
+	bubblePadding    = 8.0
+	bubbleLineHeight = 24.0
+)
+
+// emoteFallback replaces emojis that the bitmap font can't draw.
+var emoteFallback = map[rune]string{
+	'😀': ":D", '😃': ":D", '😄': ":D", '😁': ":D", '😆': "XD",
+	'😂': "XD", '🤣': "XD", '🙂': ":)", '😊': ":)", '☺': ":)",
+	'😉': ";)", '😛': ":P", '😜': ";P", '😮': ":O", '😢': ":'(",
+	'😭': ":'(", '🙁': ":(", '☹': ":(", '😡': ">:(", '❤': "<3",
+	'♥': "<3", '💜': "<3", '💙': "<3", '👍': "(y)", '👎': "(n)",
+	'👋': "o/", '🎉': "\\o/", '🔥': "(fogo)", '🚀': "(foguete)",
+}
+
+// SpeechBubble is the latest message of the viewer, shown above the
+// gopher.
+type SpeechBubble struct {
+	Lines  []string
+	Frames int
+}
+
+// IsCommand returns true for messages that are game commands, which are
+// not shown in bubbles.
+func IsCommand(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "!")
+}
+
+// SanitizeText replaces the characters that can't be drawn by their text
+// fallbacks.
+func SanitizeText(text string, hasGlyph func(r rune) bool) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\uFE0F' || r == '\u200D':
+			// Emoji variation selector and joiner
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case hasGlyph(r):
+			b.WriteRune(r)
+		case emoteFallback[r] != "":
+			b.WriteString(emoteFallback[r])
+		default:
+			b.WriteRune('?')
+		}
+	}
+	return b.String()
+}
+
+func hasGlyph(r rune) bool {
+	_, ok := face.GlyphAdvance(r)
+	return ok
+}
+
+// WrapText breaks the text in lines of up to width characters, splitting
+// words longer than a line. Text that does not fit in maxLines ends with
+// an ellipsis. Lines are at least as wide as the ellipsis.
+func WrapText(text string, width, maxLines int) (lines []string) {
+	width, maxLines = max(width, len("...")), max(maxLines, 1)
+	curr := ""
+	for _, word := range strings.Fields(text) {
+		for utf8.RuneCountInString(word) > width {
+			if curr != "" {
+				lines = append(lines, curr)
+				curr = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case curr == "":
+			curr = word
+		case utf8.RuneCountInString(curr)+1+utf8.RuneCountInString(word) <= width:
+			curr += " " + word
+		default:
+			lines = append(lines, curr)
+			curr = word
+		}
+	}
+	if curr != "" {
+		lines = append(lines, curr)
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		if len(last) > width-3 {
+			last = last[:width-3]
+		}
+		lines[maxLines-1] = string(last) + "..."
+	}
+	return lines
+}
+
+// Say shows the message in a speech bubble above the viewer gopher.
+func (g *Game) Say(v *Viewer, text string) {
+	if IsCommand(text) {
+		return
+	}
+	lines := WrapText(SanitizeText(text, hasGlyph), BubbleMaxChars, BubbleMaxLines)
+	if len(lines) == 0 {
+		return
+	}
+	v.bubble = SpeechBubble{Lines: lines, Frames: BubbleFrames}
+}
+
+func (g *Game) UpdateBubbles() {
+	for _, uid := range g.UIDs {
+		if v := g.Viewers[uid]; v.bubble.Frames > 0 {
+			v.bubble.Frames--
+		}
+	}
+}
+
+// bubbleBox is the area of a bubble on screen.
+type bubbleBox struct {
+	X, Y, W, H float64
+}
+
+func (b bubbleBox) overlaps(other bubbleBox) bool {
+	return b.X < other.X+other.W && b.X+b.W > other.X &&
+		b.Y < other.Y+other.H && b.Y+b.H > other.Y
+}
+
+// LayoutBubbles moves the bubbles up until they don't overlap the ones
+// placed before. Bubbles are placed from the lowest to the highest.
+func LayoutBubbles(boxes []bubbleBox) {
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return boxes[order[i]].Y > boxes[order[j]].Y
+	})
+	for n, i := range order {
+		for moved := true; moved; {
+			moved = false
+			for _, j := range order[:n] {
+				if boxes[i].overlaps(boxes[j]) {
+					boxes[i].Y = boxes[j].Y - boxes[i].H - 4
+					moved = true
+				}
+			}
+		}
+	}
+}
+
+func (v *Viewer) bubbleBox() bubbleBox {
+	maxWidth := 0.0
+	for _, l := range v.bubble.Lines {
+		maxWidth = max(maxWidth, TextWidth(l))
+	}
+	w := maxWidth + 2*bubblePadding
+	h := float64(len(v.bubble.Lines))*bubbleLineHeight + 2*bubblePadding
+	// Above the name tag and the status bars
+	return bubbleBox{
+		X: v.PosX + (float64(gopherSize)-w)/2,
+		Y: v.PosY - 56 - h,
+		W: w,
+		H: h,
+	}
+}
+
+// DrawBubbles draws the speech bubbles of the gophers on screen.
+func (g *Game) DrawBubbles(screen *ebiten.Image) {
+	speakers := make([]*Viewer, 0)
+	for _, uid := range g.UIDs {
+		v := g.Viewers[uid]
+		if v.bubble.Frames > 0 && g.IsVisible(v) {
+			speakers = append(speakers, v)
+		}
+	}
+	boxes := make([]bubbleBox, len(speakers))
+	for i, v := range speakers {
+		boxes[i] = v.bubbleBox()
+	}
+	LayoutBubbles(boxes)
+
+	opts := &ebiten.DrawImageOptions{}
+	fill := func(px, py, w, h float64, clr color.Color) {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(w, h)
+		opts.GeoM.Translate(px, py)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(clr)
+		screen.DrawImage(pixel, opts)
+	}
+	for i, v := range speakers {
+		b := boxes[i]
+		fill(b.X-2, b.Y-2, b.W+4, b.H+4, ColorBubbleBorder)
+		fill(b.X, b.Y, b.W, b.H, ColorBubble)
+
+		// Tail pointing to the gopher, stretched when the bubble moved up
+		tx := v.PosX + float64(gopherSize)/2
+		top, bottom := b.Y+b.H, v.PosY-56
+		for w := 12.0; top < bottom+8; top += 4 {
+			fill(tx-w/2, top, w, 4, ColorBubble)
+			w = max(2, w-2)
+		}
+
+		py := b.Y + bubblePadding
+		for _, l := range v.bubble.Lines {
+			DrawColoredTextAt(screen, l, b.X+bubblePadding, py, ColorBubbleText)
+			py += bubbleLineHeight
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	for tn, tc := range []struct {
+		text   string
+		width  int
+		lines  int
+		expect []string
+	}{
+		{text: "olá mundo", width: 24, lines: 3, expect: []string{"olá mundo"}},
+		{text: "  ", width: 24, lines: 3, expect: nil},
+		{text: "aaa bbb ccc", width: 7, lines: 3, expect: []string{"aaa bbb", "ccc"}},
+		{text: "abcdefghij", width: 4, lines: 3, expect: []string{"abcd", "efgh", "ij"}},
+		{text: "a b c d e f", width: 3, lines: 2, expect: []string{"a b", "..."}},
+		{text: "aaaa bbbb cccc", width: 6, lines: 2, expect: []string{"aaaa", "bbb..."}},
+		{text: "abcdefgh", width: 1, lines: 2, expect: []string{"abc", "..."}},
+		{text: "abc def", width: 0, lines: 0, expect: []string{"..."}},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			got := WrapText(tc.text, tc.width, tc.lines)
+			if strings.Join(got, "|") != strings.Join(tc.expect, "|") {
+				t.Errorf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	ascii := func(r rune) bool { return r < 128 }
+	for tn, tc := range []struct {
+		text   string
+		expect string
+	}{
+		{text: "oi pessoal", expect: "oi pessoal"},
+		{text: "valeu 👍", expect: "valeu (y)"},
+		{text: "te amo ❤️", expect: "te amo <3"},
+		{text: "hmm 🦫", expect: "hmm ?"},
+		{text: "a\tb\nc", expect: "a b c"},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if got := SanitizeText(tc.text, ascii); got != tc.expect {
+				t.Errorf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestLayoutBubbles(t *testing.T) {
+	boxes := []bubbleBox{
+		{X: 0, Y: 100, W: 100, H: 40},
+		{X: 50, Y: 100, W: 100, H: 40},
+		{X: 500, Y: 100, W: 100, H: 40},
+	}
+	LayoutBubbles(boxes)
+	for i := range boxes {
+		for j := range boxes {
+			if i != j && boxes[i].overlaps(boxes[j]) {
+				t.Errorf("bubbles %d and %d overlap: %v, %v", i, j, boxes[i], boxes[j])
+			}
+		}
+	}
+	if boxes[0].Y != 100 || boxes[2].Y != 100 {
+		t.Errorf("bubbles without overlap should not move: %v", boxes)
+	}
+}
+
+func TestSayIgnoresCommands(t *testing.T) {
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+	g.Say(v, "!fight")
+	if v.bubble.Frames > 0 {
+		t.Errorf("commands should not be shown")
+	}
+	g.Say(v, "bom dia")
+	if v.bubble.Frames != BubbleFrames || v.bubble.Lines[0] != "bom dia" {
+		t.Errorf("unexpected bubble: %+v", v.bubble)
+	}
+}
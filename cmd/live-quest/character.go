@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/physics"
+)
+
+// DefaultCharacter is the bundle of the viewers that did not choose a
+// character.
+const DefaultCharacter = "gopher"
+
+// AssetPacks are the directories of the asset packs loaded on startup,
+// separated by commas.
+var AssetPacks string
+
+// LoadAssetPacks loads the asset packs, skipping the invalid ones.
+func LoadAssetPacks(dirs string) {
+	for _, dir := range strings.Split(dirs, ",") {
+		if dir = strings.TrimSpace(dir); dir == "" {
+			continue
+		}
+		p, err := assets.LoadPackDir(dir)
+		if err != nil {
+			log.E("assets: unable to load the pack in %v:\n%v", dir, err)
+			continue
+		}
+		assets.AddPack(p)
+	}
+}
+
+// CharacterName returns the character of the viewer, or the default one
+// if its pack is not loaded.
+func (v *Viewer) CharacterName() string {
+	if v.Character == "" || !slices.Contains(assets.Characters, v.Character) {
+		return DefaultCharacter
+	}
+	return v.Character
+}
+
+// Bundle returns the animations of the viewer character.
+func (v *Viewer) Bundle() assets.Bundle {
+	return assets.Bundles[v.CharacterName()]
+}
+
+// CharacterHitbox converts the hitbox of the character to the body
+// coordinates, where the anchor is at the bottom center of the gopher.
+func (v *Viewer) CharacterHitbox() physics.Rect {
+	a, ok := v.Bundle()["standing"]
+	if !ok || a.Hitbox.Empty() {
+		return gopherHitbox
+	}
+	dx, dy := anchorOffset(a)
+	return physics.Rect{
+		X: float64(a.Hitbox.Min.X) + dx,
+		Y: float64(a.Hitbox.Min.Y) + dy,
+		W: float64(a.Hitbox.Dx()),
+		H: float64(a.Hitbox.Dy()),
+	}
+}
+
+// anchorOffset is the translation of the frames that places the anchor of
+// the animation at the bottom center of the gopher.
+func anchorOffset(a *assets.Animation) (dx, dy float64) {
+	return float64(gopherSize/2 - a.Anchor.X), float64(gopherSize - a.Anchor.Y)
+}
+
+// CharacterCommand handles !character <name>, and lists the characters
+// when the name is missing.
+func (g *Game) CharacterCommand(v *Viewer, text string) {
+	var name string
+	parts := strings.Fields(strings.ToLower(text))
+	for i, p := range parts {
+		if p == "!character" && i+1 < len(parts) {
+			name = parts[i+1]
+		}
+	}
+	if !slices.Contains(assets.Characters, name) {
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: personagens disponíveis: %s",
+			v.Name, strings.Join(assets.Characters, ", ")))
+		return
+	}
+	// Swapping the character restarts the animation, which would break
+	// the fight stance and the team battle
+	if g.FightState.IsFighting(v.UID) || g.TeamBattle != nil {
+		log.D("character: %v is busy, ignoring !character", v.Name)
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: troque de personagem depois da batalha", v.Name))
+		return
+	}
+	log.D("character: %v is now a %v", v.Name, name)
+	v.Character = name
+	v.Play("standing")
+	g.SendMessage(v.Platform, fmt.Sprintf("@%s: agora você joga com o %s", v.Name, name))
+}
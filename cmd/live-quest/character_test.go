@@ -0,0 +1,62 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"testing"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/physics"
+)
+
+// addTestCharacter registers a character with a 64x96 standing frame,
+// anchored at its feet.
+func addTestCharacter(t *testing.T, name string) {
+	characters := assets.Characters
+	assets.Characters = append(slices.Clone(characters), name)
+	assets.Bundles[name] = assets.Bundle{"standing": &assets.Animation{
+		Anchor: image.Pt(32, 90),
+		Hitbox: image.Rect(8, 16, 56, 90),
+	}}
+	t.Cleanup(func() {
+		assets.Characters = characters
+		delete(assets.Bundles, name)
+	})
+}
+
+func TestCharacterCommand(t *testing.T) {
+	addTestCharacter(t, "robot")
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+
+	g.CharacterCommand(v, "!character alien")
+	if v.CharacterName() != DefaultCharacter {
+		t.Fatalf("unknown characters should be ignored, got %v", v.CharacterName())
+	}
+	g.CharacterCommand(v, "!character Robot")
+	if v.CharacterName() != "robot" {
+		t.Fatalf("expected a robot, got %v", v.CharacterName())
+	}
+	expected := physics.Rect{X: 40, Y: 54, W: 48, H: 74}
+	if hb := v.CharacterHitbox(); hb != expected {
+		t.Errorf("expected the hitbox %v, got %v", expected, hb)
+	}
+
+	// The pack was not loaded in this stream
+	v.Character = "alien"
+	if v.CharacterName() != DefaultCharacter || v.CharacterHitbox() != gopherHitbox {
+		t.Errorf("expected the gopher, got %v", v.CharacterName())
+	}
+}
+
+func TestCharacterCommandBusy(t *testing.T) {
+	addTestCharacter(t, "robot")
+	g := newTestViewers("a", "b")
+	a := g.Viewers["a"]
+	g.StartFight(a, g.Viewers["b"], 0)
+	g.CharacterCommand(a, "!character robot")
+	if a.CharacterName() != DefaultCharacter || a.Animation != "fight_standing" {
+		t.Errorf("expected the fighter to keep the gopher and the stance, got %v in %v",
+			a.CharacterName(), a.Animation)
+	}
+}
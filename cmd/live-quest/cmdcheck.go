@@ -54,6 +54,16 @@ type Challenge struct {
 	Reward int
 }
 
+// FindChallenge returns the challenge with the code.
+func FindChallenge(code string) (Challenge, bool) {
+	for _, ch := range Challenges {
+		if ch.Code == code {
+			return ch, true
+		}
+	}
+	return Challenge{}, false
+}
+
 type CheckResult struct {
 	OK     bool
 	Result string
@@ -225,15 +235,7 @@ func (g *Game) ChallengeQueue() {
 			continue
 		}
 		challengeCode := cmdArgs[1]
-		var challenge Challenge
-		ok := false
-		for _, ch := range Challenges {
-			if ch.Code == challengeCode {
-				challenge = ch
-				ok = true
-				break
-			}
-		}
+		challenge, ok := FindChallenge(challengeCode)
 		if !ok {
 			g.SendMessage(m.Platform,
 				fmt.Sprintf("@%s: código do desafio não reconhecido: %v",
@@ -269,6 +271,7 @@ func (g *Game) ChallengeQueue() {
 			v.Jump()
 			v.IncXP(challenge.Reward)
 			v.MarkCompleted(challengeCode)
+			Emit(Event{Type: EventChallengeSolved, UID: v.UID})
 		} else {
 			g.SendMessage(m.Platform, "@"+m.Author+": que pena, resposta errada; tente novamente!")
 			log.W("game: %v provided wrong anser: %v", v.Name, cr.Result)
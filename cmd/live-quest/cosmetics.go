@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	SlotOutfit    = "outfit"
+	SlotEyes      = "eyes"
+	SlotAccessory = "accessory"
+	SlotHat       = "hat"
+)
+
+// CosmeticSlots are the layers a gopher can wear, in drawing order. The
+// outfit is drawn below the skin, so paws and feet stay visible.
+var CosmeticSlots = []string{SlotOutfit, SlotEyes, SlotAccessory, SlotHat}
+
+// Cosmetic is a sprite layer drawn over the gopher, with one image for
+// each frame of its animations.
+type Cosmetic struct {
+	Slot string
+	Name string
+
+	// Set is the cosmetic unlock that grants it, from levels.json or from
+	// game events.
+	Set string
+
+	// Achievement also grants the cosmetic, if set.
+	Achievement string
+}
+
+func (c Cosmetic) ID() string {
+	return c.Slot + "/" + c.Name
+}
+
+var Cosmetics = []Cosmetic{
+	{Slot: SlotHat, Name: "mario", Set: "mario"},
+	{Slot: SlotOutfit, Name: "mario", Set: "mario"},
+	{Slot: SlotAccessory, Name: "mario", Set: "mario"},
+	{Slot: SlotHat, Name: "sonic", Set: "sonic"},
+	{Slot: SlotOutfit, Name: "sonic", Set: "sonic"},
+	{Slot: SlotEyes, Name: "sonic", Set: "sonic"},
+	{Slot: SlotHat, Name: "ninja", Set: "ninja"},
+	{Slot: SlotOutfit, Name: "ninja", Set: "ninja"},
+	{Slot: SlotAccessory, Name: "ninja", Set: "ninja"},
+	{Slot: SlotHat, Name: "crown", Achievement: "tournament-champion"},
+	{Slot: SlotEyes, Name: "angry", Achievement: "fighter"},
+	{Slot: SlotHat, Name: "viking", Set: "viking"},
+}
+
+// BossMVPCosmetic is granted to the MVP of a boss raid.
+var BossMVPCosmetic = Unlock{Kind: UnlockCosmetic, Name: "viking"}
+
+func FindCosmetic(slot, name string) (Cosmetic, bool) {
+	for _, c := range Cosmetics {
+		if c.Slot == slot && c.Name == name {
+			return c, true
+		}
+	}
+	return Cosmetic{}, false
+}
+
+// HasCosmetic returns true if the viewer unlocked the cosmetic.
+func (v *Viewer) HasCosmetic(c Cosmetic) bool {
+	if c.Set != "" && v.HasUnlocked(Unlock{UnlockCosmetic, c.Set}) {
+		return true
+	}
+	return c.Achievement != "" && v.HasAchievement(c.Achievement)
+}
+
+// AvailableCosmetics lists the cosmetics unlocked by the viewer.
+func (v *Viewer) AvailableCosmetics() (available []Cosmetic) {
+	for _, c := range Cosmetics {
+		if v.HasCosmetic(c) {
+			available = append(available, c)
+		}
+	}
+	return available
+}
+
+// Wear equips the cosmetic in its slot; an empty name takes the slot off.
+func (v *Viewer) Wear(slot, name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if name == "" {
+		delete(v.Outfit, slot)
+		return
+	}
+	v.Outfit[slot] = name
+}
+
+// ParseWear returns the slot and the cosmetic name of the !wear command.
+// The name is empty to take the slot off.
+func ParseWear(text string) (slot, name string, ok bool) {
+	parts := strings.Fields(strings.ToLower(text))
+	for i, p := range parts {
+		if p != "!wear" {
+			continue
+		}
+		args := parts[i+1:]
+		if len(args) < 2 {
+			return "", "", false
+		}
+		slot, name = args[0], args[1]
+		if name == "off" || name == "none" {
+			name = ""
+		}
+		for _, s := range CosmeticSlots {
+			if s == slot {
+				return slot, name, true
+			}
+		}
+		return "", "", false
+	}
+	return "", "", false
+}
+
+// WearCommand handles !wear <slot> <name>, and lists the unlocked
+// cosmetics when the arguments are missing.
+func (g *Game) WearCommand(v *Viewer, text string) {
+	slot, name, ok := ParseWear(text)
+	if !ok {
+		names := []string{}
+		for _, c := range v.AvailableCosmetics() {
+			names = append(names, c.Slot+" "+c.Name)
+		}
+		msg := fmt.Sprintf("@%s: você ainda não desbloqueou nenhum visual", v.Name)
+		if len(names) > 0 {
+			msg = fmt.Sprintf("@%s: use !wear <tipo> <nome> ou !wear <tipo> off; disponíveis: %s",
+				v.Name, strings.Join(names, ", "))
+		}
+		g.SendMessage(v.Platform, msg)
+		return
+	}
+	if name == "" {
+		log.D("cosmetics: %v took off the %v", v.Name, slot)
+		v.Wear(slot, "")
+		return
+	}
+	c, found := FindCosmetic(slot, name)
+	if !found {
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: %s %s não existe", v.Name, slot, name))
+		return
+	}
+	if !v.HasCosmetic(c) {
+		msg := fmt.Sprintf("@%s: %s %s ainda não está disponível", v.Name, slot, name)
+		if l := Levels.RequiredLevel(Unlock{UnlockCosmetic, c.Set}); c.Set != "" && l >= 0 {
+			msg += fmt.Sprintf(" (nível %d)", l)
+		}
+		g.SendMessage(v.Platform, msg)
+		return
+	}
+	log.D("cosmetics: %v is wearing %v", v.Name, c.ID())
+	v.Wear(slot, name)
+}
+
+// DrawCosmetics draws the layers of the slots worn by the viewer, over
+// the current animation frame. The layers only fit the gopher.
+func (v *Viewer) DrawCosmetics(screen *ebiten.Image, opts *ebiten.DrawImageOptions, slots ...string) {
+	if v.CharacterName() != DefaultCharacter {
+		return
+	}
+	// Drawing and !wear both run on the game goroutine; the lock keeps the
+	// outfit under the same rule as the fields the !check queue changes
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, slot := range slots {
+		name, ok := v.Outfit[slot]
+		if !ok {
+			continue
+		}
+		// Some animations, like sleeping, have no layers
+		a, ok := assets.Cosmetics[slot+"/"+name][v.Animation]
+		if !ok || v.AnimationFrame >= len(a.Frames) {
+			continue
+		}
+		screen.DrawImage(a.Frames[v.AnimationFrame], opts)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseWear(t *testing.T) {
+	for tn, tc := range []struct {
+		text string
+		slot string
+		name string
+		ok   bool
+	}{
+		{text: "!wear hat ninja", slot: "hat", name: "ninja", ok: true},
+		{text: "olha só !wear Outfit Mario", slot: "outfit", name: "mario", ok: true},
+		{text: "!wear hat off", slot: "hat", name: "", ok: true},
+		{text: "!wear eyes none", slot: "eyes", name: "", ok: true},
+		{text: "!wear", ok: false},
+		{text: "!wear hat", ok: false},
+		{text: "!wear shoes mario", ok: false},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			slot, name, ok := ParseWear(tc.text)
+			if slot != tc.slot || name != tc.name || ok != tc.ok {
+				t.Errorf("expected %q %q %v, got %q %q %v", tc.slot, tc.name, tc.ok, slot, name, ok)
+			}
+		})
+	}
+}
+
+func TestWearCommand(t *testing.T) {
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+
+	g.WearCommand(v, "!wear hat ninja")
+	if _, ok := v.Outfit[SlotHat]; ok {
+		t.Fatalf("locked cosmetics should not be worn")
+	}
+
+	v.Unlock(Unlock{UnlockCosmetic, "ninja"})
+	g.WearCommand(v, "!wear hat ninja")
+	g.WearCommand(v, "!wear outfit ninja")
+	if v.Outfit[SlotHat] != "ninja" || v.Outfit[SlotOutfit] != "ninja" {
+		t.Fatalf("expected the ninja outfit, got %v", v.Outfit)
+	}
+
+	g.WearCommand(v, "!wear hat crown")
+	if v.Outfit[SlotHat] != "ninja" {
+		t.Errorf("crown is only for tournament champions")
+	}
+	v.GrantAchievement("tournament-champion")
+	g.WearCommand(v, "!wear hat crown")
+	if v.Outfit[SlotHat] != "crown" {
+		t.Errorf("expected the crown, got %v", v.Outfit[SlotHat])
+	}
+
+	g.WearCommand(v, "!wear hat off")
+	if _, ok := v.Outfit[SlotHat]; ok {
+		t.Errorf("hat should be taken off")
+	}
+	if len(v.AvailableCosmetics()) != 4 {
+		t.Errorf("expected 4 cosmetics available, got %v", v.AvailableCosmetics())
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+)
+
+var (
+	// DuelTimeout is how long the challenged viewer has to answer.
+	DuelTimeout = 60 * time.Second
+
+	// DuelCooldown is how long a viewer must wait to challenge the same
+	// opponent again.
+	DuelCooldown = 5 * time.Minute
+
+	// MaxWager is the maximum XP that can be wagered in a duel.
+	MaxWager = 500
+)
+
+// Duel is a challenge from one viewer to another, with an optional XP
+// wager that is escrowed when the challenge is accepted and paid to the
+// winner.
+type Duel struct {
+	Challenger string    `json:"challenger"`
+	Target     string    `json:"target"`
+	Wager      int       `json:"wager"`
+	Expires    time.Time `json:"expires"`
+	Accepted   bool      `json:"accepted"`
+}
+
+// FindViewerByName returns the viewer with the given display name,
+// preferring viewers from the same platform.
+func (g *Game) FindViewerByName(name, platform string) *Viewer {
+	name = strings.TrimPrefix(name, "@")
+	var found *Viewer
+	for _, uid := range g.UIDs {
+		v := g.Viewers[uid]
+		if !strings.EqualFold(v.Name, name) {
+			continue
+		}
+		if found == nil || v.Platform == platform {
+			found = v
+		}
+	}
+	return found
+}
+
+// DuelOf returns the pending or accepted duel involving the viewer.
+func (g *Game) DuelOf(uid string) *Duel {
+	for _, d := range g.Duels {
+		if d.Challenger == uid || d.Target == uid {
+			return d
+		}
+	}
+	return nil
+}
+
+// Busy returns true if the viewer is fighting, in the team battle or in
+// the tournament, and cannot duel now.
+func (g *Game) Busy(uid string) bool {
+	return g.FightState.IsFighting(uid) || g.TeamBattle != nil ||
+		(g.Tournament != nil && slices.Contains(g.Tournament.Alive(), uid))
+}
+
+func duelKey(challenger, target string) string {
+	return challenger + ">" + target
+}
+
+// ParseDuel parses the arguments of the command: !duel @user [xp]
+func ParseDuel(text string) (target string, wager int, err error) {
+	fields := strings.Fields(text)
+	for i, f := range fields {
+		if f != "!duel" {
+			continue
+		}
+		if i+1 >= len(fields) {
+			return "", 0, fmt.Errorf("use !duel @nome [xp]")
+		}
+		target = fields[i+1]
+		if i+2 < len(fields) {
+			wager, err = strconv.Atoi(fields[i+2])
+			if err != nil || wager < 0 {
+				return "", 0, fmt.Errorf("aposta inválida: %v", fields[i+2])
+			}
+		}
+		return target, wager, nil
+	}
+	return "", 0, fmt.Errorf("use !duel @nome [xp]")
+}
+
+func (g *Game) ChallengeDuel(v *Viewer, text string) {
+	reply := func(msg string, args ...any) {
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: "+msg, append([]any{v.Name}, args...)...))
+	}
+	name, wager, err := ParseDuel(text)
+	if err != nil {
+		reply("%v", err)
+		return
+	}
+	target := g.FindViewerByName(name, v.Platform)
+	switch {
+	case target == nil:
+		reply("não encontrei %v por aqui", name)
+		return
+	case target.UID == v.UID:
+		reply("você não pode duelar contra si mesmo")
+		return
+	case wager > MaxWager:
+		reply("a aposta máxima é de %d XP", MaxWager)
+		return
+	case wager > v.XP:
+		reply("você não tem XP suficiente para esta aposta")
+		return
+	case g.DuelOf(v.UID) != nil || g.FightState.IsFighting(v.UID):
+		reply("você já tem um duelo em andamento")
+		return
+	case g.DuelOf(target.UID) != nil || g.FightState.IsFighting(target.UID):
+		reply("%v já está em um duelo", target.Name)
+		return
+	}
+	key := duelKey(v.UID, target.UID)
+	if until, ok := g.DuelCooldowns[key]; ok && time.Now().Before(until) {
+		reply("aguarde %v para desafiar %v novamente",
+			time.Until(until).Round(time.Second), target.Name)
+		return
+	}
+
+	log.D("duel: %v challenged %v for %d XP", v.Name, target.Name, wager)
+	g.DuelCooldowns[key] = time.Now().Add(DuelCooldown)
+	g.Duels = append(g.Duels, &Duel{
+		Challenger: v.UID,
+		Target:     target.UID,
+		Wager:      wager,
+		Expires:    time.Now().Add(DuelTimeout),
+	})
+	msg := fmt.Sprintf("@%s: @%s te desafiou para um duelo", target.Name, v.Name)
+	if wager > 0 {
+		msg += fmt.Sprintf(" valendo %d XP", wager)
+	}
+	msg += fmt.Sprintf("! Responda com !accept ou !decline em %v", DuelTimeout)
+	g.SendMessage(target.Platform, msg)
+}
+
+func (g *Game) AcceptDuel(v *Viewer) {
+	d := g.DuelOf(v.UID)
+	if d == nil || d.Target != v.UID || d.Accepted {
+		log.D("duel: %v has no duel to accept", v.Name)
+		return
+	}
+	challenger := g.Viewers[d.Challenger]
+	for _, p := range []*Viewer{v, challenger} {
+		if g.Busy(p.UID) {
+			g.SendMessage(v.Platform,
+				fmt.Sprintf("@%s: %s está em outra batalha, aceite o duelo depois", v.Name, p.Name))
+			return
+		}
+	}
+	if v.XP < d.Wager || challenger.XP < d.Wager {
+		g.RemoveDuel(d)
+		g.SendMessage(v.Platform,
+			fmt.Sprintf("@%s: duelo cancelado, XP insuficiente para a aposta", v.Name))
+		return
+	}
+	log.D("duel: %v accepted the duel with %v", v.Name, challenger.Name)
+	// Escrow the wager until the end of the fight
+	v.IncXP(-d.Wager)
+	challenger.IncXP(-d.Wager)
+	d.Accepted = true
+	delete(g.FightingQueue, v.UID)
+	delete(g.FightingQueue, challenger.UID)
+
+	msg := fmt.Sprintf("@%s aceitou o duelo contra @%s!", v.Name, challenger.Name)
+	g.SendMessage(v.Platform, msg)
+	if challenger.Platform != v.Platform {
+		g.SendMessage(challenger.Platform, msg)
+	}
+}
+
+func (g *Game) DeclineDuel(v *Viewer) {
+	d := g.DuelOf(v.UID)
+	if d == nil || d.Target != v.UID || d.Accepted {
+		log.D("duel: %v has no duel to decline", v.Name)
+		return
+	}
+	challenger := g.Viewers[d.Challenger]
+	g.RemoveDuel(d)
+	g.SendMessage(challenger.Platform,
+		fmt.Sprintf("@%s: %s recusou o duelo", challenger.Name, v.Name))
+}
+
+func (g *Game) RemoveDuel(d *Duel) {
+	for i := range g.Duels {
+		if g.Duels[i] == d {
+			g.Duels = append(g.Duels[:i], g.Duels[i+1:]...)
+			return
+		}
+	}
+}
+
+// NextDuel removes and returns the first accepted duel.
+func (g *Game) NextDuel() *Duel {
+	for _, d := range g.Duels {
+		if d.Accepted {
+			g.RemoveDuel(d)
+			return d
+		}
+	}
+	return nil
+}
+
+// UpdateDuels expires the challenges that were not answered in time, and
+// the cooldowns that are over.
+func (g *Game) UpdateDuels() {
+	now := time.Now()
+	for key, until := range g.DuelCooldowns {
+		if now.After(until) {
+			delete(g.DuelCooldowns, key)
+		}
+	}
+	for _, d := range g.Duels {
+		if d.Accepted || now.Before(d.Expires) {
+			continue
+		}
+		g.RemoveDuel(d)
+		if challenger, ok := g.Viewers[d.Challenger]; ok {
+			g.SendMessage(challenger.Platform,
+				fmt.Sprintf("@%s: o desafio expirou sem resposta", challenger.Name))
+		}
+		// Removing while iterating; handle the remaining ones next frame
+		return
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseDuel(t *testing.T) {
+	testCases := []struct {
+		text    string
+		target  string
+		wager   int
+		wantErr bool
+	}{
+		{"!duel @rodinei", "@rodinei", 0, false},
+		{"!duel @rodinei 50", "@rodinei", 50, false},
+		{"bora! !duel rodinei 10", "rodinei", 10, false},
+		{"!duel", "", 0, true},
+		{"!duel @rodinei muito", "", 0, true},
+		{"!duel @rodinei -10", "", 0, true},
+	}
+	for tn, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			target, wager, err := ParseDuel(tc.text)
+			t.Logf("ParseDuel(%q) => %q, %v, %v", tc.text, target, wager, err)
+			if tc.wantErr && err == nil {
+				t.Errorf("wanted error but got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("wanted no error but got: %v", err)
+			}
+			if target != tc.target || wager != tc.wager {
+				t.Errorf("unexpected result: want: %q %v, got: %q %v",
+					tc.target, tc.wager, target, wager)
+			}
+		})
+	}
+}
+
+func newTestDuel() (*Game, *Viewer, *Viewer) {
+	g := New()
+	for _, name := range []string{"ronoaldo", "rodinei"} {
+		v := NewViewer()
+		v.UID = name
+		v.Name = name
+		v.XP = 100
+		g.Viewers[v.UID] = v
+		g.UIDs = append(g.UIDs, v.UID)
+	}
+	return g, g.Viewers["ronoaldo"], g.Viewers["rodinei"]
+}
+
+func TestDuelWager(t *testing.T) {
+	g, challenger, target := newTestDuel()
+
+	g.ChallengeDuel(challenger, "!duel @Rodinei 40")
+	if len(g.Duels) != 1 || g.Duels[0].Target != target.UID {
+		t.Fatalf("duel not registered: %v", g.Duels)
+	}
+
+	g.AcceptDuel(target)
+	if challenger.XP != 60 || target.XP != 60 {
+		t.Errorf("wager not escrowed: %v, %v", challenger.XP, target.XP)
+	}
+
+	d := g.NextDuel()
+	if d == nil || len(g.Duels) != 0 {
+		t.Fatalf("accepted duel not returned: %v", d)
+	}
+	g.StartFight(challenger, target, d.Wager)
+	g.EndFight(target, challenger)
+	// 60 + 50 XP for winning + 2 * 40 XP of the wager
+	if target.XP != 60+50+80 {
+		t.Errorf("unexpected winner XP: %v", target.XP)
+	}
+	if challenger.XP != 60+20 {
+		t.Errorf("unexpected loser XP: %v", challenger.XP)
+	}
+}
+
+func TestDuelDeclineAndCooldown(t *testing.T) {
+	g, challenger, target := newTestDuel()
+
+	g.ChallengeDuel(challenger, "!duel @rodinei")
+	g.DeclineDuel(target)
+	if len(g.Duels) != 0 {
+		t.Errorf("declined duel was not removed: %v", g.Duels)
+	}
+
+	g.ChallengeDuel(challenger, "!duel @rodinei")
+	if len(g.Duels) != 0 {
+		t.Errorf("challenged the same viewer during cooldown: %v", g.Duels)
+	}
+
+	g.ChallengeDuel(challenger, "!duel @ronoaldo")
+	g.ChallengeDuel(challenger, "!duel @rodinei 1000")
+	if len(g.Duels) != 0 {
+		t.Errorf("invalid challenges were accepted: %v", g.Duels)
+	}
+}
+
+func TestDuelBusy(t *testing.T) {
+	g, challenger, target := newTestDuel()
+	g.ChallengeDuel(challenger, "!duel @rodinei 10")
+	g.Tournament = NewTournament(8, TournamentSingle)
+	g.Tournament.Join(challenger.UID)
+	g.AcceptDuel(target)
+	if g.Duels[0].Accepted || target.XP != 100 {
+		t.Errorf("accepted a duel with a player in the tournament")
+	}
+	g.Tournament = nil
+	g.AcceptDuel(target)
+	if !g.Duels[0].Accepted {
+		t.Errorf("duel not accepted after the tournament")
+	}
+}
+
+func TestDuelCooldownExpires(t *testing.T) {
+	g, _, _ := newTestDuel()
+	g.DuelCooldowns[duelKey("a", "b")] = time.Now().Add(-time.Second)
+	g.DuelCooldowns[duelKey("a", "c")] = time.Now().Add(time.Minute)
+	g.UpdateDuels()
+	if _, ok := g.DuelCooldowns[duelKey("a", "b")]; ok || len(g.DuelCooldowns) != 1 {
+		t.Errorf("expected only the active cooldown, got %v", g.DuelCooldowns)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// SpecialMove is a powerful fight move, unlocked by level, that fires a
+// projectile at the opponent.
+//
+// Each move has its own animation bundle, with a "flying" animation for
+// the projectile.
+type SpecialMove struct {
+	Name   string
+	Bundle string
+	Shout  string
+
+	Damage   int
+	Speed    float64
+	Cooldown int
+
+	Color color.RGBA
+}
+
+var SpecialMoves = []SpecialMove{
+	{
+		Name:     "hadouken",
+		Bundle:   "hadouken",
+		Shout:    "HADOUKEN!",
+		Damage:   20,
+		Speed:    6,
+		Cooldown: 2,
+		Color:    color.RGBA{0x40, 0x90, 0xff, 0xff},
+	},
+	{
+		Name:     "shoryuken",
+		Bundle:   "shoryuken",
+		Shout:    "SHORYUKEN!",
+		Damage:   25,
+		Speed:    9,
+		Cooldown: 3,
+		Color:    color.RGBA{0xff, 0x70, 0x10, 0xff},
+	},
+	{
+		Name:     "kamehameha",
+		Bundle:   "kamehameha",
+		Shout:    "KAMEHAMEHA!",
+		Damage:   35,
+		Speed:    4,
+		Cooldown: 4,
+		Color:    color.RGBA{0x60, 0xe0, 0xff, 0xff},
+	},
+	{
+		Name:     "spear",
+		Bundle:   "spear",
+		Shout:    "GET OVER HERE!",
+		Damage:   15,
+		Speed:    12,
+		Cooldown: 2,
+		Color:    color.RGBA{0xff, 0xd0, 0x20, 0xff},
+	},
+}
+
+func FindSpecialMove(name string) (SpecialMove, bool) {
+	for _, sm := range SpecialMoves {
+		if sm.Name == name {
+			return sm, true
+		}
+	}
+	return SpecialMove{}, false
+}
+
+// SpecialMoveIn returns the name of the special move command in the
+// message text, if any.
+func SpecialMoveIn(text string) string {
+	for _, sm := range SpecialMoves {
+		if strings.Contains(text, "!"+sm.Name) {
+			return sm.Name
+		}
+	}
+	return ""
+}
+
+// Projectile is an effect that travels towards a target and damages it
+// on collision.
+type Projectile struct {
+	Move   SpecialMove
+	Owner  string
+	Target string
+
+	PosX float64
+	PosY float64
+	VelX float64
+
+	Frame     int
+	frameTime time.Duration
+	backwards bool
+
+	// History is the index of the move in the fight history, updated
+	// with the damage when the projectile hits.
+	History int
+}
+
+func (p *Projectile) Anim() *assets.Animation {
+	return assets.Bundles[p.Move.Bundle]["flying"]
+}
+
+func (p *Projectile) Size() (w, h float64) {
+	b := p.Anim().Frames[0].Bounds()
+	return float64(b.Dx()), float64(b.Dy())
+}
+
+// Hits returns true if the projectile collides with the gopher.
+func (p *Projectile) Hits(v *Viewer) bool {
+	w, h := p.Size()
+	return p.PosX < v.Right() && p.PosX+w > v.Left() && p.PosY < v.Bottom() && p.PosY+h > v.Top()
+}
+
+func (g *Game) FireProjectile(owner, target *Viewer, sm SpecialMove, history int) {
+	p := &Projectile{
+		Move:    sm,
+		Owner:   owner.UID,
+		Target:  target.UID,
+		VelX:    sm.Speed,
+		History: history,
+	}
+	w, h := p.Size()
+	p.PosX = owner.PosX + float64(gopherSize)/2
+	if target.PosX < owner.PosX {
+		p.VelX = -p.VelX
+		p.PosX -= w
+	}
+	p.PosY = owner.PosY + (float64(gopherSize)-h)/2
+	log.D("effects: %v fired %v at %v", owner.Name, sm.Name, target.Name)
+	g.projectiles = append(g.projectiles, p)
+	g.AddFloatingText(owner, sm.Shout, sm.Color)
+}
+
+func (g *Game) UpdateProjectiles() {
+	alive := g.projectiles[:0]
+	for _, p := range g.projectiles {
+		p.PosX += p.VelX
+		a := p.Anim()
+		p.frameTime += tickDuration()
+		for p.frameTime >= a.FrameDuration(p.Frame) {
+			p.frameTime -= a.FrameDuration(p.Frame)
+			p.Frame, p.backwards, _ = a.Next(p.Frame, p.backwards)
+		}
+		target, ok := g.Viewers[p.Target]
+		if ok && p.Hits(target) {
+			g.ProjectileHit(p, target)
+			continue
+		}
+		if p.PosX < -float64(gopherSize) || p.PosX > float64(Width) {
+			continue
+		}
+		alive = append(alive, p)
+	}
+	g.projectiles = alive
+}
+
+func (g *Game) ProjectileHit(p *Projectile, target *Viewer) {
+	fs := &g.FightState
+	dmg := p.Move.Damage + rand.Intn(6)
+	if fs.IsFighting(target.UID) && fs.Fighter(target.UID).Defending {
+		dmg /= 2
+	}
+	log.D("effects: %v hit %v for %d", p.Move.Name, target.Name, dmg)
+	target.Damage(dmg)
+	target.Knockback(KnockbackSpeed*sign(p.VelX), KnockbackLift)
+	if p.History < len(fs.History) {
+		fs.History[p.History].Damage = dmg
+	}
+	g.AddFloatingText(target, fmt.Sprintf("-%d", dmg), ColorDamage)
+
+	if target.HP <= 0 && fs.IsFighting(target.UID) {
+		if owner, ok := g.Viewers[p.Owner]; ok {
+			g.EndFight(owner, target)
+		}
+	}
+}
+
+func sign(x float64) float64 {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
+
+func (g *Game) DrawProjectiles(screen *ebiten.Image) {
+	for _, p := range g.projectiles {
+		a := p.Anim()
+		w, _ := p.Size()
+		opts := &ebiten.DrawImageOptions{}
+		if p.VelX < 0 {
+			opts.GeoM.Scale(-1, 1)
+			opts.GeoM.Translate(w, 0)
+		}
+		opts.GeoM.Translate(p.PosX, p.PosY)
+		opts.ColorScale.ScaleWithColor(p.Move.Color)
+		screen.DrawImage(a.Frames[p.Frame], opts)
+		if p.Frame < len(a.Skins) {
+			opts.ColorScale.Reset()
+			screen.DrawImage(a.Skins[p.Frame], opts)
+		}
+	}
+}
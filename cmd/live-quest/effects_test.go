@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSpecialMoveIn(t *testing.T) {
+	testCases := []struct {
+		text string
+		move string
+	}{
+		{"!hadouken", "hadouken"},
+		{"toma essa !kamehameha", "kamehameha"},
+		{"!special", ""},
+		{"!spear", "spear"},
+		{"hadouken", ""},
+	}
+	for tn, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if move := SpecialMoveIn(tc.text); move != tc.move {
+				t.Errorf("SpecialMoveIn(%q): want: %q, got: %q", tc.text, tc.move, move)
+			}
+		})
+	}
+}
+
+func TestProjectileHit(t *testing.T) {
+	g, p1, p2 := newTestFight()
+	sm, _ := FindSpecialMove("hadouken")
+
+	g.FireProjectile(p1, p2, sm, 0)
+	if len(g.projectiles) != 1 {
+		t.Fatalf("unexpected projectiles: %v", g.projectiles)
+	}
+	if g.projectiles[0].VelX <= 0 {
+		t.Errorf("projectile should travel towards the target")
+	}
+	for i := 0; i < 200 && len(g.projectiles) > 0; i++ {
+		g.UpdateProjectiles()
+		g.Count++
+	}
+	if len(g.projectiles) != 0 {
+		t.Errorf("projectile did not hit the target")
+	}
+	if p2.HP >= 100 || p2.HP < 100-sm.Damage-5 {
+		t.Errorf("unexpected HP after the hit: %v", p2.HP)
+	}
+}
+
+func TestChooseLockedSpecialMove(t *testing.T) {
+	g, p1, _ := newTestFight()
+	p1.XP = 0
+	g.ChooseMove(p1, "kamehameha")
+	if move := g.FightState.Fighter(p1.UID).Move; move != "" {
+		t.Errorf("locked move was accepted: %v", move)
+	}
+
+	p1.Unlock(Unlock{UnlockMove, "kamehameha"})
+	g.ChooseMove(p1, "kamehameha")
+	if move := g.FightState.Fighter(p1.UID).Move; move != "kamehameha" {
+		t.Errorf("unlocked move was not accepted: %v", move)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"github.com/codigolandia/live-quest/log"
+)
+
+var (
+	// Emotes are the commands that play an animation once. The ones that
+	// need a target make the gopher walk to it first.
+	Emotes = []string{"dance", "wave", "sit", "sleep", "hug", "highfive"}
+
+	// TargetEmotes are played together with the mentioned viewer.
+	TargetEmotes = map[string]bool{"hug": true, "highfive": true}
+
+	// EmoteHoldFrames is how long the last frame of the emote stays on
+	// screen before the gopher goes back to its behavior.
+	EmoteHoldFrames = 90
+
+	// EmoteWalkFrames is how long the gopher walks to the target before
+	// giving up the emote.
+	EmoteWalkFrames = 600
+
+	// EmoteDistance is how close the gophers get for a hug or high five.
+	EmoteDistance = float64(gopherSize) * 0.6
+)
+
+// EmoteIn returns the emote command in the message text, if any.
+func EmoteIn(text string) string {
+	for _, e := range Emotes {
+		if strings.Contains(text, "!"+e) {
+			return e
+		}
+	}
+	return ""
+}
+
+// Play starts an animation from the first frame.
+func (v *Viewer) Play(anim string) {
+	v.facingLeft = false
+	v.Animation = anim
+	v.AnimationFrame = 0
+	// Restarts the animation, even if it is already playing
+	v.playing = ""
+	v.animHandler = nil
+}
+
+// EmoteCommand handles !dance, !wave, !sit, !sleep, !hug @name and
+// !highfive @name
+func (g *Game) EmoteCommand(v *Viewer, text string) {
+	emote := EmoteIn(text)
+	if g.FightState.IsFighting(v.UID) || g.TeamBattle != nil {
+		log.D("emote: %v is busy, ignoring !%v", v.Name, emote)
+		return
+	}
+	var target *Viewer
+	if TargetEmotes[emote] {
+		for _, f := range strings.Fields(text) {
+			if strings.HasPrefix(f, "@") {
+				target = g.FindViewerByName(f, v.Platform)
+				break
+			}
+		}
+		if target == nil || target.UID == v.UID || !g.IsVisible(target) {
+			log.D("emote: %v has no one to %v", v.Name, emote)
+			return
+		}
+	}
+	g.StartEmote(v, emote, target)
+}
+
+// StartEmote plays the emote, walking to the target first if there is
+// one.
+func (g *Game) StartEmote(v *Viewer, emote string, target *Viewer) {
+	log.D("emote: %v is playing %v", v.Name, emote)
+	v.emote = emote
+	v.emoteTarget = ""
+	if target != nil {
+		v.emoteTarget = target.UID
+		v.emoteFrames = EmoteWalkFrames
+		return
+	}
+	v.VelX = 0
+	v.Play(emote)
+	v.emoteFrames = EmoteHoldFrames
+}
+
+// UpdateEmote plays the emote of the gopher, returning false when there is
+// none.
+func (g *Game) UpdateEmote(v *Viewer) bool {
+	if v.emote == "" {
+		return false
+	}
+	if v.emoteTarget != "" {
+		g.WalkToTarget(v)
+		return true
+	}
+	if v.Animation != v.emote {
+		// Interrupted by a fight
+		v.emote = ""
+		return false
+	}
+	if v.AnimationFrame < v.CurrAnimFrames()-1 {
+		return true
+	}
+	v.emoteFrames--
+	if v.emoteFrames <= 0 {
+		v.emote = ""
+		// Back to what the gopher was doing
+		v.SetBehavior(v.Behavior)
+	}
+	return true
+}
+
+// WalkToTarget moves the gopher close to the emote target, and then both
+// play the emote facing each other.
+func (g *Game) WalkToTarget(v *Viewer) {
+	t, ok := g.Viewers[v.emoteTarget]
+	v.emoteFrames--
+	if !ok || !g.IsVisible(t) || v.emoteFrames <= 0 {
+		log.D("emote: %v gave up the %v", v.Name, v.emote)
+		v.emote, v.emoteTarget = "", ""
+		v.SetBehavior(v.Behavior)
+		return
+	}
+	if !v.WalkTowards(t.PosX, EmoteDistance) {
+		return
+	}
+	emote := v.emote
+	g.StartEmote(v, emote, nil)
+	v.facingLeft = t.PosX < v.PosX
+	// Sleeping gophers are hugged without waking up
+	if t.Behavior != BehaviorSleep && !g.FightState.IsFighting(t.UID) {
+		g.StartEmote(t, emote, nil)
+		t.facingLeft = !v.facingLeft
+	}
+}
+
+// WalkTowards walks to the horizontal position px, returning true when the
+// gopher is within distance of it.
+func (v *Viewer) WalkTowards(px, distance float64) bool {
+	dx := px - v.PosX
+	switch {
+	case math.Abs(dx) <= distance:
+		if v.VelX != 0 {
+			v.VelX = 0
+			v.Stop()
+		}
+		return true
+	case dx > 0 && v.VelX <= 0:
+		v.VelX = WalkSpeed
+		v.WalkRight()
+	case dx < 0 && v.VelX >= 0:
+		v.VelX = -WalkSpeed
+		v.WalkLeft()
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEmoteIn(t *testing.T) {
+	for tn, tc := range []struct {
+		text   string
+		expect string
+	}{
+		{text: "!dance", expect: "dance"},
+		{text: "bora !wave galera", expect: "wave"},
+		{text: "!hug @fulano", expect: "hug"},
+		{text: "!highfive @fulano", expect: "highfive"},
+		{text: "vou dormir, !sleep", expect: "sleep"},
+		{text: "dance comigo", expect: ""},
+		{text: "!jump", expect: ""},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if got := EmoteIn(tc.text); got != tc.expect {
+				t.Errorf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestEmoteOneShot(t *testing.T) {
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+	v.SetBehavior(BehaviorIdle)
+	g.EmoteCommand(v, "!dance")
+	if v.Animation != "dance" || v.AnimationFrame != 0 {
+		t.Fatalf("expected to start dancing, got %v/%d", v.Animation, v.AnimationFrame)
+	}
+
+	last, ticks := v.CurrAnimFrames()-1, 0
+	for ; v.emote != "" && ticks < 1000; ticks++ {
+		v.UpdateAnimation(g)
+		g.Think(v)
+		g.Count++
+		if v.emote != "" && v.AnimationFrame < last && ticks > 6*last {
+			t.Fatalf("one-shot animation should not loop, frame %d", v.AnimationFrame)
+		}
+	}
+	if v.emote != "" {
+		t.Fatalf("emote should be over")
+	}
+	if ticks < last+EmoteHoldFrames {
+		t.Errorf("emote ended too soon, after %d ticks", ticks)
+	}
+	if v.Animation != "standing" || v.Behavior != BehaviorIdle {
+		t.Errorf("expected to go back to idle, got %v/%v", v.Animation, v.Behavior)
+	}
+}
+
+func TestEmoteTarget(t *testing.T) {
+	g := newTestViewers("a", "b")
+	a, b := g.Viewers["a"], g.Viewers["b"]
+	a.SetBehavior(BehaviorIdle)
+	b.SetBehavior(BehaviorIdle)
+
+	g.EmoteCommand(a, "!hug @ninguem")
+	if a.emote != "" {
+		t.Fatalf("should not hug someone that is not here")
+	}
+
+	a.PosX, b.PosX = 0, 1000
+	g.EmoteCommand(a, "!hug @B")
+	g.Think(a)
+	if a.VelX <= 0 || b.Animation == "hug" {
+		t.Fatalf("expected to walk to the target first, got %v", a.VelX)
+	}
+
+	a.PosX = b.PosX - EmoteDistance/2
+	g.Think(a)
+	if a.VelX != 0 {
+		t.Errorf("expected to stop close to the target, got %v", a.VelX)
+	}
+	if a.Animation != "hug" || b.Animation != "hug" {
+		t.Fatalf("expected both to hug, got %v and %v", a.Animation, b.Animation)
+	}
+	if a.facingLeft || !b.facingLeft {
+		t.Errorf("gophers should face each other")
+	}
+}
+
+func TestSitAndSleepAnimations(t *testing.T) {
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+	for _, b := range []Behavior{BehaviorSit, BehaviorSleep} {
+		v.SetBehavior(b)
+		if v.Animation != string(b) {
+			t.Fatalf("expected %v animation, got %v", b, v.Animation)
+		}
+		for i := 0; i < 100; i++ {
+			v.UpdateAnimation(g)
+			g.Count++
+		}
+		if v.AnimationFrame != v.CurrAnimFrames()-1 {
+			t.Errorf("%v should hold the last frame, got %d", b, v.AnimationFrame)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/codigolandia/live-quest/log"
+)
+
+// EventType identifies something that happened during the game.
+type EventType int
+
+const (
+	EventLevelUp EventType = iota
+	EventMessage
+	EventFirstChat
+	EventFightWon
+	EventChallengeSolved
+	EventTournamentWon
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventLevelUp:
+		return "level-up"
+	case EventMessage:
+		return "message"
+	case EventFirstChat:
+		return "first-chat"
+	case EventFightWon:
+		return "fight-won"
+	case EventChallengeSolved:
+		return "challenge-solved"
+	case EventTournamentWon:
+		return "tournament-won"
+	}
+	return "invalid"
+}
+
+// Event is something that happened to a viewer and that other parts of the
+// game can react to, like announcing it in the chat.
+type Event struct {
+	Type  EventType
+	UID   string
+	Level int
+}
+
+var events = make(chan Event, 100)
+
+// Emit queues the event to be handled on the next game update.
+// It is safe to call it from other goroutines, like the challenge queue.
+func Emit(e Event) {
+	select {
+	case events <- e:
+	default:
+		log.W("events: queue is full, dropping %v event for %v", e.Type, e.UID)
+	}
+}
+
+// HandleEvents processes all the events queued since the last update.
+func (g *Game) HandleEvents() {
+	for {
+		select {
+		case e := <-events:
+			g.HandleEvent(e)
+		default:
+			return
+		}
+	}
+}
+
+func (g *Game) HandleEvent(e Event) {
+	v, ok := g.Viewers[e.UID]
+	if !ok {
+		log.W("events: %v event for unknown viewer %v", e.Type, e.UID)
+		return
+	}
+	log.D("events: %v for %v", e.Type, v.Name)
+	switch e.Type {
+	case EventLevelUp:
+		g.LevelUp(v, e.Level)
+	}
+	switch e.Type {
+	case EventLevelUp, EventFightWon, EventChallengeSolved, EventTournamentWon:
+		// Fighters keep their stance until the end of the fight
+		if !g.FightState.IsFighting(v.UID) && g.TeamBattle == nil {
+			v.SetBehavior(BehaviorCelebrate)
+		}
+	}
+	g.CheckAchievements(v, e)
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var (
+	// TurnTimeout is how long a fighter has to choose a move in the chat
+	// before an automatic move is played.
+	TurnTimeout = 20 * time.Second
+
+	// MoveDelay is the minimum time between two moves, so the audience can
+	// follow the fight on screen.
+	MoveDelay = 1500 * time.Millisecond
+
+	// MaxHeals is how many times a fighter can heal during a fight.
+	MaxHeals = 2
+
+	// SpecialCooldown is how many turns after the special move a fighter
+	// can play it again.
+	SpecialCooldown = 3
+
+	// FightHistorySize is the number of moves displayed on screen.
+	FightHistorySize = 5
+)
+
+const (
+	MoveAttack  = "attack"
+	MoveDefend  = "defend"
+	MoveSpecial = "special"
+	MoveHeal    = "heal"
+)
+
+// Fighter is the state of a viewer during a fight.
+type Fighter struct {
+	// Move is the move chosen in the chat, to be played on the next turn.
+	Move            string `json:"move"`
+	Defending       bool   `json:"defending"`
+	SpecialCooldown int    `json:"specialCooldown"`
+	Heals           int    `json:"heals"`
+
+	// Cooldowns are the turns left to use each special move again.
+	Cooldowns map[string]int `json:"cooldowns"`
+}
+
+// turnsLeft returns how many turns a move on cooldown n still waits when
+// played next: PlayMove counts the turn down before playing the move.
+func turnsLeft(n int) int {
+	return max(n-1, 0)
+}
+
+// CanPlay returns an error explaining why the move is not available.
+func (f *Fighter) CanPlay(move string) error {
+	switch move {
+	case MoveSpecial:
+		if n := turnsLeft(f.SpecialCooldown); n > 0 {
+			return fmt.Errorf("especial disponível em %d turno(s)", n)
+		}
+	case MoveHeal:
+		if f.Heals >= MaxHeals {
+			return fmt.Errorf("você não tem mais curas nesta luta")
+		}
+	default:
+		if n := turnsLeft(f.Cooldowns[move]); n > 0 {
+			return fmt.Errorf("%s disponível em %d turno(s)", move, n)
+		}
+	}
+	return nil
+}
+
+// FightMove is a move played during a fight.
+type FightMove struct {
+	UID    string `json:"uid"`
+	Name   string `json:"name"`
+	Move   string `json:"move"`
+	Damage int    `json:"damage"`
+	Heal   int    `json:"heal"`
+	Missed bool   `json:"missed"`
+	Auto   bool   `json:"auto"`
+}
+
+func (m FightMove) String() string {
+	s := fmt.Sprintf("%s: !%s", m.Name, m.Move)
+	switch {
+	case m.Missed:
+		s += " errou!"
+	case m.Damage > 0:
+		s += fmt.Sprintf(" -%d HP", m.Damage)
+	case m.Heal > 0:
+		s += fmt.Sprintf(" +%d HP", m.Heal)
+	}
+	if m.Auto {
+		s += " (auto)"
+	}
+	return s
+}
+
+type FightState struct {
+	Player1     string `json:"player1"`
+	Player2     string `json:"player2"`
+	CurrentTurn string `json:"currentTurn"`
+
+	TurnDeadline time.Time           `json:"turnDeadline"`
+	LastMove     time.Time           `json:"lastMove"`
+	Fighters     map[string]*Fighter `json:"fighters"`
+	History      []FightMove         `json:"history"`
+
+	// Wager is the XP escrowed by each player in a duel.
+	Wager int `json:"wager"`
+
+	// Striking is true while the attack animation of the last move
+	// plays, until the StrikeDeadline; finishStrike resolves the move
+	// when the animation is interrupted or never finishes.
+	Striking       bool      `json:"-"`
+	StrikeDeadline time.Time `json:"-"`
+	finishStrike   func()
+}
+
+// Fighter returns the fight state for the given player.
+func (fs *FightState) Fighter(uid string) *Fighter {
+	if fs.Fighters == nil {
+		fs.Fighters = make(map[string]*Fighter)
+	}
+	f, ok := fs.Fighters[uid]
+	if !ok {
+		f = &Fighter{}
+		fs.Fighters[uid] = f
+	}
+	return f
+}
+
+// Opponent returns the player fighting against uid.
+func (fs *FightState) Opponent(uid string) string {
+	if uid == fs.Player1 {
+		return fs.Player2
+	}
+	return fs.Player1
+}
+
+func (fs *FightState) IsFighting(uid string) bool {
+	return fs.CurrentTurn != "" && (uid == fs.Player1 || uid == fs.Player2)
+}
+
+// UpdateFightPositions places the fighters in the middle of the arena,
+// facing each other, and the other gophers on the stands.
+func (g *Game) UpdateFightPositions() {
+	fs := &g.FightState
+	cx := ArenaCenter() - float64(gopherSize)/2
+	crowd := make([]*Viewer, 0, len(g.UIDs))
+	for _, uid := range g.UIDs {
+		v := g.Viewers[uid]
+		switch {
+		case fs.CurrentTurn != "" && uid == fs.Player1:
+			v.VelX, v.KnockX = 0, 0
+			v.PosX = cx - FightDistance/2
+			v.facingLeft = false
+		case fs.CurrentTurn != "" && uid == fs.Player2:
+			v.VelX, v.KnockX = 0, 0
+			v.PosX = cx + FightDistance/2
+			v.facingLeft = true
+		case g.IsVisible(v):
+			crowd = append(crowd, v)
+		}
+	}
+	g.SeatSpectators(crowd)
+}
+
+func (g *Game) RemoveFromQueue(p1, p2 string) {
+	delete(g.FightingQueue, p1)
+	delete(g.FightingQueue, p2)
+	delete(g.queuedAt, p1)
+	delete(g.queuedAt, p2)
+}
+
+var fightHelpMsg = "%s vs %s! Na sua vez, escolha: !attack, !defend, !special ou !heal; " +
+	"golpes desbloqueados por nível: !hadouken, !shoryuken, !kamehameha e !spear"
+
+func (g *Game) StartFight(p1, p2 *Viewer, wager int) {
+	log.D("fight: initializing fight ...")
+	g.FightState = FightState{
+		Player1:      p1.UID,
+		Player2:      p2.UID,
+		CurrentTurn:  p2.UID,
+		TurnDeadline: time.Now().Add(TurnTimeout),
+		LastMove:     time.Now(),
+		Wager:        wager,
+	}
+	g.RemoveFromQueue(p1.UID, p2.UID)
+	// The fighters face each other right away, and are placed in the ring
+	// when the transition to the arena covers the screen
+	p1.FightStance(false)
+	p1.VelX = 0
+	p2.FightStance(true)
+	p2.VelX = 0
+	g.OpenFightArena()
+
+	msg := fmt.Sprintf(fightHelpMsg, "@"+p1.Name, "@"+p2.Name)
+	g.SendMessage(p1.Platform, msg)
+	if p2.Platform != p1.Platform {
+		g.SendMessage(p2.Platform, msg)
+	}
+}
+
+func (g *Game) FightRound() {
+	fs := &g.FightState
+	if fs.CurrentTurn == "" {
+		// The team battle takes the whole arena
+		if g.Count%60 != 0 || g.TeamBattle != nil {
+			return
+		}
+		// Tournaments hold the arena; otherwise accepted duels have
+		// priority over the fighting queue
+		if g.Tournament != nil {
+			if g.Tournament.Finished() {
+				g.FinishTournament()
+			} else {
+				g.NextTournamentFight()
+			}
+		} else if d := g.NextDuel(); d != nil {
+			g.StartFight(g.Viewers[d.Challenger], g.Viewers[d.Target], d.Wager)
+		} else if p1, p2, ok := g.MatchFighters(); ok {
+			g.StartFight(p1, p2, 0)
+		}
+		return
+	}
+
+	now := time.Now()
+	if fs.Striking {
+		if now.After(fs.StrikeDeadline) && fs.finishStrike != nil {
+			log.W("fight: the attack animation did not finish, resolving the move")
+			fs.finishStrike()
+		}
+		return
+	}
+	if now.Sub(fs.LastMove) < MoveDelay || len(g.projectiles) > 0 {
+		return
+	}
+	f := fs.Fighter(fs.CurrentTurn)
+	switch {
+	case f.Move != "":
+		g.PlayMove(fs.CurrentTurn, f.Move, false)
+	case now.After(fs.TurnDeadline):
+		g.PlayMove(fs.CurrentTurn, g.AutoMove(fs.CurrentTurn), true)
+	}
+}
+
+// ChooseMove registers the move chosen by the fighter in the chat.
+// It will be played on the fighter's turn.
+func (g *Game) ChooseMove(v *Viewer, move string) {
+	fs := &g.FightState
+	if !fs.IsFighting(v.UID) {
+		log.D("fight: %v is not fighting, ignoring !%v", v.Name, move)
+		return
+	}
+	if _, ok := FindSpecialMove(move); ok && !v.HasUnlocked(Unlock{UnlockMove, move}) {
+		msg := fmt.Sprintf("@%s: %s ainda não está disponível", v.Name, move)
+		if l := Levels.RequiredLevel(Unlock{UnlockMove, move}); l >= 0 {
+			msg = fmt.Sprintf("@%s: %s desbloqueia no nível %d", v.Name, move, l)
+		}
+		g.SendMessage(v.Platform, msg)
+		return
+	}
+	f := fs.Fighter(v.UID)
+	if err := f.CanPlay(move); err != nil {
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: %v", v.Name, err))
+		return
+	}
+	log.D("fight: %v chose !%v", v.Name, move)
+	f.Move = move
+}
+
+// AutoMove picks a move for a fighter that did not choose in time.
+func (g *Game) AutoMove(uid string) string {
+	v, f := g.Viewers[uid], g.FightState.Fighter(uid)
+	if v.HP < 30 && f.CanPlay(MoveHeal) == nil {
+		return MoveHeal
+	}
+	return MoveAttack
+}
+
+func (g *Game) PlayMove(uid, move string, auto bool) {
+	fs := &g.FightState
+	attacker, defender := g.Viewers[uid], g.Viewers[fs.Opponent(uid)]
+	f, df := fs.Fighter(attacker.UID), fs.Fighter(defender.UID)
+
+	f.Move = ""
+	f.Defending = false
+	if f.SpecialCooldown > 0 {
+		f.SpecialCooldown--
+	}
+	if f.Cooldowns == nil {
+		f.Cooldowns = make(map[string]int)
+	}
+	for m, n := range f.Cooldowns {
+		if n > 0 {
+			f.Cooldowns[m] = n - 1
+		}
+	}
+
+	fm := FightMove{UID: attacker.UID, Name: attacker.Name, Move: move, Auto: auto}
+	switch move {
+	case MoveAttack:
+		fm.Damage = attacker.Attack(defender)
+	case MoveSpecial:
+		f.SpecialCooldown = SpecialCooldown
+		if rand.Intn(2) == 0 {
+			fm.Missed = true
+		} else {
+			fm.Damage = 2 * attacker.Attack(defender)
+		}
+	case MoveDefend:
+		f.Defending = true
+	case MoveHeal:
+		f.Heals++
+		fm.Heal = min(15+rand.Intn(11), 100-attacker.HP)
+		attacker.HP += fm.Heal
+	default:
+		// Special moves deal damage when the projectile hits
+		if sm, ok := FindSpecialMove(move); ok {
+			f.Cooldowns[move] = sm.Cooldown
+			g.FireProjectile(attacker, defender, sm, len(fs.History))
+		}
+	}
+	if fm.Damage > 0 && df.Defending {
+		fm.Damage /= 2
+	}
+	log.D("fight: %v", fm)
+	fs.History = append(fs.History, fm)
+
+	now := time.Now()
+	fs.LastMove = now
+	fs.CurrentTurn = defender.UID
+	fs.TurnDeadline = now.Add(TurnTimeout)
+	if move == MoveAttack || move == MoveSpecial {
+		g.Strike(attacker, defender, fm)
+		return
+	}
+	g.ResolveMove(attacker, defender, fm)
+}
+
+// AttackHitEvent is the frame event of the attack animation that deals
+// the damage.
+const AttackHitEvent = "hit"
+
+// Strike plays the attack animation, resolving the move on its hit frame,
+// so the damage matches the visual. If the animation is interrupted, or
+// loops without finishing, the move is resolved after its duration.
+func (g *Game) Strike(attacker, defender *Viewer, fm FightMove) {
+	fs := &g.FightState
+	facingLeft := attacker.facingLeft
+	attacker.Play("attack")
+	attacker.facingLeft = facingLeft
+
+	hit, done := false, false
+	resolve := func() {
+		if !hit {
+			hit = true
+			g.ResolveMove(attacker, defender, fm)
+		}
+	}
+	finish := func() {
+		resolve()
+		done = true
+		// The fight is over when the hit knocks the defender out
+		if fs.IsFighting(attacker.UID) {
+			fs.Striking, fs.finishStrike = false, nil
+			attacker.FightStance(facingLeft)
+		}
+	}
+	fs.Striking, fs.finishStrike = true, finish
+	fs.StrikeDeadline = fs.LastMove.Add(attacker.CurrAnim().Duration() + MoveDelay)
+	attacker.OnAnimation(func(event string) {
+		if done {
+			return
+		}
+		switch event {
+		case AttackHitEvent:
+			resolve()
+		case AnimationFinished:
+			finish()
+		}
+	})
+}
+
+// ResolveMove deals the damage of the move and shows its outcome, ending
+// the fight when the defender is knocked out. Moves resolved after the
+// fight is over are ignored.
+func (g *Game) ResolveMove(attacker, defender *Viewer, fm FightMove) {
+	if !g.FightState.IsFighting(attacker.UID) {
+		return
+	}
+	defender.Damage(fm.Damage)
+	if fm.Damage > 0 {
+		defender.KnockbackFrom(attacker.PosX + float64(gopherSize)/2)
+	}
+	g.ShowMove(attacker, defender, fm)
+	if defender.HP <= 0 && g.FightState.IsFighting(defender.UID) {
+		g.EndFight(attacker, defender)
+	}
+}
+
+func (g *Game) EndFight(winner, loser *Viewer) {
+	log.D("fight: fight is over: %v won!", winner.Name)
+	g.RecordFight(winner, loser)
+	if wager := g.FightState.Wager; wager > 0 {
+		winner.IncXP(2 * wager)
+		g.SendMessage(winner.Platform,
+			fmt.Sprintf("@%s venceu o duelo e ganhou %d XP!", winner.Name, 2*wager))
+	}
+	if t := g.Tournament; t != nil && t.IsPlaying(winner.UID, loser.UID) {
+		t.Report(winner.UID, loser.UID)
+	}
+	g.FightState = FightState{}
+	loser.HP = 100
+	loser.IncXP(20)
+	loser.Stop()
+	winner.HP = 100
+	winner.IncXP(50)
+	winner.Stop()
+	winner.Jump()
+	Emit(Event{Type: EventFightWon, UID: winner.UID})
+}
+
+func (g *Game) SortFighters() []*Viewer {
+	fighters := make([]*Viewer, 0, len(g.FightingQueue))
+	for uid, _ := range g.FightingQueue {
+		v := g.Viewers[uid]
+		fighters = append(fighters, v)
+	}
+	sort.Sort(ByXP(fighters))
+	return fighters
+}
+
+var (
+	ColorDamage = color.RGBA{0xff, 0x40, 0x40, 0xff}
+	ColorHeal   = color.RGBA{0x40, 0xff, 0x40, 0xff}
+	ColorMiss   = color.RGBA{0xc0, 0xc0, 0xc0, 0xff}
+	ColorDefend = color.RGBA{0x40, 0xa0, 0xff, 0xff}
+)
+
+// ShowMove displays floating numbers with the outcome of the move.
+func (g *Game) ShowMove(attacker, defender *Viewer, fm FightMove) {
+	switch {
+	case fm.Missed:
+		g.AddFloatingText(defender, "MISS", ColorMiss)
+	case fm.Damage > 0:
+		g.AddFloatingText(defender, fmt.Sprintf("-%d", fm.Damage), ColorDamage)
+	case fm.Heal > 0:
+		g.AddFloatingText(attacker, fmt.Sprintf("+%d", fm.Heal), ColorHeal)
+	case fm.Move == MoveDefend:
+		g.AddFloatingText(attacker, "DEFESA", ColorDefend)
+	}
+}
+
+// FloatingText is a short text that floats up and vanishes,
+// like damage numbers.
+type FloatingText struct {
+	Text  string
+	Color color.Color
+	PosX  float64
+	PosY  float64
+	Frame int
+}
+
+// FloatingTextFrames is how long a floating text stays on screen.
+var FloatingTextFrames = 90
+
+func (g *Game) AddFloatingText(v *Viewer, txt string, clr color.Color) {
+	px := v.PosX + (float64(gopherSize)-TextWidth(txt))/2
+	g.AddFloatingTextAt(px, v.PosY+float64(gopherSize)/3, txt, clr)
+}
+
+func (g *Game) AddFloatingTextAt(px, py float64, txt string, clr color.Color) {
+	g.floatingTexts = append(g.floatingTexts, FloatingText{
+		Text:  txt,
+		Color: clr,
+		PosX:  px,
+		PosY:  py,
+		Frame: FloatingTextFrames,
+	})
+}
+
+func (g *Game) UpdateFloatingTexts() {
+	alive := g.floatingTexts[:0]
+	for _, ft := range g.floatingTexts {
+		ft.Frame--
+		ft.PosY -= 1
+		if ft.Frame > 0 {
+			alive = append(alive, ft)
+		}
+	}
+	g.floatingTexts = alive
+}
+
+func (g *Game) DrawFloatingTexts(screen *ebiten.Image) {
+	for _, ft := range g.floatingTexts {
+		DrawColoredTextAt(screen, ft.Text, ft.PosX, ft.PosY, ft.Color)
+	}
+}
+
+// DrawFight draws the turn indicator and the latest moves of the fight.
+func (g *Game) DrawFight(screen *ebiten.Image) {
+	fs := &g.FightState
+	if fs.CurrentTurn == "" {
+		return
+	}
+	p1, p2 := g.Viewers[fs.Player1], g.Viewers[fs.Player2]
+	curr := g.Viewers[fs.CurrentTurn]
+	title, turn, history := g.FightLines()
+
+	cx := (p1.PosX + p2.PosX + float64(gopherSize)) / 2
+	py := float64(Height) / 3
+	DrawTextAt(screen, title, cx-TextWidth(title)/2, py)
+
+	py += 24
+	DrawColoredTextAt(screen, turn, cx-TextWidth(turn)/2, py, ColorDefend)
+
+	// Marker above the head of the fighter that must choose a move
+	marker := "vvv"
+	DrawColoredTextAt(screen, marker,
+		curr.PosX+(float64(gopherSize)-TextWidth(marker))/2,
+		curr.PosY-64, ColorDefend)
+
+	for _, txt := range history {
+		py += 24
+		DrawTextAt(screen, txt, cx-TextWidth(txt)/2, py)
+	}
+}
+
+// FightLines returns the texts shown during fights: the fighters, whose
+// turn it is and the latest moves. The title is empty out of fights.
+func (g *Game) FightLines() (title, turn string, history []string) {
+	fs := &g.FightState
+	if fs.CurrentTurn == "" {
+		return "", "", nil
+	}
+	p1, p2 := g.Viewers[fs.Player1], g.Viewers[fs.Player2]
+	title = fmt.Sprintf("%s vs %s", p1.Name, p2.Name)
+	remaining := max(0, int(time.Until(fs.TurnDeadline).Seconds()))
+	turn = fmt.Sprintf("Vez de %s: %ds", g.Viewers[fs.CurrentTurn].Name, remaining)
+	moves := fs.History
+	if len(moves) > FightHistorySize {
+		moves = moves[len(moves)-FightHistorySize:]
+	}
+	for _, m := range moves {
+		history = append(history, m.String())
+	}
+	return title, turn, history
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func newTestFight() (*Game, *Viewer, *Viewer) {
+	g := New()
+	for _, uid := range []string{"p1", "p2"} {
+		v := NewViewer()
+		v.UID = uid
+		v.Name = uid
+		v.XP = 100
+		g.Viewers[uid] = v
+		g.UIDs = append(g.UIDs, uid)
+		g.FightingQueue[uid] = true
+	}
+	fighters := g.SortFighters()
+	g.StartFight(fighters[0], fighters[1], 0)
+	return g, g.Viewers[g.FightState.Player1], g.Viewers[g.FightState.Player2]
+}
+
+func TestFighterCanPlay(t *testing.T) {
+	testCases := []struct {
+		fighter Fighter
+		move    string
+		wantErr bool
+	}{
+		{Fighter{}, MoveAttack, false},
+		{Fighter{}, MoveSpecial, false},
+		{Fighter{SpecialCooldown: 1}, MoveSpecial, false},
+		{Fighter{SpecialCooldown: 2}, MoveSpecial, true},
+		{Fighter{Cooldowns: map[string]int{"hadouken": 1}}, "hadouken", false},
+		{Fighter{Cooldowns: map[string]int{"hadouken": 2}}, "hadouken", true},
+		{Fighter{Heals: MaxHeals - 1}, MoveHeal, false},
+		{Fighter{Heals: MaxHeals}, MoveHeal, true},
+	}
+	for tn, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			err := tc.fighter.CanPlay(tc.move)
+			if tc.wantErr && err == nil {
+				t.Errorf("wanted error but got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("wanted no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestChooseMove(t *testing.T) {
+	g, p1, p2 := newTestFight()
+
+	outsider := NewViewer()
+	outsider.UID = "outsider"
+	g.ChooseMove(outsider, MoveAttack)
+	if _, ok := g.FightState.Fighters[outsider.UID]; ok {
+		t.Errorf("viewers outside the fight must not choose moves")
+	}
+
+	g.FightState.Fighter(p1.UID).SpecialCooldown = 2
+	g.ChooseMove(p1, MoveSpecial)
+	if move := g.FightState.Fighter(p1.UID).Move; move != "" {
+		t.Errorf("special move on cooldown was accepted: %v", move)
+	}
+
+	g.ChooseMove(p2, MoveDefend)
+	if move := g.FightState.Fighter(p2.UID).Move; move != MoveDefend {
+		t.Errorf("unexpected move: want: %v, got: %v", MoveDefend, move)
+	}
+}
+
+func TestPlayMove(t *testing.T) {
+	g, p1, p2 := newTestFight()
+	if g.FightState.CurrentTurn != p2.UID {
+		t.Fatalf("unexpected first turn: want: %v, got: %v", p2.UID, g.FightState.CurrentTurn)
+	}
+
+	p2.HP = 50
+	g.PlayMove(p2.UID, MoveHeal, false)
+	if p2.HP < 65 || p2.HP > 75 {
+		t.Errorf("unexpected HP after healing: %v", p2.HP)
+	}
+	if g.FightState.CurrentTurn != p1.UID {
+		t.Errorf("turn did not change after the move")
+	}
+
+	g.PlayMove(p1.UID, MoveDefend, true)
+	if !g.FightState.Fighter(p1.UID).Defending {
+		t.Errorf("fighter should be defending")
+	}
+	if len(g.FightState.History) != 2 || !g.FightState.History[1].Auto {
+		t.Errorf("unexpected history: %v", g.FightState.History)
+	}
+
+	p1.HP = 1
+	g.PlayMove(p2.UID, MoveAttack, false)
+	if p1.HP != 1 || !g.FightState.Striking || p2.Animation != "attack" {
+		t.Fatalf("the damage should wait for the hit of the attack animation")
+	}
+	for range 2 * ebiten.TPS() {
+		p2.UpdateAnimation(g)
+	}
+	if g.FightState.CurrentTurn != "" {
+		t.Errorf("fight should be over")
+	}
+	if p2.FightsWon != 1 {
+		t.Errorf("unexpected fights won: want: 1, got: %v", p2.FightsWon)
+	}
+	if p1.HP != 100 || p2.HP != 100 {
+		t.Errorf("HP not restored after the fight: %v, %v", p1.HP, p2.HP)
+	}
+}
+
+func TestStrike(t *testing.T) {
+	g, p1, p2 := newTestFight()
+	g.PlayMove(p2.UID, MoveAttack, false)
+	if !p2.facingLeft {
+		t.Errorf("the attack should face the opponent")
+	}
+	ticks := 0
+	for ; p1.HP == 100 && ticks < 2*ebiten.TPS(); ticks++ {
+		p2.UpdateAnimation(g)
+	}
+	if p1.HP == 100 {
+		t.Fatalf("the attack did not hit")
+	}
+	// The hit frame is shown after 300ms
+	if hit := ebiten.TPS() * 3 / 10; ticks < hit-1 || ticks > hit+1 {
+		t.Errorf("expected the hit after %d ticks, got %d", hit, ticks)
+	}
+	hp := p1.HP
+	for range 2 * ebiten.TPS() {
+		p2.UpdateAnimation(g)
+	}
+	if p1.HP != hp {
+		t.Errorf("the attack hit twice")
+	}
+	if g.FightState.Striking || p2.Animation != "fight_standing" || !p2.facingLeft {
+		t.Errorf("expected the fight stance after the attack, got %v", p2.Animation)
+	}
+}
+
+func TestStrikeInterrupted(t *testing.T) {
+	g, p1, p2 := newTestFight()
+	g.PlayMove(p2.UID, MoveAttack, false)
+	// Another animation drops the handler of the attack
+	p2.Play("standing")
+	for range 2 * ebiten.TPS() {
+		p2.UpdateAnimation(g)
+	}
+	if !g.FightState.Striking || p1.HP != 100 {
+		t.Fatalf("expected the strike to wait for its deadline")
+	}
+	g.FightState.StrikeDeadline = time.Now().Add(-time.Second)
+	g.FightRound()
+	if g.FightState.Striking || p1.HP == 100 {
+		t.Errorf("expected the move resolved after the deadline")
+	}
+}
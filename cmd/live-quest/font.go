@@ -0,0 +1,267 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/bitmapfont/v3"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	// FontFiles are TTF or OTF fonts, separated by commas. Each one is a
+	// fallback for the runes missing in the previous, like an emoji font
+	// after the text font; the built-in bitmap font is the last one.
+	FontFiles string
+
+	// FontSize is the text size, in pixels.
+	FontSize = 24.0
+
+	// face is the font of the texts, set by LoadFonts.
+	face font.Face = bitmapFace(FontSize)
+
+	// fontFiles are the fonts that loaded, opened again by FaceAt for
+	// other sizes.
+	fontFiles []string
+
+	// sizedFaces are the faces of the sizes other than the FontSize.
+	sizedFaces = make(map[float64]font.Face)
+)
+
+// bitmapFontSize is the size of the built-in bitmap font, which is drawn
+// stretched to the FontSize.
+const bitmapFontSize = 12
+
+// LoadFonts sets the face used to draw texts. Fonts that fail to load are
+// logged and skipped.
+func LoadFonts(files string) {
+	var faces []font.Face
+	fontFiles = nil
+	for _, name := range strings.Split(files, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		f, err := LoadFont(name, FontSize)
+		if err != nil {
+			log.E("font: unable to load %v: %v", name, err)
+			continue
+		}
+		log.I("font: loaded %v", name)
+		fontFiles = append(fontFiles, name)
+		faces = append(faces, f)
+	}
+	face = withFallback(faces, FontSize)
+	sizedFaces = make(map[float64]font.Face)
+}
+
+// withFallback returns the faces followed by the bitmap font of the size.
+func withFallback(faces []font.Face, size float64) font.Face {
+	faces = append(faces, bitmapFace(size))
+	if len(faces) == 1 {
+		return faces[0]
+	}
+	return &fallbackFace{faces: faces}
+}
+
+// FaceAt returns the font of the texts with the size in pixels, loading
+// the fonts again at that size, so they stay sharp.
+func FaceAt(size float64) font.Face {
+	if size <= 0 || size == FontSize {
+		return face
+	}
+	if f, ok := sizedFaces[size]; ok {
+		return f
+	}
+	var faces []font.Face
+	for _, name := range fontFiles {
+		f, err := LoadFont(name, size)
+		if err != nil {
+			log.E("font: unable to load %v at %vpx: %v", name, size, err)
+			continue
+		}
+		faces = append(faces, f)
+	}
+	f := withFallback(faces, size)
+	sizedFaces[size] = f
+	return f
+}
+
+// LoadFont loads a TTF or OTF font from the file with the size in pixels.
+func LoadFont(name string, size float64) (font.Face, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := opentype.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// bitmapFace returns the built-in font stretched to the size. It is a bit
+// condensed vertically, as the game always drew it.
+func bitmapFace(size float64) font.Face {
+	sx := size / bitmapFontSize
+	return &scaledFace{Face: bitmapfont.Face, sx: sx, sy: sx * 0.9}
+}
+
+// fallbackFace draws each rune with the first face that has it.
+type fallbackFace struct {
+	faces []font.Face
+}
+
+func (f *fallbackFace) pick(r rune) font.Face {
+	for _, face := range f.faces {
+		if _, ok := face.GlyphAdvance(r); ok {
+			return face
+		}
+	}
+	return f.faces[0]
+}
+
+func (f *fallbackFace) Close() error {
+	for _, face := range f.faces {
+		face.Close()
+	}
+	return nil
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	return f.pick(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	return f.pick(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	return f.pick(r).GlyphAdvance(r)
+}
+
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if face := f.pick(r0); face == f.pick(r1) {
+		return face.Kern(r0, r1)
+	}
+	return 0
+}
+
+// Metrics are the largest of the faces, so lines fit all of them.
+func (f *fallbackFace) Metrics() font.Metrics {
+	var m font.Metrics
+	for i, face := range f.faces {
+		fm := face.Metrics()
+		if i == 0 {
+			m = fm
+			continue
+		}
+		m.Height = max(m.Height, fm.Height)
+		m.Ascent = max(m.Ascent, fm.Ascent)
+		m.Descent = max(m.Descent, fm.Descent)
+	}
+	return m
+}
+
+// scaledFace stretches the glyphs of a face, keeping the hard edges of
+// bitmap fonts.
+type scaledFace struct {
+	font.Face
+	sx, sy float64
+}
+
+func scale(v fixed.Int26_6, s float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(float64(v) * s))
+}
+
+func (f *scaledFace) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	sdr, smask, smaskp, sadvance, ok := f.Face.Glyph(fixed.Point26_6{}, r)
+	if !ok {
+		return
+	}
+	m := &scaledMask{
+		src:     smask,
+		srcRect: sdr,
+		offset:  smaskp.Sub(sdr.Min),
+		sx:      f.sx,
+		sy:      f.sy,
+		rect: image.Rect(
+			int(math.Floor(float64(sdr.Min.X)*f.sx)), int(math.Floor(float64(sdr.Min.Y)*f.sy)),
+			int(math.Ceil(float64(sdr.Max.X)*f.sx)), int(math.Ceil(float64(sdr.Max.Y)*f.sy)),
+		),
+	}
+	return m.rect.Add(image.Pt(dot.X.Round(), dot.Y.Round())), m, m.rect.Min, scale(sadvance, f.sx), true
+}
+
+func (f *scaledFace) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	bounds, advance, ok = f.Face.GlyphBounds(r)
+	bounds.Min.X, bounds.Max.X = scale(bounds.Min.X, f.sx), scale(bounds.Max.X, f.sx)
+	bounds.Min.Y, bounds.Max.Y = scale(bounds.Min.Y, f.sy), scale(bounds.Max.Y, f.sy)
+	return bounds, scale(advance, f.sx), ok
+}
+
+func (f *scaledFace) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	advance, ok = f.Face.GlyphAdvance(r)
+	return scale(advance, f.sx), ok
+}
+
+func (f *scaledFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return scale(f.Face.Kern(r0, r1), f.sx)
+}
+
+func (f *scaledFace) Metrics() font.Metrics {
+	m := f.Face.Metrics()
+	m.Height = scale(m.Height, f.sy)
+	m.Ascent = scale(m.Ascent, f.sy)
+	m.Descent = scale(m.Descent, f.sy)
+	m.XHeight = scale(m.XHeight, f.sy)
+	m.CapHeight = scale(m.CapHeight, f.sy)
+	return m
+}
+
+// scaledMask is a glyph mask stretched with the nearest pixel, in the
+// coordinates of the glyph around the dot.
+type scaledMask struct {
+	src     image.Image
+	srcRect image.Rectangle
+	offset  image.Point
+	sx, sy  float64
+	rect    image.Rectangle
+}
+
+func (m *scaledMask) ColorModel() color.Model { return color.AlphaModel }
+
+func (m *scaledMask) Bounds() image.Rectangle { return m.rect }
+
+func (m *scaledMask) At(x, y int) color.Color {
+	p := image.Pt(int(math.Floor(float64(x)/m.sx)), int(math.Floor(float64(y)/m.sy)))
+	if !p.In(m.srcRect) {
+		return color.Alpha{}
+	}
+	return m.src.At(p.X+m.offset.X, p.Y+m.offset.Y)
+}
+
+// TextWidth returns the width of the text drawn with the current font.
+func TextWidth(txt string) float64 {
+	return TextWidthIn(face, txt)
+}
+
+// TextWidthIn returns the width of the text drawn with the face.
+func TextWidthIn(f font.Face, txt string) float64 {
+	return float64(font.MeasureString(f, txt).Ceil())
+}
+
+// TextHeight returns the height of a line of text.
+func TextHeight() float64 {
+	return float64(face.Metrics().Height.Ceil())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestTextWidth(t *testing.T) {
+	for tn, tc := range []struct {
+		txt    string
+		expect float64
+	}{
+		{txt: "gopher", expect: 6 * 12},
+		{txt: "ação", expect: 4 * 12},
+		{txt: "ronoaldo [12]", expect: 13 * 12},
+		{txt: "", expect: 0},
+	} {
+		t.Run(tc.txt, func(t *testing.T) {
+			if w := TextWidth(tc.txt); w != tc.expect {
+				t.Errorf("#%d: expected %v, got %v", tn, tc.expect, w)
+			}
+		})
+	}
+}
+
+func TestLoadFonts(t *testing.T) {
+	defer func(f font.Face) { face = f }(face)
+
+	ttf := filepath.Join(t.TempDir(), "goregular.ttf")
+	if err := os.WriteFile(ttf, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	LoadFonts(ttf + ", missing.ttf")
+	f, ok := face.(*fallbackFace)
+	if !ok || len(f.faces) != 2 {
+		t.Fatalf("expected the font and the bitmap fallback, got %#v", face)
+	}
+	if f.pick('é') != f.faces[0] {
+		t.Errorf("expected accents from the TTF font")
+	}
+	if f.pick('日') != f.faces[1] {
+		t.Errorf("expected CJK from the bitmap fallback")
+	}
+	if _, ok := face.GlyphAdvance('日'); !ok {
+		t.Errorf("expected a glyph for CJK")
+	}
+	if w := TextWidth("ii"); w >= TextWidth("WW") {
+		t.Errorf("expected a proportional font, got %v for ii", w)
+	}
+}
+
+// painted draws the text and counts the opaque pixels.
+func painted(f font.Face, txt string) (n int) {
+	dst := image.NewAlpha(image.Rect(0, 0, 200, 100))
+	d := font.Drawer{Dst: dst, Src: image.Opaque, Face: f, Dot: fixed.P(10, 50)}
+	d.DrawString(txt)
+	for _, a := range dst.Pix {
+		if a > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestScaledFace(t *testing.T) {
+	base := bitmapFace(bitmapFontSize)
+	double := &scaledFace{Face: base, sx: 2, sy: 2}
+	if n, n2 := painted(base, "Go!"), painted(double, "Go!"); n == 0 || n2 != 4*n {
+		t.Errorf("expected 4 times the %d pixels, got %d", n, n2)
+	}
+	if m, m2 := base.Metrics(), double.Metrics(); m2.Height != 2*m.Height {
+		t.Errorf("expected twice the height %v, got %v", m.Height, m2.Height)
+	}
+}
@@ -3,34 +3,201 @@ package main
 import (
 	"fmt"
 	"image/color"
+	"math"
 	"math/rand"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/codigolandia/live-quest/log"
+)
+
+var (
+	// MinKeyDistance is the minimum distance, in the CbCr plane, between
+	// the gopher color and the chroma-key background. Closer colors are
+	// removed along with the background by the streaming software.
+	MinKeyDistance = 0.3
+
+	// GradientUnlock allows two-tone gopher colors, like
+	// "!color azul vermelho".
+	GradientUnlock = Unlock{Kind: UnlockCosmetic, Name: "gradient"}
 )
 
-func SelectColor(message string) (c color.RGBA) {
-	err := fmt.Errorf("no color")
-	parts := strings.Fields(message)
+var (
+	colorTokens = regexp.MustCompile(`hsl\([^)]*\)|\S+`)
+	hslColor    = regexp.MustCompile(`^hsl\(\s*(-?[\d.]+)(?:deg)?\s*[, ]\s*([\d.]+)%?\s*[, ]\s*([\d.]+)%?\s*\)$`)
+)
 
-	if len(parts) >= 2 {
-		switch len(parts[1]) {
-		case 7:
-			_, err = fmt.Sscanf(parts[1], "#%02x%02x%02x", &c.R, &c.G, &c.B)
-		case 4:
-			_, err = fmt.Sscanf(parts[1], "#%1x%1x%1x", &c.R, &c.G, &c.B)
-			// Double the hex digits:
-			c.R *= 17
-			c.G *= 17
-			c.B *= 17
-		default:
-			err = fmt.Errorf("invalid length, must be 7 or 4")
+// ParseColor parses a color in the formats #rgb, #rrggbb,
+// hsl(h, s%, l%) or a CSS color name, in English or Portuguese.
+func ParseColor(spec string) (c color.RGBA, err error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	c.A = 0xff
+	switch {
+	case strings.HasPrefix(spec, "#") && len(spec) == 7:
+		_, err = fmt.Sscanf(spec, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case strings.HasPrefix(spec, "#") && len(spec) == 4:
+		_, err = fmt.Sscanf(spec, "#%1x%1x%1x", &c.R, &c.G, &c.B)
+		// Double the hex digits:
+		c.R *= 17
+		c.G *= 17
+		c.B *= 17
+	case strings.HasPrefix(spec, "#"):
+		err = fmt.Errorf("invalid length, must be 7 or 4")
+	case strings.HasPrefix(spec, "hsl("):
+		m := hslColor.FindStringSubmatch(spec)
+		if m == nil {
+			return c, fmt.Errorf("invalid hsl color: %v", spec)
 		}
+		h, _ := strconv.ParseFloat(m[1], 64)
+		s, _ := strconv.ParseFloat(m[2], 64)
+		l, _ := strconv.ParseFloat(m[3], 64)
+		if s > 100 || l > 100 {
+			return c, fmt.Errorf("invalid hsl color: %v", spec)
+		}
+		c = HSL(h, s/100, l/100)
+	default:
+		named, ok := ColorNames[spec]
+		if !ok {
+			return c, fmt.Errorf("unknown color name: %v", spec)
+		}
+		c = named
+	}
+	return c, err
+}
+
+// HSL converts hue in degrees, and saturation and lightness from 0 to 1,
+// to RGB.
+func HSL(h, s, l float64) color.RGBA {
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 60
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
 	}
-	if err != nil {
-		c.R = uint8(rand.Intn(255))
-		c.G = uint8(rand.Intn(126))
-		c.B = uint8(rand.Intn(255))
+	m := l - chroma/2
+	to8 := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v+m)) * 255))
 	}
+	return color.RGBA{to8(r), to8(g), to8(b), 0xff}
+}
 
-	c.A = 0xff
-	return c
+// KeyDistance returns the distance of the colors in the CbCr plane, that
+// ignores the brightness, like chroma-key filters do.
+func KeyDistance(a, b color.RGBA) float64 {
+	cbcr := func(c color.RGBA) (cb, cr float64) {
+		r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+		return -0.1687*r - 0.3313*g + 0.5*b, 0.5*r - 0.4187*g - 0.0813*b
+	}
+	cb1, cr1 := cbcr(a)
+	cb2, cr2 := cbcr(b)
+	return math.Hypot(cb1-cb2, cr1-cr2)
+}
+
+// Readable returns true if the color does not vanish against the
+// chroma-key background. Any color is readable on transparent and image
+// backgrounds.
+func Readable(c color.RGBA) bool {
+	if !ScreenBackground.Keyed() {
+		return true
+	}
+	return KeyDistance(c, ScreenBackground.Color) >= MinKeyDistance
+}
+
+// RandomColor returns a saturated color that is readable on stream.
+func RandomColor(r *rand.Rand) color.RGBA {
+	for {
+		c := HSL(r.Float64()*360, 0.5+r.Float64()/2, 0.35+r.Float64()*0.4)
+		if Readable(c) {
+			return c
+		}
+	}
 }
+
+// HexColor formats the color as #rrggbb.
+func HexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// SelectColors parses the arguments of the !color command: one color, or
+// two for a gradient from the head to the feet. Without arguments or with
+// "random", a random color is picked.
+func SelectColors(message string, r *rand.Rand) (colors []color.RGBA, err error) {
+	args := strings.ToLower(message)
+	if i := strings.Index(args, "!color"); i >= 0 {
+		args = args[i+len("!color"):]
+	}
+	tokens := colorTokens.FindAllString(args, -1)
+	if len(tokens) == 0 {
+		tokens = []string{"random"}
+	}
+	// Names with more than one word, like "azul claro"
+	if _, ok := ColorNames[strings.Join(tokens, "")]; ok {
+		tokens = []string{strings.Join(tokens, "")}
+	}
+	if len(tokens) > 2 {
+		return nil, fmt.Errorf("too many colors")
+	}
+	for _, t := range tokens {
+		if t == "random" || t == "aleatorio" || t == "aleatório" {
+			colors = append(colors, RandomColor(r))
+			continue
+		}
+		c, err := ParseColor(t)
+		if err != nil {
+			return nil, err
+		}
+		if !Readable(c) {
+			return nil, errUnreadable
+		}
+		colors = append(colors, c)
+	}
+	return colors, nil
+}
+
+var errUnreadable = fmt.Errorf("color is too close to the background")
+
+// ColorCommand handles !color, confirming the new color in the chat.
+func (g *Game) ColorCommand(v *Viewer, text string) {
+	colors, err := SelectColors(text, colorRand)
+	switch {
+	case err == errUnreadable:
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: essa cor some no fundo da live, escolha outra", v.Name))
+		return
+	case err != nil:
+		log.D("color: %v: %v", v.Name, err)
+		g.SendMessage(v.Platform, fmt.Sprintf(
+			"@%s: cor inválida; use um nome (azul, red), #rgb, #rrggbb, hsl(200, 80%%, 60%%) ou random", v.Name))
+		return
+	case len(colors) == 2 && !v.HasUnlocked(GradientUnlock):
+		msg := fmt.Sprintf("@%s: cores em degradê ainda não estão disponíveis", v.Name)
+		if l := Levels.RequiredLevel(GradientUnlock); l >= 0 {
+			msg += fmt.Sprintf(" (nível %d)", l)
+		}
+		g.SendMessage(v.Platform, msg)
+		return
+	}
+
+	v.SpriteColor, v.SpriteColor2 = colors[0], color.RGBA{}
+	msg := fmt.Sprintf("@%s: seu gopher agora é %s", v.Name, HexColor(colors[0]))
+	if len(colors) == 2 {
+		v.SpriteColor2 = colors[1]
+		msg += " com degradê para " + HexColor(colors[1])
+	}
+	log.D("color: %v changed the gopher color", v.Name)
+	g.SendMessage(v.Platform, msg)
+}
+
+var colorRand = rand.New(rand.NewSource(rand.Int63()))
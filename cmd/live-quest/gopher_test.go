@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for tn, tc := range []struct {
+		spec   string
+		expect color.RGBA
+		err    bool
+	}{
+		{spec: "#ff8000", expect: color.RGBA{0xff, 0x80, 0x00, 0xff}},
+		{spec: "#F80", expect: color.RGBA{0xff, 0x88, 0x00, 0xff}},
+		{spec: "#ff80", err: true},
+		{spec: "#zzzzzz", err: true},
+		{spec: "red", expect: color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{spec: "RebeccaPurple", expect: color.RGBA{0x66, 0x33, 0x99, 0xff}},
+		{spec: "azul", expect: color.RGBA{0x00, 0x00, 0xff, 0xff}},
+		{spec: "verde", expect: color.RGBA{0x00, 0x80, 0x00, 0xff}},
+		{spec: "hsl(0, 100%, 50%)", expect: color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{spec: "hsl(240 100% 25%)", expect: color.RGBA{0x00, 0x00, 0x80, 0xff}},
+		{spec: "hsl(120deg, 100%, 50%)", expect: color.RGBA{0x00, 0xff, 0x00, 0xff}},
+		{spec: "hsl(0, 200%, 50%)", err: true},
+		{spec: "hsl(0, 50%)", err: true},
+		{spec: "azul-bebê", err: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			c, err := ParseColor(tc.spec)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.err && c != tc.expect {
+				t.Errorf("expected %v, got %v", tc.expect, c)
+			}
+		})
+	}
+}
+
+func TestReadable(t *testing.T) {
+	for tn, tc := range []struct {
+		c      color.RGBA
+		expect bool
+	}{
+		{c: ColorGopherBlue, expect: true},
+		{c: ColorNames["black"], expect: true},
+		{c: ColorNames["white"], expect: true},
+		{c: ColorNames["yellow"], expect: true},
+		{c: ColorNames["red"], expect: true},
+		{c: ColorGreen, expect: false},
+		{c: ColorNames["verde"], expect: false},
+		{c: ColorNames["limegreen"], expect: false},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if got := Readable(tc.c); got != tc.expect {
+				t.Errorf("expected %v for %v (distance %.2f)", tc.expect, tc.c, KeyDistance(tc.c, ColorGreen))
+			}
+		})
+	}
+}
+
+func TestSelectColors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for tn, tc := range []struct {
+		text  string
+		count int
+		err   error
+	}{
+		{text: "!color", count: 1},
+		{text: "!color random", count: 1},
+		{text: "!color azul", count: 1},
+		{text: "!color azul claro", count: 1},
+		{text: "!color hsl(200, 80%, 60%)", count: 1},
+		{text: "!color azul #f00", count: 2},
+		{text: "!color hsl(200, 80%, 60%) random", count: 2},
+		{text: "!color lime", err: errUnreadable},
+		{text: "!color azul vermelho amarelo", err: fmt.Errorf("too many colors")},
+		{text: "!color azulzinho", err: fmt.Errorf("unknown color name: azulzinho")},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			colors, err := SelectColors(tc.text, r)
+			if fmt.Sprint(err) != fmt.Sprint(tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if len(colors) != tc.count {
+				t.Errorf("expected %d colors, got %v", tc.count, colors)
+			}
+			for _, c := range colors {
+				if !Readable(c) {
+					t.Errorf("unreadable color selected: %v", c)
+				}
+			}
+		})
+	}
+}
+
+func TestColorCommandGradient(t *testing.T) {
+	g := newTestViewers("a")
+	v := g.Viewers["a"]
+	g.ColorCommand(v, "!color azul vermelho")
+	if v.SpriteColor2.A != 0 {
+		t.Errorf("gradients should be locked")
+	}
+	v.Unlock(GradientUnlock)
+	g.ColorCommand(v, "!color azul vermelho")
+	if v.SpriteColor != ColorNames["azul"] || v.SpriteColor2 != ColorNames["vermelho"] {
+		t.Errorf("unexpected colors: %v, %v", v.SpriteColor, v.SpriteColor2)
+	}
+	g.ColorCommand(v, "!color lime")
+	if v.SpriteColor != ColorNames["azul"] {
+		t.Errorf("unreadable colors should be rejected, got %v", v.SpriteColor)
+	}
+	g.ColorCommand(v, "!color roxo")
+	if v.SpriteColor != ColorNames["roxo"] || v.SpriteColor2.A != 0 {
+		t.Errorf("expected a single color, got %v, %v", v.SpriteColor, v.SpriteColor2)
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/message"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// WidgetType is the content of a HUD panel.
+type WidgetType string
+
+const (
+	WidgetTopXP      WidgetType = "topXP"
+	WidgetTopToday   WidgetType = "topToday"
+	WidgetFightQueue WidgetType = "fightQueue"
+	WidgetChallenge  WidgetType = "challenge"
+	WidgetPlatforms  WidgetType = "platforms"
+)
+
+// Anchor is the corner of the screen where a HUD panel is placed.
+type Anchor string
+
+const (
+	AnchorTopLeft     Anchor = "top-left"
+	AnchorTopRight    Anchor = "top-right"
+	AnchorBottomLeft  Anchor = "bottom-left"
+	AnchorBottomRight Anchor = "bottom-right"
+)
+
+func (a Anchor) right() bool  { return strings.HasSuffix(string(a), "right") }
+func (a Anchor) bottom() bool { return strings.HasPrefix(string(a), "bottom") }
+
+// Widget is a panel of the HUD. Widgets at the same anchor are stacked in
+// the order of the config, and X and Y are the distance from the corner,
+// or from the previous panel.
+type Widget struct {
+	Type     WidgetType `json:"type"`
+	Anchor   Anchor     `json:"anchor"`
+	X        float64    `json:"x"`
+	Y        float64    `json:"y"`
+	FontSize float64    `json:"fontSize"`
+	Max      int        `json:"max"`
+	Title    string     `json:"title"`
+	Hidden   bool       `json:"hidden"`
+}
+
+// HUDConfig is the layout of the panels drawn over the game.
+type HUDConfig struct {
+	Background string   `json:"background"`
+	Opacity    float64  `json:"opacity"`
+	Padding    float64  `json:"padding"`
+	Spacing    float64  `json:"spacing"`
+	Widgets    []Widget `json:"widgets"`
+}
+
+// Validate checks the widget types and anchors.
+func (c HUDConfig) Validate() error {
+	if _, err := ParseColor(c.Background); err != nil {
+		return fmt.Errorf("invalid background: %w", err)
+	}
+	for i, w := range c.Widgets {
+		switch w.Type {
+		case WidgetTopXP, WidgetTopToday, WidgetFightQueue, WidgetChallenge, WidgetPlatforms:
+		default:
+			return fmt.Errorf("widget #%d: unknown type %q", i, w.Type)
+		}
+		switch w.Anchor {
+		case AnchorTopLeft, AnchorTopRight, AnchorBottomLeft, AnchorBottomRight:
+		default:
+			return fmt.Errorf("widget #%d: unknown anchor %q", i, w.Anchor)
+		}
+	}
+	return nil
+}
+
+var (
+	HUDFile = "hud.json"
+
+	// HUD is the top XP with the fight queue beneath it, at the top right
+	// corner, until a config is loaded.
+	HUD = HUDConfig{
+		Background: "#000000",
+		Opacity:    0.5,
+		Padding:    8,
+		Spacing:    8,
+		Widgets: []Widget{
+			{Type: WidgetTopXP, Anchor: AnchorTopRight, X: 12, Y: 12, Max: 5},
+			{Type: WidgetFightQueue, Anchor: AnchorTopRight, X: 12, Max: 10},
+		},
+	}
+
+	ColorHUDTitle   = color.RGBA{0xff, 0xd7, 0x00, 0xff}
+	ColorOnline     = color.RGBA{0x40, 0xe0, 0x40, 0xff}
+	ColorOffline    = color.RGBA{0xe0, 0x40, 0x40, 0xff}
+	DefaultHUDTitle = map[WidgetType]string{
+		WidgetTopXP:      "Top XP",
+		WidgetTopToday:   "Top do dia",
+		WidgetFightQueue: "Fila de luta",
+		WidgetChallenge:  "Desafio",
+		WidgetPlatforms:  "Plataformas",
+	}
+)
+
+func LoadHUD(fileName string) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		log.E("hud: error loading %v, using the default layout: %v", fileName, err)
+		return
+	}
+	var hc HUDConfig
+	if err := json.Unmarshal(b, &hc); err != nil {
+		log.E("hud: error deserializing %v: %v", fileName, err)
+		return
+	}
+	if err := hc.Validate(); err != nil {
+		log.E("hud: %v: %v", fileName, err)
+		return
+	}
+	HUD = hc
+	log.I("hud loaded: %d widgets", len(hc.Widgets))
+}
+
+// HUDRow is a line of a panel. The first cell is aligned to the left and
+// the others, usually numbers, to the right; a single cell may be wider
+// than the first column.
+type HUDRow struct {
+	Cells []string
+	Color color.Color
+}
+
+// HUDContent is what a widget shows; widgets without rows are not drawn.
+type HUDContent struct {
+	Title string
+	Rows  []HUDRow
+}
+
+// HUDText is a text of a panel at its baseline; right aligned texts end
+// at X.
+type HUDText struct {
+	Text  string  `json:"text"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Right bool    `json:"right,omitempty"`
+	Color string  `json:"color"`
+}
+
+// HUDPanel is a widget laid out on the screen: the background box and its
+// texts, drawn with a font Scale times the FontSize.
+type HUDPanel struct {
+	X          float64   `json:"x"`
+	Y          float64   `json:"y"`
+	W          float64   `json:"w"`
+	H          float64   `json:"h"`
+	Scale      float64   `json:"scale"`
+	Background string    `json:"background"`
+	Opacity    float64   `json:"opacity"`
+	Texts      []HUDText `json:"texts"`
+}
+
+// textMetrics measures the texts of a font size.
+type textMetrics struct {
+	Width          func(string) float64
+	Height, Ascent float64
+}
+
+// fontMetrics measures the texts with the face of the size.
+func fontMetrics(size float64) textMetrics {
+	f := FaceAt(size)
+	m := f.Metrics()
+	return textMetrics{
+		Width:  func(txt string) float64 { return TextWidthIn(f, txt) },
+		Height: float64(m.Height.Ceil()),
+		Ascent: float64(m.Ascent.Ceil()),
+	}
+}
+
+// Layout places the visible widgets on a screen of w x h, with the content
+// of each one and the texts measured at its font size.
+func (c HUDConfig) Layout(content func(Widget) *HUDContent, metrics func(size float64) textMetrics, w, h float64) []HUDPanel {
+	var panels []HUDPanel
+	stacked := make(map[Anchor]float64)
+	for _, wg := range c.Widgets {
+		if wg.Hidden {
+			continue
+		}
+		hc := content(wg)
+		if hc == nil || len(hc.Rows) == 0 {
+			continue
+		}
+		size := wg.FontSize
+		if size <= 0 {
+			size = FontSize
+		}
+		p := c.layoutPanel(hc, metrics(size))
+		p.Scale = size / FontSize
+		p.X = wg.X
+		if wg.Anchor.right() {
+			p.X = w - wg.X - p.W
+		}
+		p.Y = stacked[wg.Anchor] + wg.Y
+		stacked[wg.Anchor] = p.Y + p.H + c.Spacing
+		if wg.Anchor.bottom() {
+			p.Y = h - p.Y - p.H
+		}
+		for i := range p.Texts {
+			p.Texts[i].X += p.X
+			p.Texts[i].Y += p.Y
+		}
+		panels = append(panels, p)
+	}
+	return panels
+}
+
+// layoutPanel sizes the panel to the measured texts, with them relative to
+// its top left corner.
+func (c HUDConfig) layoutPanel(hc *HUDContent, m textMetrics) HUDPanel {
+	lineH, gap := m.Height, m.Height/2
+	var cols []float64
+	span := 0.0
+	if hc.Title != "" {
+		span = m.Width(hc.Title)
+	}
+	for _, row := range hc.Rows {
+		if len(row.Cells) == 1 {
+			span = max(span, m.Width(row.Cells[0]))
+			continue
+		}
+		for j, cell := range row.Cells {
+			if j == len(cols) {
+				cols = append(cols, 0)
+			}
+			cols[j] = max(cols[j], m.Width(cell))
+		}
+	}
+	table := 0.0
+	for j, cw := range cols {
+		if j > 0 {
+			table += gap
+		}
+		table += cw
+	}
+	inner := max(table, span)
+	lines := len(hc.Rows)
+	if hc.Title != "" {
+		lines++
+	}
+	p := HUDPanel{
+		W:          inner + 2*c.Padding,
+		H:          float64(lines)*lineH + 2*c.Padding,
+		Background: c.Background,
+		Opacity:    c.Opacity,
+	}
+	py := c.Padding + m.Ascent
+	if hc.Title != "" {
+		p.Texts = append(p.Texts, HUDText{Text: hc.Title, X: c.Padding, Y: py, Color: HexColor(ColorHUDTitle)})
+		py += lineH
+	}
+	for _, row := range hc.Rows {
+		clr := "#ffffff"
+		if row.Color != nil {
+			clr = HexColor(color.RGBAModel.Convert(row.Color).(color.RGBA))
+		}
+		// The right aligned columns end at the right of the panel, and the
+		// first one takes the space left by wider titles
+		right := p.W - c.Padding
+		for j := len(row.Cells) - 1; j >= 0; j-- {
+			t := HUDText{Text: row.Cells[j], X: c.Padding, Y: py, Color: clr}
+			if j > 0 {
+				t.X, t.Right = right, true
+				right -= cols[j] + gap
+			}
+			p.Texts = append(p.Texts, t)
+		}
+		py += lineH
+	}
+	return p
+}
+
+// HUDContent returns the rows of the widget, or nil when it has nothing
+// to show.
+func (g *Game) HUDContent(w Widget) *HUDContent {
+	n := w.Max
+	if n <= 0 {
+		n = 5
+	}
+	hc := &HUDContent{Title: w.Title}
+	if hc.Title == "" {
+		hc.Title = DefaultHUDTitle[w.Type]
+	}
+	switch w.Type {
+	case WidgetTopXP:
+		for _, v := range g.TopViewers(n) {
+			hc.Rows = append(hc.Rows, HUDRow{Cells: []string{
+				v.Name, fmt.Sprintf("[%d]", v.Level()), fmt.Sprintf("%d XP", v.XP)}})
+		}
+	case WidgetTopToday:
+		for _, v := range g.TopToday(n) {
+			hc.Rows = append(hc.Rows, HUDRow{Cells: []string{
+				v.Name, fmt.Sprintf("+%d XP", v.DayXP)}})
+		}
+	case WidgetFightQueue:
+		fighters := g.SortFighters()
+		for i, v := range fighters {
+			if i == n {
+				hc.Rows = append(hc.Rows, HUDRow{Cells: []string{
+					fmt.Sprintf("... e mais %d", len(fighters)-n)}})
+				break
+			}
+			hc.Rows = append(hc.Rows, HUDRow{Cells: []string{
+				fmt.Sprintf("%d. %s", i+1, v.Name)}})
+		}
+	case WidgetChallenge:
+		ch, ok := FindChallenge(g.ActiveChallenge)
+		if !ok {
+			return nil
+		}
+		hc.Title += ": " + ch.Code
+		hc.Rows = []HUDRow{
+			{Cells: []string{fmt.Sprintf("!check %s <link>", ch.Code)}},
+			{Cells: []string{"Recompensa", fmt.Sprintf("%d XP", ch.Reward)}},
+			{Cells: []string{"Resolvido por", strconv.Itoa(g.Solved(ch.Code))}},
+		}
+	case WidgetPlatforms:
+		for _, ps := range PlatformStatus(time.Now()) {
+			status, clr := "offline", ColorOffline
+			if ps.Online {
+				status, clr = "online", ColorOnline
+			}
+			hc.Rows = append(hc.Rows, HUDRow{Cells: []string{ps.Name, status}, Color: clr})
+		}
+	}
+	return hc
+}
+
+// HUDPanels lays out the HUD with the current game state.
+func (g *Game) HUDPanels() []HUDPanel {
+	return HUD.Layout(g.HUDContent, fontMetrics, float64(Width), float64(Height))
+}
+
+// TopToday returns the n viewers who earned more XP today.
+func (g *Game) TopToday(n int) []*Viewer {
+	today := time.Now().Format(dayFormat)
+	viewers := make([]*Viewer, 0, len(g.UIDs))
+	for _, uid := range g.UIDs {
+		if v := g.Viewers[uid]; v.XPDay == today && v.DayXP > 0 {
+			viewers = append(viewers, v)
+		}
+	}
+	sort.SliceStable(viewers, func(i, j int) bool {
+		return viewers[i].DayXP > viewers[j].DayXP
+	})
+	if len(viewers) > n {
+		viewers = viewers[:n]
+	}
+	return viewers
+}
+
+// Solved returns how many viewers completed the challenge.
+func (g *Game) Solved(code string) int {
+	n := 0
+	for _, v := range g.Viewers {
+		if _, ok := v.CompletedChallenges[code]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// ChatTimeout is how long a chat can go without being read before its
+// platform is shown offline; the Twitch server pings quiet chats every
+// five minutes.
+var ChatTimeout = 6 * time.Minute
+
+// Platform is the connection status of a live stream platform.
+type Platform struct {
+	Name   string
+	Online bool
+}
+
+// PlatformStatus returns whether the game read each chat recently.
+func PlatformStatus(now time.Time) []Platform {
+	online := func(lastRead time.Time) bool {
+		return !lastRead.IsZero() && now.Sub(lastRead) < ChatTimeout
+	}
+	return []Platform{
+		{Name: "YouTube", Online: online(yt.LastRead())},
+		{Name: "Twitch", Online: online(tw.LastRead())},
+	}
+}
+
+// ChallengeCommand handles the moderator command !challenge <code>, that
+// shows the challenge on the HUD, or !challenge off to hide it.
+func (g *Game) ChallengeCommand(m message.Message, v *Viewer) {
+	if !m.Moderator {
+		log.D("hud: %v is not a moderator", v.Name)
+		return
+	}
+	fields := strings.Fields(m.Text)
+	code := ""
+	for i, f := range fields {
+		if f == "!challenge" && i+1 < len(fields) {
+			code = fields[i+1]
+		}
+	}
+	switch code {
+	case "":
+		g.SendMessage(m.Platform, "@"+m.Author+": use !challenge <código> ou !challenge off")
+	case "off":
+		g.ActiveChallenge = ""
+		log.I("hud: challenge hidden by %v", v.Name)
+	default:
+		ch, ok := FindChallenge(code)
+		if !ok {
+			g.SendMessage(m.Platform,
+				fmt.Sprintf("@%s: código do desafio não reconhecido: %v", m.Author, code))
+			return
+		}
+		g.ActiveChallenge = ch.Code
+		log.I("hud: challenge %v started by %v", ch.Code, v.Name)
+		g.Broadcast(fmt.Sprintf("Novo desafio: resolva e envie com !check %s <link> para ganhar %d XP!",
+			ch.Code, ch.Reward))
+	}
+}
+
+// DrawHUD draws the panels of the HUD.
+func (g *Game) DrawHUD(screen *ebiten.Image) {
+	for _, p := range g.HUDPanels() {
+		f := FaceAt(p.Scale * FontSize)
+		bg, _ := ParseColor(p.Background)
+		opts := &ebiten.DrawImageOptions{}
+		opts.GeoM.Scale(p.W, p.H)
+		opts.GeoM.Translate(p.X, p.Y)
+		opts.ColorScale.ScaleWithColor(bg)
+		opts.ColorScale.ScaleAlpha(float32(p.Opacity))
+		screen.DrawImage(pixel, opts)
+		for _, t := range p.Texts {
+			clr, _ := ParseColor(t.Color)
+			px := t.X
+			if t.Right {
+				px -= TextWidthIn(f, t.Text)
+			}
+			DrawTextWith(screen, f, t.Text, px, t.Y, clr)
+		}
+	}
+}
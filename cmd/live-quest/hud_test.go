@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHUDLayout(t *testing.T) {
+	contents := map[string]*HUDContent{
+		"top": {Title: "Top", Rows: []HUDRow{
+			{Cells: []string{"ab", "[1]", "10 XP"}},
+			{Cells: []string{"abcdef", "[12]", "5 XP"}},
+		}},
+		"queue": {Rows: []HUDRow{{Cells: []string{"x"}}}},
+		"big":   {Rows: []HUDRow{{Cells: []string{"abc"}}}},
+		"empty": {Title: "Empty"},
+	}
+	cfg := HUDConfig{
+		Background: "#000000",
+		Padding:    5,
+		Spacing:    4,
+		Widgets: []Widget{
+			{Title: "top", Anchor: AnchorTopRight, X: 12, Y: 12},
+			{Title: "empty", Anchor: AnchorTopRight},
+			{Title: "queue", Anchor: AnchorTopRight, X: 12},
+			{Title: "top", Anchor: AnchorTopLeft, Hidden: true},
+			{Title: "big", Anchor: AnchorBottomLeft, X: 12, Y: 12, FontSize: FontSize * 2},
+		},
+	}
+	// Texts are 10px per rune and lines 20px at the FontSize
+	metrics := func(size float64) textMetrics {
+		s := size / FontSize
+		return textMetrics{
+			Width:  func(txt string) float64 { return 10 * s * float64(utf8.RuneCountInString(txt)) },
+			Height: 20 * s,
+			Ascent: 16 * s,
+		}
+	}
+	panels := cfg.Layout(func(w Widget) *HUDContent { return contents[w.Title] }, metrics, 1000, 500)
+	if len(panels) != 3 {
+		t.Fatalf("expected 3 panels, got %d", len(panels))
+	}
+	for tn, tc := range []struct {
+		x, y, w, h float64
+	}{
+		{x: 808, y: 12, w: 180, h: 70},
+		{x: 968, y: 86, w: 20, h: 30},
+		{x: 12, y: 438, w: 70, h: 50},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			p := panels[tn]
+			if p.X != tc.x || p.Y != tc.y || p.W != tc.w || p.H != tc.h {
+				t.Errorf("expected %vx%v at %v,%v, got %vx%v at %v,%v",
+					tc.w, tc.h, tc.x, tc.y, p.W, p.H, p.X, p.Y)
+			}
+		})
+	}
+	// The numbers end at the same column, whatever the name length
+	for tn, tc := range []struct {
+		text  string
+		x, y  float64
+		right bool
+	}{
+		{text: "Top", x: 813, y: 33},
+		{text: "10 XP", x: 983, y: 53, right: true},
+		{text: "[1]", x: 923, y: 53, right: true},
+		{text: "ab", x: 813, y: 53},
+		{text: "5 XP", x: 983, y: 73, right: true},
+		{text: "[12]", x: 923, y: 73, right: true},
+		{text: "abcdef", x: 813, y: 73},
+	} {
+		t.Run(fmt.Sprintf("text#%d", tn), func(t *testing.T) {
+			txt := panels[0].Texts[tn]
+			if txt.Text != tc.text || txt.X != tc.x || txt.Y != tc.y || txt.Right != tc.right {
+				t.Errorf("expected %q at %v,%v (right: %v), got %+v", tc.text, tc.x, tc.y, tc.right, txt)
+			}
+		})
+	}
+	if p := panels[2]; p.Scale != 2 || p.Texts[0].Y != 475 {
+		t.Errorf("expected the text at 2x the font size, got %+v", p)
+	}
+}
+
+func TestHUDValidate(t *testing.T) {
+	for tn, tc := range []struct {
+		cfg     HUDConfig
+		wantErr bool
+	}{
+		{cfg: HUD},
+		{cfg: HUDConfig{Background: "black", Widgets: []Widget{{Type: WidgetPlatforms, Anchor: AnchorBottomRight}}}},
+		{cfg: HUDConfig{Background: "black", Widgets: []Widget{{Type: "chat", Anchor: AnchorTopLeft}}}, wantErr: true},
+		{cfg: HUDConfig{Background: "black", Widgets: []Widget{{Type: WidgetTopXP, Anchor: "middle"}}}, wantErr: true},
+		{cfg: HUDConfig{Background: "nope"}, wantErr: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if err := tc.cfg.Validate(); tc.wantErr != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHUDContent(t *testing.T) {
+	g := newTestViewers("a", "b", "c")
+	a, b := g.Viewers["a"], g.Viewers["b"]
+	a.XP = 500
+	b.IncXP(30)
+	b.IncXP(20)
+	g.FightingQueue["a"], g.FightingQueue["b"], g.FightingQueue["c"] = true, true, true
+
+	if top := g.TopToday(5); len(top) != 1 || top[0] != b || b.DayXP != 50 {
+		t.Errorf("expected only b today with 50 XP, got %v", top)
+	}
+	if hc := g.HUDContent(Widget{Type: WidgetTopXP, Max: 2}); len(hc.Rows) != 2 || hc.Rows[0].Cells[0] != "a" {
+		t.Errorf("unexpected top XP: %+v", hc)
+	}
+	if hc := g.HUDContent(Widget{Type: WidgetFightQueue, Max: 2}); len(hc.Rows) != 3 || hc.Rows[2].Cells[0] != "... e mais 1" {
+		t.Errorf("unexpected fight queue: %+v", hc)
+	}
+	if hc := g.HUDContent(Widget{Type: WidgetChallenge}); hc != nil {
+		t.Errorf("expected no challenge, got %+v", hc)
+	}
+	Challenges = append(Challenges, Challenge{Code: "hud", Reward: 50})
+	defer func() { Challenges = Challenges[:len(Challenges)-1] }()
+	g.ActiveChallenge = "hud"
+	b.MarkCompleted("hud")
+	hc := g.HUDContent(Widget{Type: WidgetChallenge, Title: "Agora"})
+	if hc == nil || hc.Title != "Agora: hud" || hc.Rows[2].Cells[1] != "1" {
+		t.Errorf("unexpected challenge: %+v", hc)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	CurvePolynomial = "polynomial"
+	CurveTable      = "table"
+)
+
+// LevelCurve maps the accumulated XP of a viewer to levels.
+//
+// A polynomial curve requires Base * level^Exponent XP to reach a level.
+// A table curve lists the XP required for each level, starting at level 0;
+// levels past the end of the table keep the last XP increment.
+type LevelCurve struct {
+	Type     string  `json:"type"`
+	Base     float64 `json:"base"`
+	Exponent float64 `json:"exponent"`
+	Table    []int   `json:"table"`
+}
+
+// maxLevel is a safety net for bad curve configurations.
+const maxLevel = 999
+
+// XPForLevel returns the total XP required to reach the level.
+func (c LevelCurve) XPForLevel(level int) int {
+	if level <= 0 {
+		return 0
+	}
+	switch c.Type {
+	case CurveTable:
+		n := len(c.Table)
+		if level < n {
+			return c.Table[level]
+		}
+		step := XPPerLevel
+		if n >= 2 {
+			step = c.Table[n-1] - c.Table[n-2]
+		}
+		return c.Table[n-1] + (level-n+1)*step
+	default:
+		return int(math.Round(c.Base * math.Pow(float64(level), c.Exponent)))
+	}
+}
+
+// Level returns the level reached with the given amount of XP.
+func (c LevelCurve) Level(xp int) int {
+	if xp <= 0 {
+		return 0
+	}
+	if c.Type == CurveTable {
+		return sort.Search(maxLevel, func(l int) bool {
+			return c.XPForLevel(l+1) > xp
+		})
+	}
+	// Estimate using the inverse function, then fix rounding errors.
+	l := int(math.Pow(float64(xp)/c.Base, 1/c.Exponent))
+	l = max(0, min(l, maxLevel))
+	for l > 0 && c.XPForLevel(l) > xp {
+		l--
+	}
+	for l < maxLevel && c.XPForLevel(l+1) <= xp {
+		l++
+	}
+	return l
+}
+
+// Progress returns how far, from 0 to 1, the XP is from the next level.
+func (c LevelCurve) Progress(xp int) float64 {
+	l := c.Level(xp)
+	curr, next := c.XPForLevel(l), c.XPForLevel(l+1)
+	if next <= curr {
+		return 1
+	}
+	return float64(xp-curr) / float64(next-curr)
+}
+
+func (c LevelCurve) Validate() error {
+	switch c.Type {
+	case CurvePolynomial:
+		if c.Base <= 0 || c.Exponent <= 0 {
+			return fmt.Errorf("level: polynomial curve requires positive base and exponent")
+		}
+	case CurveTable:
+		if len(c.Table) < 2 || c.Table[0] != 0 {
+			return fmt.Errorf("level: table curve requires at least two levels, starting at 0 XP")
+		}
+		for i := 1; i < len(c.Table); i++ {
+			if c.Table[i] <= c.Table[i-1] {
+				return fmt.Errorf("level: table curve must be strictly increasing at level %d", i)
+			}
+		}
+	default:
+		return fmt.Errorf("level: invalid curve type: %#v", c.Type)
+	}
+	return nil
+}
+
+const (
+	UnlockCosmetic = "cosmetic"
+	UnlockMove     = "move"
+)
+
+// Unlock is a reward that becomes available to the viewer.
+type Unlock struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func (u Unlock) String() string {
+	return u.Kind + ":" + u.Name
+}
+
+// LevelConfig is the leveling configuration, loaded from levels.json.
+type LevelConfig struct {
+	Curve   LevelCurve       `json:"curve"`
+	Unlocks map[int][]Unlock `json:"unlocks"`
+}
+
+// UnlocksBetween returns the unlocks for levels in the range (from, to].
+func (lc LevelConfig) UnlocksBetween(from, to int) (unlocks []Unlock) {
+	for l := from + 1; l <= to; l++ {
+		unlocks = append(unlocks, lc.Unlocks[l]...)
+	}
+	return unlocks
+}
+
+// RequiredLevel returns the level that grants the unlock, or -1 if no
+// level grants it.
+func (lc LevelConfig) RequiredLevel(u Unlock) int {
+	required := -1
+	for l, unlocks := range lc.Unlocks {
+		for _, lu := range unlocks {
+			if lu == u && (required < 0 || l < required) {
+				required = l
+			}
+		}
+	}
+	return required
+}
+
+var (
+	LevelsFile = "levels.json"
+
+	Levels = LevelConfig{
+		Curve: LevelCurve{
+			Type:     CurvePolynomial,
+			Base:     XPPerLevel,
+			Exponent: 1.5,
+		},
+	}
+)
+
+func LoadLevels(fileName string) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		log.E("level: error loading %v, using default curve: %v", fileName, err)
+		return
+	}
+	var lc LevelConfig
+	if err := json.Unmarshal(b, &lc); err != nil {
+		log.E("level: error deserializing %v: %v", fileName, err)
+		return
+	}
+	if err := lc.Curve.Validate(); err != nil {
+		log.E("level: %v: %v", fileName, err)
+		return
+	}
+	Levels = lc
+	log.I("level curve loaded: %v, %d unlock levels", lc.Curve.Type, len(lc.Unlocks))
+}
+
+// LevelUp rewards the viewer that just reached a new level.
+func (g *Game) LevelUp(v *Viewer, level int) {
+	log.I("level: %v reached level %d", v.Name, level)
+	v.Celebrate()
+
+	unlocks := Levels.UnlocksBetween(v.RewardedLevel, level)
+	names := make([]string, 0, len(unlocks))
+	for _, u := range unlocks {
+		v.Unlock(u)
+		names = append(names, u.Name)
+	}
+	v.RewardedLevel = level
+
+	msg := fmt.Sprintf("@%s subiu para o nível %d!", v.Name, level)
+	if len(names) > 0 {
+		msg += " Desbloqueou: " + strings.Join(names, ", ")
+	}
+	g.SendMessage(v.Platform, msg)
+}
+
+var (
+	// CelebrationFrames is how long the level up celebration lasts.
+	CelebrationFrames = 120
+
+	confettiCount  = 24
+	confettiColors = []color.RGBA{
+		{0xff, 0xd7, 0x00, 0xff},
+		{0xff, 0x45, 0x00, 0xff},
+		{0x94, 0x4d, 0xff, 0xff},
+		{0x00, 0xbf, 0xff, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+
+	// pixel is in the atlas, so rectangles are batched with the sprites.
+	pixel = assets.Pixel
+)
+
+// Celebrate starts the level up animation.
+func (v *Viewer) Celebrate() {
+	v.celebrationFrame = CelebrationFrames
+	v.Jump()
+}
+
+// DrawCelebration draws confetti bursting from the gopher and a level up
+// banner above its name tag.
+func (v *Viewer) DrawCelebration(screen *ebiten.Image) {
+	if v.celebrationFrame <= 0 {
+		return
+	}
+	t := float64(CelebrationFrames - v.celebrationFrame)
+	alpha := float32(v.celebrationFrame) / float32(CelebrationFrames)
+	cx, cy := v.PosX+float64(gopherSize)/2, v.PosY+float64(gopherSize)/2
+
+	opts := &ebiten.DrawImageOptions{}
+	for i := 0; i < confettiCount; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(confettiCount)
+		speed := 2.0 + float64(i%3)
+		px := cx + math.Cos(angle)*speed*t
+		py := cy + math.Sin(angle)*speed*t + 0.05*t*t
+
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(6, 6)
+		opts.GeoM.Rotate(angle + t/10)
+		opts.GeoM.Translate(px, py)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(confettiColors[i%len(confettiColors)])
+		opts.ColorScale.ScaleAlpha(alpha)
+		screen.DrawImage(pixel, opts)
+	}
+
+	banner := "LEVEL UP!"
+	px := v.PosX - (TextWidth(banner)-float64(gopherSize))/2
+	py := v.PosY - 80 - t/4
+	DrawTextAt(screen, banner, px, py)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+var levelCurveTestCases = []struct {
+	curve LevelCurve
+
+	xp       int
+	level    int
+	progress float64
+}{
+	{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1}, 0, 0, 0},
+	{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1}, 250, 2, 0.5},
+	{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1.5}, 99, 0, 0.99},
+	{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1.5}, 100, 1, 0},
+	{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1.5}, 283, 2, 0},
+	{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1.5}, 282, 1, 182.0 / 183.0},
+	{LevelCurve{Type: CurveTable, Table: []int{0, 50, 200}}, 49, 0, 0.98},
+	{LevelCurve{Type: CurveTable, Table: []int{0, 50, 200}}, 125, 1, 0.5},
+	{LevelCurve{Type: CurveTable, Table: []int{0, 50, 200}}, 500, 4, 0},
+}
+
+func TestLevelCurve(t *testing.T) {
+	for tn, tc := range levelCurveTestCases {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			level := tc.curve.Level(tc.xp)
+			progress := tc.curve.Progress(tc.xp)
+			t.Logf("%v.Level(%d) => %d (%.3f)", tc.curve, tc.xp, level, progress)
+			if level != tc.level {
+				t.Errorf("unexpected level: want: %v, got: %v", tc.level, level)
+			}
+			if diff := progress - tc.progress; diff > 0.001 || diff < -0.001 {
+				t.Errorf("unexpected progress: want: %.3f, got: %.3f", tc.progress, progress)
+			}
+		})
+	}
+}
+
+func TestLevelCurveValidate(t *testing.T) {
+	testCases := []struct {
+		curve   LevelCurve
+		wantErr bool
+	}{
+		{LevelCurve{Type: CurvePolynomial, Base: 100, Exponent: 1.5}, false},
+		{LevelCurve{Type: CurvePolynomial, Base: 0, Exponent: 1.5}, true},
+		{LevelCurve{Type: CurveTable, Table: []int{0, 100, 300}}, false},
+		{LevelCurve{Type: CurveTable, Table: []int{0, 100, 100}}, true},
+		{LevelCurve{Type: "exponential"}, true},
+	}
+	for tn, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			err := tc.curve.Validate()
+			if tc.wantErr && err == nil {
+				t.Errorf("wanted error but got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("wanted no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestUnlocksBetween(t *testing.T) {
+	lc := LevelConfig{
+		Unlocks: map[int][]Unlock{
+			2: {{Kind: UnlockMove, Name: "hadouken"}},
+			3: {{Kind: UnlockCosmetic, Name: "mario"}},
+			5: {{Kind: UnlockMove, Name: "shoryuken"}},
+		},
+	}
+	unlocks := lc.UnlocksBetween(1, 3)
+	if len(unlocks) != 2 || unlocks[0].Name != "hadouken" || unlocks[1].Name != "mario" {
+		t.Errorf("unexpected unlocks: %v", unlocks)
+	}
+	if unlocks := lc.UnlocksBetween(3, 4); len(unlocks) != 0 {
+		t.Errorf("unexpected unlocks: %v", unlocks)
+	}
+}
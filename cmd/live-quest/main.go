@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"flag"
-	"fmt"
 	"image/color"
 	"math/rand"
 	"net/http"
@@ -20,15 +19,14 @@ import (
 	"github.com/codigolandia/live-quest/message"
 	"github.com/codigolandia/live-quest/twitch"
 	"github.com/codigolandia/live-quest/youtube"
-	"github.com/hajimehoshi/bitmapfont/v3"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/text"
-	"golang.org/x/image/font"
+	"golang.org/x/net/websocket"
 
 	_ "image/png"
 )
 
 var (
+	// Width and Height are the screen size, set with --resolution.
 	Width  = 1920
 	Height = 1080
 
@@ -36,10 +34,12 @@ var (
 	AutoSaveDelay = 60 * 10
 
 	// GopherDrawingTimeout is the time stop displaying the player on screen
-	// due to inactivity
+	// due to inactivity. Gophers fall asleep before that, and sleeping
+	// gophers of viewers who chatted in the current stream stay on screen.
 	GopherDrawingTimeout = 20 * time.Minute
 
 	ColorGreen      = color.RGBA{0, 0xff, 0, 0xff}
+	ColorMagenta    = color.RGBA{0xff, 0, 0xff, 0xff}
 	ColorGopherBlue = color.RGBA{0x9c, 0xed, 0xff, 0xff}
 
 	Port          string
@@ -49,10 +49,6 @@ var (
 var (
 	gopherFrameCount = 5
 	gopherSize       = 128
-
-	face font.Face
-
-	gravity = 0.1
 )
 
 var (
@@ -61,17 +57,26 @@ var (
 )
 
 func init() {
-	face = bitmapfont.Face
-
 	// Command line options
 	flag.StringVar(&Port, "http-port", "8080", "HTTP Port to listen to")
 	flag.BoolVar(&HttpHotReload, "http-hot-reload", false, "Hot-reload of http assets when developing")
-}
-
-type FightState struct {
-	Player1     string `json:"player1"`
-	Player2     string `json:"player2"`
-	CurrentTurn string `json:"currentTurn"`
+	flag.StringVar(&LevelsFile, "levels", LevelsFile, "Level curve and unlocks configuration file")
+	flag.StringVar(&SceneFile, "scene", SceneFile, "Scene file with the world settings and platforms")
+	flag.StringVar(&HUDFile, "hud", HUDFile, "HUD layout file with the panels drawn over the game")
+	flag.StringVar(&MapFile, "map", "", "Tiled map (TMX or JSON) drawn as the stage, whose collision layers are the platforms")
+	flag.Float64Var(&ParallaxSpeed, "parallax-speed", ParallaxSpeed, "Speed of the camera that moves the parallax layers of the map, in pixels per second")
+	flag.StringVar(&AssetPacks, "assets", "", "Asset pack directories with a pack.json manifest, separated by commas")
+	flag.StringVar(&FontFiles, "font", "", "TTF or OTF fonts for the texts, separated by commas, each a fallback for the previous")
+	flag.Float64Var(&FontSize, "font-size", FontSize, "Text size in pixels")
+	flag.IntVar(&TextOutline, "text-outline", TextOutline, "Width of the text outline in pixels")
+	flag.StringVar(&Resolution, "resolution", Resolution, "Screen size, as WIDTHxHEIGHT")
+	flag.StringVar(&BackgroundSpec, "background", "", "Background: green, magenta, transparent, #rrggbb or an image file (default green, transparent with --overlay)")
+	flag.BoolVar(&Overlay, "overlay", false, "Transparent window, always on top and letting the mouse clicks through")
+	flag.BoolVar(&Decorated, "decorated", false, "Keep the window title bar in overlay mode")
+	flag.StringVar(&SceneScheduleSpec, "scene-schedule", "", "Scheduled scenes, like credits@22:00 or leaderboard/15m/30s, separated by commas")
+	flag.StringVar(&SceneTransition, "scene-transition", SceneTransition, "Transition between scenes: fade or wipe")
+	flag.BoolVar(&Headless, "headless", false, "Run without a window, drawing the game in the browser at /game.html")
+	flag.IntVar(&Bench, "bench", 0, "Benchmark scene with this many fake viewers, without chat or saves")
 }
 
 type Game struct {
@@ -82,12 +87,33 @@ type Game struct {
 	FightingQueue map[string]bool `json:"fightingQueue"`
 	FightState    FightState      `json:"fightState"`
 
+	FightLog      []FightRecord        `json:"fightLog"`
+	Duels         []*Duel              `json:"duels"`
+	DuelCooldowns map[string]time.Time `json:"duelCooldowns"`
+	Tournament    *Tournament          `json:"tournament"`
+	Boss          *Boss                `json:"boss"`
+	TeamBattle    *TeamBattle          `json:"teamBattle"`
+
 	UsedLinks map[string]struct{} `json:"usedLinks"`
 
+	// ActiveChallenge is the code of the challenge shown on the HUD.
+	ActiveChallenge string `json:"activeChallenge"`
+
+	// Streams are the days the game was played, used to track streaks.
+	Streams      []string `json:"streams"`
+	FirstChatDay string   `json:"firstChatDay"`
+
 	YoutubePageToken string `json:"youtubePageToken"`
 	Count            int    `json:"-"`
 
 	queue chan message.Message
+
+	queuedAt      map[string]time.Time
+	floatingTexts []FloatingText
+	projectiles   []*Projectile
+	frameStats    frameStats
+	stream        streamHub
+	scenes        sceneManager
 }
 
 var tempFileMu sync.Mutex
@@ -145,6 +171,15 @@ func (g *Game) Autoload() {
 		if v.CompletedChallenges == nil {
 			v.CompletedChallenges = make(map[string]struct{})
 		}
+		if v.Unlocks == nil {
+			v.Unlocks = make(map[string]struct{})
+		}
+		if v.Achievements == nil {
+			v.Achievements = make(map[string]time.Time)
+		}
+		if v.Outfit == nil {
+			v.Outfit = make(map[string]string)
+		}
 	}
 
 	// Sanity Check
@@ -157,10 +192,38 @@ func (g *Game) Autoload() {
 	if g.UsedLinks == nil {
 		g.UsedLinks = make(map[string]struct{})
 	}
+	if g.DuelCooldowns == nil {
+		g.DuelCooldowns = make(map[string]time.Time)
+	}
+	for uid := range g.FightingQueue {
+		g.queuedAt[uid] = time.Now()
+	}
 
 	log.I("game loaded from %v", fileName)
 }
 
+const dayFormat = "2006-01-02"
+
+// StartStream registers today as a new stream, if not already registered.
+func (g *Game) StartStream() {
+	today := time.Now().Format(dayFormat)
+	if len(g.Streams) == 0 || g.Streams[len(g.Streams)-1] != today {
+		g.Streams = append(g.Streams, today)
+	}
+}
+
+// CurrentStream returns the current and the previous stream days.
+func (g *Game) CurrentStream() (curr, prev string) {
+	if len(g.Streams) == 0 {
+		g.StartStream()
+	}
+	curr = g.Streams[len(g.Streams)-1]
+	if len(g.Streams) > 1 {
+		prev = g.Streams[len(g.Streams)-2]
+	}
+	return curr, prev
+}
+
 func (g *Game) CheckNewMessages() {
 	var msg []message.Message
 	if yt != nil {
@@ -187,19 +250,35 @@ func (g *Game) CheckNewMessages() {
 			g.UIDs = append(g.UIDs, m.UID)
 		}
 
+		v.LastSeen = m.Timestamp
+		v.WakeUp()
+		g.UpdateFriend(v, m.Text)
+		g.Say(v, m.Text)
 		g.ParseCommands(m, v)
+		g.ChargeTeam(v)
 		v.IncXP(10)
+
+		v.Messages++
+		v.Attend(g.CurrentStream())
+		Emit(Event{Type: EventMessage, UID: m.UID})
+		if today := m.Timestamp.Local().Format(dayFormat); g.FirstChatDay != today {
+			g.FirstChatDay = today
+			Emit(Event{Type: EventFirstChat, UID: m.UID})
+		}
 	}
 }
 
-var helpMsg = `!color: para personalizar o gopher; !jump para ele pular; !check para resolver desafio de programação.`
+var helpMsg = `!color <cor> para personalizar o gopher (nome, #rgb, hsl(...) ou random); !wear para vestir os visuais desbloqueados; !character para trocar de personagem; !jump para ele pular; !fight para lutar; !duel @nome [xp] para desafiar alguém; !join para entrar no torneio; !check para resolver desafio de programação; !record para ver suas lutas; !dance, !wave, !sit, !sleep, !hug @nome e !highfive @nome para se expressar; !achievements para ver suas conquistas.`
 
 func (g *Game) SendMessage(platform, msg string) {
 	var err error
-	if platform == message.PlatformYoutube {
+	switch {
+	case platform == message.PlatformYoutube && yt != nil:
 		err = yt.SendMessage(msg)
-	} else {
+	case platform == message.PlatformTwitch && tw != nil:
 		err = tw.SendMessage(msg)
+	default:
+		log.W("live-quest: not connected to %v, unable to send: %v", platform, msg)
 	}
 	if err != nil {
 		log.E("live-quest: error sending message to %v: %v", platform, err)
@@ -214,15 +293,58 @@ func (g *Game) ParseCommands(m message.Message, v *Viewer) {
 		g.SendMessage(m.Platform, helpMsg)
 	case strings.Contains(m.Text, "!jump"):
 		log.D("%s is jumping!", m.Author)
-		v.VelY = -100
+		v.Jump()
+	case strings.Contains(m.Text, "!wear"):
+		g.WearCommand(v, m.Text)
+	case strings.Contains(m.Text, "!character"):
+		g.CharacterCommand(v, m.Text)
 	case strings.Contains(m.Text, "!color"):
 		log.D("%s is changing the Gopher color!", m.Author)
-		v.SpriteColor = SelectColor(m.Text)
+		g.ColorCommand(v, m.Text)
 	case strings.Contains(m.Text, "!fight"):
 		log.D("%s is looking for a fight!", m.Author)
-		g.FightingQueue[m.UID] = true
+		g.JoinQueue(v)
+	case strings.Contains(m.Text, "!duel"):
+		g.ChallengeDuel(v, m.Text)
+	case strings.Contains(m.Text, "!accept"):
+		g.AcceptDuel(v)
+	case strings.Contains(m.Text, "!decline"):
+		g.DeclineDuel(v)
+	case strings.Contains(m.Text, "!tournament"):
+		g.TournamentCommand(m, v)
+	case strings.Contains(m.Text, "!join"):
+		g.JoinTournament(v)
+	case strings.Contains(m.Text, "!challenge"):
+		g.ChallengeCommand(m, v)
 	case strings.Contains(m.Text, "!check"):
 		g.queue <- m
+	case strings.Contains(m.Text, "!record"):
+		g.ReplyRecord(v)
+	case strings.Contains(m.Text, "!achievements"):
+		g.ReplyAchievements(v)
+	case strings.Contains(m.Text, "!teams"):
+		g.TeamBattleCommand(m, v)
+	case strings.Contains(m.Text, "!scene"):
+		g.SceneCommand(m, v)
+	case strings.Contains(m.Text, "!boss"):
+		g.BossCommand(m, v)
+	case strings.Contains(m.Text, "!attack"):
+		// Fighters attack their opponent; everyone else attacks the boss
+		if g.Boss != nil && !g.FightState.IsFighting(v.UID) {
+			g.AttackBoss(v)
+		} else {
+			g.ChooseMove(v, MoveAttack)
+		}
+	case strings.Contains(m.Text, "!defend"):
+		g.ChooseMove(v, MoveDefend)
+	case strings.Contains(m.Text, "!special"):
+		g.ChooseMove(v, MoveSpecial)
+	case strings.Contains(m.Text, "!heal"):
+		g.ChooseMove(v, MoveHeal)
+	case SpecialMoveIn(m.Text) != "":
+		g.ChooseMove(v, SpecialMoveIn(m.Text))
+	case EmoteIn(m.Text) != "":
+		g.EmoteCommand(v, m.Text)
 	}
 }
 
@@ -231,99 +353,37 @@ func (g *Game) Update() error {
 		log.I("closing ...")
 		return ebiten.Termination
 	}
-	g.Autosave()
+	if Bench == 0 {
+		g.Autosave()
+	}
 	g.CheckNewMessages()
+	g.HandleEvents()
+	g.UpdateDuels()
 	for _, uid := range g.UIDs {
 		v := g.Viewers[uid]
-		if g.FightState.CurrentTurn == "" {
+		if g.FightState.CurrentTurn == "" && g.TeamBattle == nil {
 			v.Update(g)
 		} else {
 			v.UpdateAnimation(g)
 		}
 	}
+	g.UpdatePhysics()
 	g.FightRound()
+	g.UpdateBoss()
+	g.UpdateTeamBattle()
+	g.UpdateProjectiles()
+	g.UpdateFloatingTexts()
+	g.UpdateBubbles()
+	g.UpdateScenes()
+	g.PublishState()
 	g.Count++
 	return nil
 }
 
-func (g *Game) UpdateFightPositions() {
-	p1 := g.Viewers[g.FightState.Player1]
-	p1.WalkRight()
-	p1.VelX = 0
-	p1.PosX = 1000
-
-	p2 := g.Viewers[g.FightState.Player2]
-	p2.WalkLeft()
-	p2.VelX = 0
-	p2.PosX = 1200
-
-	crowd := make([]*Viewer, 0, len(g.Viewers))
-	for uid, _ := range g.Viewers {
-		if uid == p1.UID || uid == p2.UID {
-			continue
-		}
-		v := g.Viewers[uid]
-		crowd = append(crowd, v)
-	}
-	sort.Sort(ByXP(crowd))
-
-	dx := 800/len(g.Viewers) + 1
-	px := 64
-	for i := range crowd {
-		v := crowd[i]
-		v.PosX = float64(px)
-		v.WalkRight()
-		v.VelX = 0
-		px = px + dx
-	}
-}
-
-func (g *Game) RemoveFromQueue(p1, p2 string) {
-	delete(g.FightingQueue, p1)
-	delete(g.FightingQueue, p2)
-}
-
-func (g *Game) FightRound() {
-	if len(g.FightingQueue) < 2 && g.FightState.CurrentTurn == "" {
-		return
-	}
-	if g.Count%60 != 0 {
-		return
-	}
-	if g.FightState.CurrentTurn == "" {
-		log.D("fight: initializing fight ...")
-		fighters := g.SortFighters()
-		g.FightState.Player1 = fighters[0].UID
-		g.FightState.Player2 = fighters[1].UID
-		g.FightState.CurrentTurn = g.FightState.Player2
-		g.RemoveFromQueue(fighters[0].UID, fighters[1].UID)
-		g.UpdateFightPositions()
-	}
-
-	log.D("fight: %s vs %s...", g.FightState.Player1, g.FightState.Player2)
-	attacker := g.Viewers[g.FightState.CurrentTurn]
-	defender := g.Viewers[g.FightState.Player1]
-	if g.FightState.CurrentTurn == g.FightState.Player1 {
-		defender = g.Viewers[g.FightState.Player2]
-	}
-
-	dmg := attacker.Attack(defender)
-	log.D("fight: %s damaged %s by %d", attacker.Name, defender.Name, dmg)
-	defender.Damage(dmg)
-	g.FightState.CurrentTurn = defender.UID
-
-	if defender.HP <= 0 {
-		log.D("fight: fight is over: %v won!", attacker.Name)
-		g.FightState = FightState{}
-		defender.HP = 100
-		defender.IncXP(20)
-		attacker.HP = 100
-		attacker.IncXP(50)
-		attacker.Jump()
-	}
-}
-
 func (g *Game) LastActivity(v *Viewer) (last time.Time) {
+	if !v.LastSeen.IsZero() {
+		return v.LastSeen
+	}
 	for _, m := range g.ChatHistory {
 		if m.UID == v.UID {
 			last = m.Timestamp
@@ -333,59 +393,48 @@ func (g *Game) LastActivity(v *Viewer) (last time.Time) {
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(ColorGreen)
+	g.DrawScene(screen)
+	if Bench > 0 {
+		g.DrawBenchStats(screen)
+	}
+	texts.Sweep()
+}
+
+// DrawViewers draws the visible gophers in passes: all the sprites, then
+// all the bars and then all the names. The sprites and the bars are in
+// our atlas; the names are cached text images, which Ebiten moves to its
+// shared atlases after a few frames of drawing them unchanged. Each pass
+// is then batched in a few draw calls instead of switching textures per
+// gopher.
+func (g *Game) DrawViewers(screen *ebiten.Image) {
+	visible := make([]*Viewer, 0, len(g.UIDs))
 	for _, uid := range g.UIDs {
-		v := g.Viewers[uid]
-		if time.Since(g.LastActivity(v)) < GopherDrawingTimeout {
-			v.Draw(screen)
+		if v := g.Viewers[uid]; g.IsVisible(v) {
+			visible = append(visible, v)
 		}
 	}
-	g.DrawLeaderBoard(screen)
+	for _, v := range visible {
+		v.DrawSprite(screen)
+	}
+	for _, v := range visible {
+		v.DrawBars(screen)
+	}
+	for _, v := range visible {
+		v.DrawNameTag(screen)
+	}
 }
 
-func (g *Game) DrawLeaderBoard(screen *ebiten.Image) {
-	// Top 5
+// TopViewers returns the n viewers with more XP.
+func (g *Game) TopViewers(n int) []*Viewer {
 	viewers := make([]*Viewer, 0, len(g.UIDs))
 	for _, uid := range g.UIDs {
 		viewers = append(viewers, g.Viewers[uid])
 	}
 	sort.Sort(ByXP(viewers))
-
-	px, py := float64(Width-380), 12.0
-	topFive := viewers
-	if len(viewers) > 5 {
-		topFive = viewers[:5]
-	}
-	for _, v := range topFive {
-		txt := fmt.Sprintf("%v [%02d] %04d XP\n", v.Name, v.Level(), v.XP)
-		txtLen := len(txt) * pixelPerChar
-		py += 24
-		px = float64(Width) - float64(txtLen)
-		DrawTextAt(screen, txt, px, py)
-	}
-	// Fighting queue
-	py += 24
-	px = float64(Width) - 350
-	if len(g.FightingQueue) > 0 {
-		fighters := g.SortFighters()
-		py += 24
-		DrawTextAt(screen, "** Fighting Queue **", px, py)
-		py += 24
-		for _, v := range fighters {
-			DrawTextAt(screen, v.Name, px, py)
-			py += 24
-		}
+	if len(viewers) > n {
+		viewers = viewers[:n]
 	}
-}
-
-func (g *Game) SortFighters() []*Viewer {
-	fighters := make([]*Viewer, 0, len(g.FightingQueue))
-	for uid, _ := range g.FightingQueue {
-		v := g.Viewers[uid]
-		fighters = append(fighters, v)
-	}
-	sort.Sort(ByXP(fighters))
-	return fighters
+	return viewers
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenW, screenH int) {
@@ -397,41 +446,42 @@ func New() *Game {
 	g.Viewers = make(map[string]*Viewer)
 	g.FightingQueue = make(map[string]bool)
 	g.UsedLinks = make(map[string]struct{})
+	g.DuelCooldowns = make(map[string]time.Time)
+	g.queuedAt = make(map[string]time.Time)
 	return &g
 }
 
-// TODO(ronoaldo): move to own file, like draw.go
-var (
-	pixelPerChar           = 2 * 6
-	textScaleX, textScaleY = 2.0, 1.8
-)
-
-func DrawTextAt(screen *ebiten.Image, txt string, px, py float64) {
-	textOpts := &ebiten.DrawImageOptions{}
-	textOpts.GeoM.Scale(textScaleX, textScaleY)
-	textOpts.GeoM.Translate(px, py)
-
-	textOpts.GeoM.Translate(-2.0, -2.0)
-	textOpts.ColorScale.SetR(0.0)
-	textOpts.ColorScale.SetG(0.0)
-	textOpts.ColorScale.SetB(0.0)
-	text.DrawWithOptions(screen, txt, face, textOpts)
-
-	textOpts.GeoM.Translate(3.0, 3.0)
-	text.DrawWithOptions(screen, txt, face, textOpts)
-
-	textOpts.GeoM.Translate(-1.0, -1.0)
-	textOpts.ColorScale.SetR(1.0)
-	textOpts.ColorScale.SetG(1.0)
-	textOpts.ColorScale.SetB(1.0)
-	text.DrawWithOptions(screen, txt, face, textOpts)
-}
-
 func main() {
 	flag.Parse()
+	if w, h, err := ParseResolution(Resolution); err != nil {
+		log.E("live-quest: %v, using %dx%d", err, Width, Height)
+	} else {
+		SetResolution(w, h)
+	}
+	SetBackground(BackgroundSpec)
+	LoadLevels(LevelsFile)
+	LoadScene(SceneFile)
+	LoadMap(MapFile)
+	LoadHUD(HUDFile)
+	LoadAssetPacks(AssetPacks)
+	LoadFonts(FontFiles)
 
 	g := New()
+	if Bench > 0 {
+		g.SpawnBench(Bench, rand.New(rand.NewSource(time.Now().UnixNano())))
+		ebiten.SetVsyncEnabled(false)
+		if err := RunWindow(g, "LiveQuest - bench"); err != nil {
+			panic(err)
+		}
+		return
+	}
 	g.Autoload()
+	g.StartStream()
+	if schedule, err := ParseSceneSchedule(SceneScheduleSpec); err != nil {
+		log.E("live-quest: %v", err)
+	} else {
+		g.SetSceneSchedule(schedule, time.Now())
+	}
 
 	var err error
 	ytts, err := oauth.NewTokenSource(message.PlatformYoutube)
@@ -455,18 +505,22 @@ func main() {
 	// Initialize challenge queue
 	go g.ChallengeQueue()
 
-	ebiten.SetWindowSize(Width, Height)
-	ebiten.SetWindowTitle("LiveQuest")
-
 	log.I("game initialized")
 
 	// Listen on http 8080
 	http.HandleFunc("/chat", g.ServeChat)
+	http.Handle("/stream", websocket.Handler(g.ServeStream))
+	http.HandleFunc("/sprites.json", g.ServeSprites)
+	http.Handle("/sprites/", spriteHandler)
 	http.HandleFunc("/", g.ServeAssets)
 	go http.ListenAndServe(":"+Port, nil)
 	log.I("http chat overlay started on port " + Port)
 
-	if err := ebiten.RunGame(g); err != nil {
+	run := RunWindow
+	if Headless {
+		run = func(g *Game, _ string) error { return RunHeadless(g) }
+	}
+	if err := run(g, "LiveQuest"); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/tiled"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var (
+	// MapFile is a Tiled map, in TMX or JSON, drawn as the stage.
+	MapFile string
+
+	// ParallaxSpeed is the speed of the virtual camera that moves the
+	// parallax layers of the map, in pixels per second.
+	ParallaxSpeed = 30.0
+
+	// Stage is the map loaded from MapFile, or nil.
+	Stage *StageMap
+)
+
+// StageMap is a Tiled map ready to draw, scaled to the screen height.
+// HasPlatforms is true when its collision layers replaced the platforms of
+// the scene, so they are drawn by the map.
+type StageMap struct {
+	Name         string
+	Scale        float64
+	Layers       []StageLayer
+	HasPlatforms bool
+}
+
+// StageLayer is a tile or image layer of the map. Foreground layers, with
+// the foreground property set, are drawn over the gophers.
+type StageLayer struct {
+	*tiled.Layer
+	Image      *ebiten.Image
+	Foreground bool
+}
+
+// openMap loads the map from the file, relative to the working directory
+// or absolute, so the map can reference files in other directories.
+func openMap(file string) (*tiled.Map, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	vol := filepath.VolumeName(abs)
+	name := strings.TrimPrefix(filepath.ToSlash(abs[len(vol):]), "/")
+	return tiled.Load(os.DirFS(vol+string(filepath.Separator)), name)
+}
+
+// NewStageMap renders the layers of the map. The map is scaled to the
+// screen height, unless it has the scale property.
+func NewStageMap(name string, m *tiled.Map) (*StageMap, error) {
+	_, h := m.PixelSize()
+	s := &StageMap{
+		Name:  name,
+		Scale: m.Properties.Float("scale", float64(Height)/float64(h)),
+	}
+	if !(s.Scale > 0) || math.IsInf(s.Scale, 0) {
+		return nil, fmt.Errorf("invalid scale: %v", s.Scale)
+	}
+	for _, l := range m.Layers {
+		if !l.Visible || l.Type == tiled.ObjectLayer {
+			continue
+		}
+		sl := StageLayer{Layer: l, Foreground: l.Properties.Bool("foreground")}
+		switch {
+		case l.Type == tiled.TileLayer:
+			sl.Image = ebiten.NewImageFromImage(m.Render(l))
+		case l.Image != nil:
+			sl.Image = ebiten.NewImageFromImage(l.Image)
+		default:
+			continue
+		}
+		s.Layers = append(s.Layers, sl)
+	}
+	return s, nil
+}
+
+// LoadMap loads the map of the stage. The platforms of its collision
+// layers replace the platforms of the scene file.
+func LoadMap(file string) {
+	if file == "" {
+		return
+	}
+	m, err := openMap(file)
+	if err != nil {
+		log.E("map: error loading the map, using the scene: %v", err)
+		return
+	}
+	stage, err := NewStageMap(filepath.Base(file), m)
+	if err != nil {
+		log.E("map: %v: %v, using the scene", file, err)
+		return
+	}
+	Stage = stage
+	platforms := m.Platforms()
+	if len(platforms) > 0 {
+		for i := range platforms {
+			p := &platforms[i]
+			p.X, p.Y, p.W = p.X*Stage.Scale, p.Y*Stage.Scale, p.W*Stage.Scale
+		}
+		World.Platforms = platforms
+		Stage.HasPlatforms = true
+	}
+	log.I("map: loaded %v: %d layers and %d platforms, scaled %.2fx",
+		Stage.Name, len(Stage.Layers), len(platforms), Stage.Scale)
+}
+
+// wrap returns the position of the layer of size n scrolled to x on a
+// screen of the given size: repeated layers start before the screen, and
+// the others leave it at one side and come back from the other.
+func wrap(x, n, screen float64, repeat bool) float64 {
+	if repeat {
+		return math.Mod(math.Mod(x, n)+n, n) - n
+	}
+	return math.Mod(math.Mod(x+n, screen+n)+screen+n, screen+n) - n
+}
+
+// DrawMap draws the background or the foreground layers of the map, moving
+// the parallax layers with the virtual camera at the time t, in seconds.
+func DrawMap(screen *ebiten.Image, foreground bool, t float64) {
+	if Stage == nil {
+		return
+	}
+	s := Stage.Scale
+	camX := ParallaxSpeed * t
+	opts := &ebiten.DrawImageOptions{}
+	for _, l := range Stage.Layers {
+		if l.Foreground != foreground {
+			continue
+		}
+		size := l.Image.Bounds().Size()
+		w, h := float64(size.X)*s, float64(size.Y)*s
+		// The map stays still, so the layers move against the camera
+		x, y := l.OffsetX*s+(1-l.ParallaxX)*camX, l.OffsetY*s
+		if l.RepeatX || l.ParallaxX != 1 {
+			x = wrap(x, w, float64(Width), l.RepeatX)
+		}
+		if l.RepeatY {
+			y = wrap(y, h, float64(Height), true)
+		}
+		for py := y; py < float64(Height); py += h {
+			for px := x; px < float64(Width); px += w {
+				opts.GeoM.Reset()
+				opts.GeoM.Scale(s, s)
+				opts.GeoM.Translate(px, py)
+				opts.ColorScale.Reset()
+				opts.ColorScale.ScaleAlpha(float32(l.Opacity))
+				screen.DrawImage(l.Image, opts)
+				if !l.RepeatX {
+					break
+				}
+			}
+			if !l.RepeatY {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/codigolandia/live-quest/tiled"
+)
+
+func TestWrap(t *testing.T) {
+	for tn, tc := range []struct {
+		x, n, screen float64
+		repeat       bool
+		expect       float64
+	}{
+		{x: 0, n: 100, screen: 1000, repeat: true, expect: -100},
+		{x: 30, n: 100, screen: 1000, repeat: true, expect: -70},
+		{x: -30, n: 100, screen: 1000, repeat: true, expect: -30},
+		{x: 250, n: 100, screen: 1000, repeat: true, expect: -50},
+		{x: 0, n: 100, screen: 1000, expect: 0},
+		{x: 950, n: 100, screen: 1000, expect: 950},
+		{x: 1000, n: 100, screen: 1000, expect: -100},
+		{x: 1050, n: 100, screen: 1000, expect: -50},
+		{x: -150, n: 100, screen: 1000, expect: 950},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if x := wrap(tc.x, tc.n, tc.screen, tc.repeat); x != tc.expect {
+				t.Errorf("expected %v, got %v", tc.expect, x)
+			}
+		})
+	}
+}
+
+func TestStageMapScale(t *testing.T) {
+	for tn, tc := range []struct {
+		scale   string
+		wantErr bool
+	}{
+		{scale: "", wantErr: false},
+		{scale: "2", wantErr: false},
+		{scale: "0", wantErr: true},
+		{scale: "-1", wantErr: true},
+		{scale: "NaN", wantErr: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			m := &tiled.Map{Width: 4, Height: 3, TileWidth: 8, TileHeight: 8,
+				Properties: tiled.Properties{"scale": tc.scale}}
+			if _, err := NewStageMap("test", m); tc.wantErr != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+)
+
+var (
+	// InitialRating is the Elo rating of viewers that never fought.
+	InitialRating = 1000.0
+
+	// RatingK is the maximum rating change after a single fight.
+	RatingK = 32.0
+
+	// MatchWindow is the maximum rating difference to pair fighters that
+	// just joined the queue. It grows by MatchWindowStep every
+	// MatchWindowInterval waiting, so nobody waits forever.
+	MatchWindow         = 100.0
+	MatchWindowStep     = 50.0
+	MatchWindowInterval = 30 * time.Second
+
+	// MaxFightLog is the number of fights kept in the history.
+	MaxFightLog = 1000
+)
+
+// ExpectedScore returns the probability of a player rated ra winning
+// against a player rated rb.
+func ExpectedScore(ra, rb float64) float64 {
+	return 1 / (1 + math.Pow(10, (rb-ra)/400))
+}
+
+// UpdateRatings returns the new ratings after the winner defeats the loser.
+func UpdateRatings(winner, loser float64) (float64, float64) {
+	delta := RatingK * (1 - ExpectedScore(winner, loser))
+	return winner + delta, loser - delta
+}
+
+// FightRecord is an entry of the fight history.
+type FightRecord struct {
+	Time   time.Time `json:"time"`
+	Winner string    `json:"winner"`
+	Loser  string    `json:"loser"`
+
+	WinnerName   string  `json:"winnerName"`
+	LoserName    string  `json:"loserName"`
+	WinnerRating float64 `json:"winnerRating"`
+	LoserRating  float64 `json:"loserRating"`
+
+	Wager int `json:"wager"`
+	Moves int `json:"moves"`
+}
+
+// RecordFight updates the fight records and ratings of both fighters and
+// appends the fight to the history.
+func (g *Game) RecordFight(winner, loser *Viewer) {
+	winner.Rating, loser.Rating = UpdateRatings(winner.CurrRating(), loser.CurrRating())
+	winner.FightsWon++
+	loser.FightsLost++
+	winner.Streak = max(winner.Streak, 0) + 1
+	loser.Streak = min(loser.Streak, 0) - 1
+	log.D("rating: %v is now %.0f, %v is now %.0f",
+		winner.Name, winner.Rating, loser.Name, loser.Rating)
+
+	g.FightLog = append(g.FightLog, FightRecord{
+		Time:         time.Now(),
+		Winner:       winner.UID,
+		Loser:        loser.UID,
+		WinnerName:   winner.Name,
+		LoserName:    loser.Name,
+		WinnerRating: winner.Rating,
+		LoserRating:  loser.Rating,
+		Wager:        g.FightState.Wager,
+		Moves:        len(g.FightState.History),
+	})
+	if len(g.FightLog) > MaxFightLog {
+		g.FightLog = g.FightLog[len(g.FightLog)-MaxFightLog:]
+	}
+}
+
+// CurrRating returns the viewer rating, initializing it if needed.
+func (v *Viewer) CurrRating() float64 {
+	if v.Rating == 0 {
+		v.Rating = InitialRating
+	}
+	return v.Rating
+}
+
+// matchWindow returns the rating difference accepted for a fighter
+// waiting since the given time.
+func matchWindow(since time.Time, now time.Time) float64 {
+	if since.IsZero() {
+		return math.Inf(1)
+	}
+	steps := math.Floor(float64(now.Sub(since)) / float64(MatchWindowInterval))
+	return MatchWindow + steps*MatchWindowStep
+}
+
+// MatchFighters pairs the closest rated fighters in the queue, as long as
+// the difference fits the window of one of them.
+func (g *Game) MatchFighters() (p1, p2 *Viewer, ok bool) {
+	now := time.Now()
+	fighters := g.SortFighters()
+	best := math.Inf(1)
+	for i := 0; i < len(fighters); i++ {
+		for j := i + 1; j < len(fighters); j++ {
+			a, b := fighters[i], fighters[j]
+			diff := math.Abs(a.CurrRating() - b.CurrRating())
+			window := max(matchWindow(g.queuedAt[a.UID], now), matchWindow(g.queuedAt[b.UID], now))
+			if diff > window || diff >= best {
+				continue
+			}
+			best = diff
+			p1, p2, ok = a, b, true
+		}
+	}
+	if ok && p2.CurrRating() > p1.CurrRating() {
+		p1, p2 = p2, p1
+	}
+	return p1, p2, ok
+}
+
+// JoinQueue adds the viewer to the fighting queue.
+func (g *Game) JoinQueue(v *Viewer) {
+	if _, ok := g.FightingQueue[v.UID]; ok {
+		return
+	}
+	g.FightingQueue[v.UID] = true
+	g.queuedAt[v.UID] = time.Now()
+}
+
+// ReplyRecord shows the fight record of the viewer in the chat.
+func (g *Game) ReplyRecord(v *Viewer) {
+	streak := ""
+	switch {
+	case v.Streak > 1:
+		streak = fmt.Sprintf(", %d vitórias seguidas", v.Streak)
+	case v.Streak < -1:
+		streak = fmt.Sprintf(", %d derrotas seguidas", -v.Streak)
+	}
+	g.SendMessage(v.Platform, fmt.Sprintf("@%s: %d vitórias, %d derrotas%s; rating %.0f",
+		v.Name, v.FightsWon, v.FightsLost, streak, v.CurrRating()))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUpdateRatings(t *testing.T) {
+	if e := ExpectedScore(1000, 1000); e != 0.5 {
+		t.Errorf("unexpected score for equal ratings: %v", e)
+	}
+	w, l := UpdateRatings(1000, 1000)
+	if w != 1016 || l != 984 {
+		t.Errorf("unexpected ratings: %v, %v", w, l)
+	}
+	// Beating a much stronger opponent is worth more
+	w, _ = UpdateRatings(1000, 1400)
+	if math.Abs(w-1000-RatingK*10/11) > 0.01 {
+		t.Errorf("unexpected rating after upset: %v", w)
+	}
+}
+
+func newTestQueue(ratings map[string]float64, since time.Duration) *Game {
+	g := New()
+	for uid, rating := range ratings {
+		v := NewViewer()
+		v.UID = uid
+		v.Name = uid
+		v.Rating = rating
+		g.Viewers[uid] = v
+		g.UIDs = append(g.UIDs, uid)
+		g.JoinQueue(v)
+		g.queuedAt[uid] = time.Now().Add(-since)
+	}
+	return g
+}
+
+func TestMatchFighters(t *testing.T) {
+	g := newTestQueue(map[string]float64{"a": 1000, "b": 1300, "c": 1060, "d": 1500}, 0)
+	p1, p2, ok := g.MatchFighters()
+	if !ok || p1.UID != "c" || p2.UID != "a" {
+		t.Errorf("unexpected match: %v, %v, %v", p1, p2, ok)
+	}
+
+	g = newTestQueue(map[string]float64{"a": 1000, "b": 1300}, 0)
+	if _, _, ok := g.MatchFighters(); ok {
+		t.Errorf("fighters matched outside the rating window")
+	}
+
+	g = newTestQueue(map[string]float64{"a": 1000, "b": 1300}, 3*time.Minute)
+	if _, _, ok := g.MatchFighters(); !ok {
+		t.Errorf("rating window did not expand while waiting")
+	}
+}
+
+func TestRecordFight(t *testing.T) {
+	g := New()
+	winner, loser := NewViewer(), NewViewer()
+	loser.Streak = 2
+
+	g.RecordFight(winner, loser)
+	g.RecordFight(winner, loser)
+	if winner.FightsWon != 2 || winner.Streak != 2 || winner.Rating <= InitialRating {
+		t.Errorf("unexpected winner record: %v, %v, %v", winner.FightsWon, winner.Streak, winner.Rating)
+	}
+	if loser.FightsLost != 2 || loser.Streak != -2 || loser.Rating >= InitialRating {
+		t.Errorf("unexpected loser record: %v, %v, %v", loser.FightsLost, loser.Streak, loser.Rating)
+	}
+	if len(g.FightLog) != 2 {
+		t.Errorf("unexpected fight log: %v", g.FightLog)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"os"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/physics"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// SceneHeight is the screen height the scene files are made for; their
+// platforms are scaled to the resolution.
+const SceneHeight = 1080
+
+var (
+	SceneFile = "scene.json"
+
+	// World is where the gophers move; the right side of the screen is
+	// reserved for the leader board.
+	World = physics.NewWorld(float64(Width)-400, float64(Height))
+
+	// Speeds, in pixels per second.
+	WalkSpeed      = 42.0
+	TurnSpeed      = 120.0
+	JumpSpeed      = 720.0
+	KnockbackSpeed = 300.0
+	KnockbackLift  = 250.0
+
+	ColorPlatform = color.RGBA{0x4a, 0x2c, 0x0a, 0xff}
+
+	// gopherHitbox ignores the transparent space around the sprites.
+	gopherHitbox = physics.Rect{
+		X: 32,
+		Y: 32,
+		W: float64(gopherSize) - 64,
+		H: float64(gopherSize) - 32,
+	}
+)
+
+// LoadScene loads the world settings and platforms from the scene file.
+func LoadScene(fileName string) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		log.E("scene: error loading %v, using an empty scene: %v", fileName, err)
+		return
+	}
+	if err := json.Unmarshal(b, World); err != nil {
+		log.E("scene: error deserializing %v: %v", fileName, err)
+		return
+	}
+	FitPlatforms(World, float64(Height)/SceneHeight)
+	log.I("scene loaded: %d platforms", len(World.Platforms))
+}
+
+// FitPlatforms scales the platforms of the scene to the resolution, and
+// drops the ones left outside the world.
+func FitPlatforms(w *physics.World, scale float64) {
+	platforms := w.Platforms[:0]
+	for _, p := range w.Platforms {
+		p.X, p.Y, p.W = p.X*scale, p.Y*scale, p.W*scale
+		if p.X+p.W <= w.Left || p.X >= w.Right || p.Y < w.Top || p.Y > w.Floor {
+			log.W("scene: platform at %.0f,%.0f is outside the world, ignoring it", p.X, p.Y)
+			continue
+		}
+		platforms = append(platforms, p)
+	}
+	w.Platforms = platforms
+}
+
+// IsVisible returns true if the viewer gopher is on screen: recently
+// active, or sleeping during the stream they chatted in.
+func (g *Game) IsVisible(v *Viewer) bool {
+	if time.Since(g.LastActivity(v)) < GopherDrawingTimeout {
+		return true
+	}
+	curr, _ := g.CurrentStream()
+	return v.Behavior == BehaviorSleep && v.LastStream == curr
+}
+
+// UpdatePhysics moves the gophers on screen.
+func (g *Game) UpdatePhysics() {
+	bodies := make([]*physics.Body, 0, len(g.UIDs))
+	for _, uid := range g.UIDs {
+		v := g.Viewers[uid]
+		if !g.IsVisible(v) {
+			continue
+		}
+		v.Hitbox = v.CharacterHitbox()
+		bodies = append(bodies, &v.Body)
+	}
+	World.Update(1/float64(ebiten.TPS()), bodies)
+}
+
+// DrawPlatforms draws the platforms, unless they came from the map of the
+// stage, which draws them.
+func DrawPlatforms(screen *ebiten.Image) {
+	if Stage != nil && Stage.HasPlatforms {
+		return
+	}
+	opts := &ebiten.DrawImageOptions{}
+	for _, p := range World.Platforms {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(p.W, 8)
+		opts.GeoM.Translate(p.X, p.Y)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(ColorPlatform)
+		screen.DrawImage(pixel, opts)
+	}
+}
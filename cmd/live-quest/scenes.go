@@ -0,0 +1,635 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/message"
+	"github.com/codigolandia/live-quest/physics"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Scene is a screen of the game: the lobby where the gophers wander, the
+// fight arena, the full screen leader board or the credits.
+type Scene int
+
+const (
+	SceneLobby Scene = iota
+	SceneArena
+	SceneLeaderBoard
+	SceneCredits
+)
+
+func (s Scene) String() string {
+	switch s {
+	case SceneLobby:
+		return "lobby"
+	case SceneArena:
+		return "arena"
+	case SceneLeaderBoard:
+		return "leaderboard"
+	case SceneCredits:
+		return "credits"
+	}
+	return "invalid"
+}
+
+// ParseScene parses the scene name, in english or portuguese.
+func ParseScene(name string) (Scene, error) {
+	switch strings.ToLower(name) {
+	case "lobby", "inicio", "início":
+		return SceneLobby, nil
+	case "arena":
+		return SceneArena, nil
+	case "leaderboard", "ranking", "placar":
+		return SceneLeaderBoard, nil
+	case "credits", "creditos", "créditos":
+		return SceneCredits, nil
+	}
+	return SceneLobby, fmt.Errorf("invalid scene %q", name)
+}
+
+var (
+	// SceneKeys switch to each scene: F1 is the lobby, F2 the arena, F3
+	// the leader board and F4 the credits.
+	SceneKeys = []ebiten.Key{ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4}
+
+	// SceneTransition is the animation between scenes: fade or wipe.
+	SceneTransition = "fade"
+
+	// TransitionFrames is the duration of the transition; the scene is
+	// switched halfway, while the screen is covered.
+	TransitionFrames = 40
+
+	// ArenaLinger is how long the arena stays after a fight, in frames,
+	// before going back to the lobby, so the next fight of the queue
+	// starts without leaving it.
+	ArenaLinger = 3 * 60
+
+	// SceneScheduleSpec shows scenes at times of the day, like
+	// credits@22:00, or periodically, like leaderboard/15m/30s, which
+	// shows the leader board for 30s every 15 minutes. Entries are
+	// separated by commas.
+	SceneScheduleSpec string
+
+	// FightDistance is the space between the fighters in the arena.
+	FightDistance = 200.0
+
+	// StandRows are the rows of the spectator stands at both sides of the
+	// arena, each StandStep pixels above the previous one and a seat
+	// further from the ring.
+	StandRows = 3
+	StandStep = 64.0
+	SeatWidth = 80.0
+
+	// LeaderBoardSize is the number of viewers in the full screen leader
+	// board.
+	LeaderBoardSize = 10
+
+	// CreditsSpeed is how fast the credits scroll, in pixels per second.
+	CreditsSpeed = 60.0
+
+	ColorStand = color.RGBA{0x6b, 0x45, 0x1c, 0xff}
+	ColorPanel = color.RGBA{0x20, 0x20, 0x30, 0xff}
+)
+
+// sceneManager switches between the scenes of the game.
+type sceneManager struct {
+	Current Scene
+
+	// next is the scene shown halfway through the transition, while
+	// frame counts its frames; frame is zero out of transitions.
+	next  Scene
+	frame int
+
+	// auto is set when a fight opened the arena, which goes back to the
+	// lobby after the fights, and idle counts the frames without fights.
+	auto bool
+	idle int
+
+	// started is the frame the current scene started, to scroll texts.
+	started int
+
+	schedule []*ScheduledScene
+
+	// platforms are the platforms of the world, restored when leaving
+	// the arena.
+	platforms []physics.Platform
+}
+
+// ShowScene switches to the scene with a transition. Showing the current
+// scene again lays it out again, like the arena for a new fight.
+func (g *Game) ShowScene(s Scene) {
+	sc := &g.scenes
+	if TransitionFrames <= 0 {
+		g.EnterScene(s)
+		return
+	}
+	// After the switch, the transition turns back at the same coverage
+	if sc.frame > TransitionFrames/2 {
+		sc.frame = TransitionFrames - sc.frame
+	}
+	sc.frame = max(sc.frame, 1)
+	sc.next = s
+	log.D("scenes: switching from %v to %v", sc.Current, s)
+}
+
+// EnterScene switches to the scene immediately.
+func (g *Game) EnterScene(s Scene) {
+	sc := &g.scenes
+	if sc.Current == SceneArena {
+		g.CloseArena()
+	}
+	sc.Current = s
+	sc.started = g.Count
+	sc.idle = 0
+	if s != SceneArena {
+		sc.auto = false
+	}
+	switch s {
+	case SceneArena:
+		g.OpenArena()
+	}
+	log.I("scenes: showing %v", s)
+}
+
+// Transition returns the progress of the scene transition, from 0 to 1,
+// and false out of transitions.
+func (g *Game) Transition() (float64, bool) {
+	sc := &g.scenes
+	if sc.frame == 0 || TransitionFrames <= 0 {
+		return 0, false
+	}
+	return float64(sc.frame) / float64(TransitionFrames), true
+}
+
+// UpdateScenes switches the scenes with the hotkeys, the schedule and the
+// fights, and animates the transitions.
+func (g *Game) UpdateScenes() {
+	sc := &g.scenes
+	for i, k := range SceneKeys {
+		if inpututil.IsKeyJustPressed(k) {
+			sc.auto = false
+			g.ShowScene(Scene(i))
+		}
+	}
+	if g.Count%60 == 0 {
+		g.UpdateSchedule(time.Now())
+	}
+	g.UpdateTransition()
+}
+
+// UpdateTransition animates the transition, and closes the arena opened
+// by the fights once they are over.
+func (g *Game) UpdateTransition() {
+	sc := &g.scenes
+	if sc.Current == SceneArena && sc.auto && sc.frame == 0 {
+		if g.FightState.CurrentTurn != "" || g.Tournament != nil {
+			sc.idle = 0
+		} else if sc.idle++; sc.idle > ArenaLinger {
+			g.ShowScene(SceneLobby)
+		}
+	}
+	if sc.frame == 0 {
+		return
+	}
+	if sc.frame == TransitionFrames/2 {
+		g.EnterScene(sc.next)
+	}
+	if sc.frame++; sc.frame > TransitionFrames {
+		sc.frame = 0
+	}
+}
+
+// OpenFightArena shows the arena for the fight that just started.
+func (g *Game) OpenFightArena() {
+	sc := &g.scenes
+	if sc.Current != SceneArena || sc.frame != 0 {
+		sc.auto = true
+	}
+	g.ShowScene(SceneArena)
+}
+
+// ArenaCenter returns the horizontal center of the ring.
+func ArenaCenter() float64 {
+	return (World.Left + World.Right) / 2
+}
+
+// Stands returns the rows of the spectator stands, at the left and at the
+// right of the ring, from the bottom row to the top one.
+func Stands() (left, right []physics.Platform) {
+	cx := ArenaCenter()
+	ring := FightDistance/2 + float64(gopherSize) + 64
+	for i := range StandRows {
+		y := World.Floor - float64(i+1)*StandStep
+		w := cx - ring - World.Left - float64(i)*SeatWidth
+		if w < SeatWidth {
+			break
+		}
+		left = append(left, physics.Platform{X: World.Left, Y: y, W: w, OneWay: true})
+		right = append(right, physics.Platform{X: World.Right - w, Y: y, W: w, OneWay: true})
+	}
+	return left, right
+}
+
+// OpenArena adds the stands to the world and places the fighters and the
+// spectators.
+func (g *Game) OpenArena() {
+	g.scenes.platforms = World.Platforms
+	left, right := Stands()
+	World.Platforms = append(append(append([]physics.Platform{}, g.scenes.platforms...), left...), right...)
+	g.UpdateFightPositions()
+}
+
+// CloseArena removes the stands from the world.
+func (g *Game) CloseArena() {
+	World.Platforms = g.scenes.platforms
+	g.scenes.platforms = nil
+}
+
+// SeatSpectators seats the visible gophers on the stands, the ones with
+// more XP in the front rows, closer to the ring.
+func (g *Game) SeatSpectators(crowd []*Viewer) {
+	type seat struct {
+		x, y float64
+		left bool
+	}
+	left, right := Stands()
+	seats := make([]seat, 0)
+	for i := range left {
+		l, r := left[i], right[i]
+		for k := 0; float64(k+1)*SeatWidth <= l.W; k++ {
+			dx := (float64(k) + 0.5) * SeatWidth
+			seats = append(seats,
+				seat{x: l.X + l.W - dx, y: l.Y, left: true},
+				seat{x: r.X + dx, y: r.Y})
+		}
+	}
+	if len(seats) == 0 {
+		return
+	}
+	sort.Sort(ByXP(crowd))
+	for i, v := range crowd {
+		// When the seats are over, the gophers pile up on the stands
+		s := seats[i%len(seats)]
+		v.Hitbox = v.CharacterHitbox()
+		v.VelX, v.VelY, v.KnockX = 0, 0, 0
+		v.PosX = s.x - float64(gopherSize)/2
+		v.SetBottom(s.y)
+		v.SetBehavior(BehaviorSit)
+		v.facingLeft = !s.left
+	}
+}
+
+// Seated returns true if the gopher watches the arena from the stands.
+func (g *Game) Seated(v *Viewer) bool {
+	return g.scenes.Current == SceneArena && v.Behavior == BehaviorSit
+}
+
+// ShowsGophers returns true if the current scene draws the gophers.
+func (g *Game) ShowsGophers() bool {
+	switch g.scenes.Current {
+	case SceneLobby, SceneArena:
+		return true
+	}
+	return false
+}
+
+// DrawScene draws the current scene and the transition.
+func (g *Game) DrawScene(screen *ebiten.Image) {
+	DrawBackground(screen)
+	t := float64(g.Count) / float64(ebiten.TPS())
+	DrawMap(screen, false, t)
+	switch g.scenes.Current {
+	case SceneLobby, SceneArena:
+		if g.scenes.Current == SceneArena {
+			DrawStands(screen)
+		}
+		DrawPlatforms(screen)
+		g.DrawViewers(screen)
+		g.DrawBoss(screen)
+		g.DrawProjectiles(screen)
+		DrawMap(screen, true, t)
+		g.DrawFight(screen)
+		g.DrawTournament(screen)
+		g.DrawTeamBattle(screen)
+		g.DrawFloatingTexts(screen)
+		g.DrawBubbles(screen)
+		g.DrawHUD(screen)
+	default:
+		DrawPanel(screen, g.ScenePanel())
+	}
+	g.DrawTransition(screen)
+}
+
+// DrawStands fills the stands down to the floor, with the seats on top.
+func DrawStands(screen *ebiten.Image) {
+	opts := &ebiten.DrawImageOptions{}
+	left, right := Stands()
+	for _, p := range append(left, right...) {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(p.W, World.Floor-p.Y)
+		opts.GeoM.Translate(p.X, p.Y)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(ColorStand)
+		opts.ColorScale.Scale(0.6, 0.6, 0.6, 1)
+		screen.DrawImage(pixel, opts)
+
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(p.W, 8)
+		opts.GeoM.Translate(p.X, p.Y)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(ColorPlatform)
+		screen.DrawImage(pixel, opts)
+	}
+}
+
+// Curtain is the rectangle covering the screen during the transitions,
+// with its opacity.
+type Curtain struct {
+	X     float64 `json:"x"`
+	W     float64 `json:"w"`
+	Alpha float64 `json:"alpha"`
+}
+
+// TransitionCurtain returns the curtain at the progress p of the
+// transition: fades cover the whole screen, getting opaque until halfway
+// and then clear; wipes cover it from the left and uncover it to the
+// right.
+func TransitionCurtain(p float64) Curtain {
+	w := float64(Width)
+	if SceneTransition == "wipe" {
+		if p < 0.5 {
+			return Curtain{W: w * 2 * p, Alpha: 1}
+		}
+		x := w * (2*p - 1)
+		return Curtain{X: x, W: w - x, Alpha: 1}
+	}
+	return Curtain{W: w, Alpha: 1 - math.Abs(2*p-1)}
+}
+
+func (g *Game) DrawTransition(screen *ebiten.Image) {
+	p, ok := g.Transition()
+	if !ok {
+		return
+	}
+	c := TransitionCurtain(p)
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(c.W, float64(Height))
+	opts.GeoM.Translate(c.X, 0)
+	opts.ColorScale.Scale(0, 0, 0, float32(c.Alpha))
+	screen.DrawImage(pixel, opts)
+}
+
+// Panel is the text of the full screen scenes: a title and rows of
+// columns, whose widths are fractions of the panel; rows with a single
+// column are centered. The first row is at Y.
+type Panel struct {
+	Title   string     `json:"title"`
+	Rows    [][]string `json:"rows"`
+	Columns []float64  `json:"columns,omitempty"`
+	Y       float64    `json:"y"`
+}
+
+// PanelWidth returns the width of the full screen panels.
+func PanelWidth() float64 {
+	return min(float64(Width)-200, 1000)
+}
+
+// RowHeight returns the space between the rows of the panels.
+func RowHeight() float64 {
+	return math.Round(TextHeight() * 1.5)
+}
+
+// ScenePanel returns the panel of the leader board and credits scenes,
+// or nil for the others.
+func (g *Game) ScenePanel() *Panel {
+	switch g.scenes.Current {
+	case SceneLeaderBoard:
+		p := &Panel{
+			Title:   "Ranking",
+			Columns: []float64{0.1, 0.45, 0.15, 0.15, 0.15},
+			Y:       float64(Height)/4 + RowHeight(),
+		}
+		p.Rows = append(p.Rows, []string{"#", "Nome", "Nível", "XP", "V/D"})
+		for i, v := range g.TopViewers(LeaderBoardSize) {
+			p.Rows = append(p.Rows, []string{
+				strconv.Itoa(i + 1),
+				v.Name,
+				strconv.Itoa(v.Level()),
+				strconv.Itoa(v.XP),
+				fmt.Sprintf("%d/%d", v.FightsWon, v.FightsLost),
+			})
+		}
+		return p
+	case SceneCredits:
+		p := &Panel{}
+		for _, line := range g.CreditLines() {
+			p.Rows = append(p.Rows, []string{line})
+		}
+		// The credits scroll up from the bottom of the screen, and start
+		// again after the last line leaves it
+		h := float64(Height) + float64(len(p.Rows))*RowHeight()
+		elapsed := float64(g.Count-g.scenes.started) / float64(ebiten.TPS())
+		p.Y = float64(Height) - math.Mod(elapsed*CreditsSpeed, h)
+		return p
+	}
+	return nil
+}
+
+// Chatters returns the viewers who chatted in the current stream.
+func (g *Game) Chatters() []*Viewer {
+	curr, _ := g.CurrentStream()
+	chatters := make([]*Viewer, 0, len(g.UIDs))
+	for _, uid := range g.UIDs {
+		if v := g.Viewers[uid]; v.LastStream == curr {
+			chatters = append(chatters, v)
+		}
+	}
+	sort.Sort(ByXP(chatters))
+	return chatters
+}
+
+// CreditLines returns the lines of the credits, with every chatter of the
+// stream.
+func (g *Game) CreditLines() []string {
+	lines := []string{"Obrigado por assistir!", ""}
+	chatters := g.Chatters()
+	if len(chatters) > 0 {
+		lines = append(lines, "Chat de hoje", "")
+	}
+	for _, v := range chatters {
+		lines = append(lines, fmt.Sprintf("%s (%s)", v.Name, v.Platform))
+	}
+	return append(lines, "", "Live Quest")
+}
+
+// DrawPanel draws the panel centered on the screen.
+func DrawPanel(screen *ebiten.Image, p *Panel) {
+	if p == nil {
+		return
+	}
+	pw := PanelWidth()
+	px := (float64(Width) - pw) / 2
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(pw, float64(Height))
+	opts.GeoM.Translate(px, 0)
+	opts.ColorScale.ScaleWithColor(ColorPanel)
+	screen.DrawImage(pixel, opts)
+
+	if p.Title != "" {
+		DrawTextAt(screen, p.Title, (float64(Width)-TextWidth(p.Title))/2, float64(Height)/4-RowHeight())
+	}
+	rh := RowHeight()
+	for i, row := range p.Rows {
+		py := p.Y + float64(i)*rh
+		if py < -rh || py > float64(Height) {
+			continue
+		}
+		if len(row) == 1 || len(p.Columns) == 0 {
+			txt := strings.Join(row, " ")
+			DrawTextAt(screen, txt, (float64(Width)-TextWidth(txt))/2, py)
+			continue
+		}
+		cx := px + 32
+		for j, cell := range row {
+			DrawTextAt(screen, cell, cx, py)
+			if j < len(p.Columns) {
+				cx += p.Columns[j] * (pw - 64)
+			}
+		}
+	}
+}
+
+// SceneCommand handles the moderator command !scene <name>.
+func (g *Game) SceneCommand(m message.Message, v *Viewer) {
+	if !m.Moderator {
+		log.D("scenes: %v is not a moderator", v.Name)
+		return
+	}
+	fields := strings.Fields(m.Text)
+	for i, f := range fields {
+		if f != "!scene" {
+			continue
+		}
+		if i+1 >= len(fields) {
+			break
+		}
+		s, err := ParseScene(fields[i+1])
+		if err != nil {
+			g.SendMessage(v.Platform, fmt.Sprintf("@%s: cena inválida: %v", v.Name, fields[i+1]))
+			return
+		}
+		g.scenes.auto = false
+		g.ShowScene(s)
+		return
+	}
+	g.SendMessage(v.Platform, fmt.Sprintf("@%s: use !scene lobby, arena, ranking ou creditos", v.Name))
+}
+
+// ScheduledScene is a scene shown at a time of the day, or for a while
+// at every interval.
+type ScheduledScene struct {
+	Scene Scene
+
+	// At is the time of the day, since midnight, if Every is zero.
+	At time.Duration
+
+	Every, For time.Duration
+
+	next, until time.Time
+}
+
+// ParseSceneSchedule parses the schedule entries separated by commas:
+// scene@HH:MM or scene/every/for, like leaderboard/15m/30s.
+func ParseSceneSchedule(spec string) ([]*ScheduledScene, error) {
+	var schedule []*ScheduledScene
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ss := &ScheduledScene{}
+		var name string
+		if n, at, ok := strings.Cut(entry, "@"); ok {
+			t, err := time.Parse("15:04", at)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time in %q, expected HH:MM", entry)
+			}
+			name = n
+			ss.At = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		} else {
+			parts := strings.Split(entry, "/")
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid schedule %q, expected scene@HH:MM or scene/every/for", entry)
+			}
+			every, err1 := time.ParseDuration(parts[1])
+			dur, err2 := time.ParseDuration(parts[2])
+			if err1 != nil || err2 != nil || every <= 0 || dur <= 0 || dur >= every {
+				return nil, fmt.Errorf("invalid intervals in %q", entry)
+			}
+			name, ss.Every, ss.For = parts[0], every, dur
+		}
+		s, err := ParseScene(name)
+		if err != nil {
+			return nil, err
+		}
+		ss.Scene = s
+		schedule = append(schedule, ss)
+	}
+	return schedule, nil
+}
+
+// SetSceneSchedule schedules the scenes from now on.
+func (g *Game) SetSceneSchedule(schedule []*ScheduledScene, now time.Time) {
+	for _, ss := range schedule {
+		if ss.Every > 0 {
+			ss.next = now.Add(ss.Every)
+			continue
+		}
+		y, m, d := now.Date()
+		ss.next = time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(ss.At)
+		if !ss.next.After(now) {
+			ss.next = ss.next.AddDate(0, 0, 1)
+		}
+	}
+	g.scenes.schedule = schedule
+}
+
+// UpdateSchedule shows the scheduled scenes that are due, and goes back
+// to the lobby when the periodic ones are over.
+func (g *Game) UpdateSchedule(now time.Time) {
+	sc := &g.scenes
+	for _, ss := range sc.schedule {
+		if !ss.until.IsZero() && !now.Before(ss.until) {
+			ss.until = time.Time{}
+			if sc.Current == ss.Scene || sc.next == ss.Scene && sc.frame != 0 {
+				g.ShowScene(SceneLobby)
+			}
+		}
+		if now.Before(ss.next) {
+			continue
+		}
+		// Fights keep the arena, and the scene waits for the next time
+		if g.FightState.CurrentTurn != "" {
+			ss.next = ss.next.Add(time.Minute)
+			continue
+		}
+		if ss.Every > 0 {
+			ss.next = now.Add(ss.Every)
+			ss.until = now.Add(ss.For)
+		} else {
+			ss.next = ss.next.AddDate(0, 0, 1)
+		}
+		log.I("scenes: scheduled %v", ss.Scene)
+		sc.auto = false
+		g.ShowScene(ss.Scene)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseScene(t *testing.T) {
+	for tn, tc := range []struct {
+		name    string
+		expect  Scene
+		wantErr bool
+	}{
+		{name: "lobby", expect: SceneLobby},
+		{name: "Arena", expect: SceneArena},
+		{name: "ranking", expect: SceneLeaderBoard},
+		{name: "créditos", expect: SceneCredits},
+		{name: "credits", expect: SceneCredits},
+		{name: "palco", wantErr: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			s, err := ParseScene(tc.name)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s != tc.expect {
+				t.Errorf("expected %v, got %v", tc.expect, s)
+			}
+		})
+	}
+}
+
+func TestParseSceneSchedule(t *testing.T) {
+	for tn, tc := range []struct {
+		spec    string
+		expect  []ScheduledScene
+		wantErr bool
+	}{
+		{spec: ""},
+		{spec: "credits@22:30", expect: []ScheduledScene{
+			{Scene: SceneCredits, At: 22*time.Hour + 30*time.Minute},
+		}},
+		{spec: "leaderboard/15m/30s, arena/1h/2m", expect: []ScheduledScene{
+			{Scene: SceneLeaderBoard, Every: 15 * time.Minute, For: 30 * time.Second},
+			{Scene: SceneArena, Every: time.Hour, For: 2 * time.Minute},
+		}},
+		{spec: "credits@25:00", wantErr: true},
+		{spec: "leaderboard/30s/15m", wantErr: true},
+		{spec: "leaderboard/15m", wantErr: true},
+		{spec: "palco@22:00", wantErr: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			schedule, err := ParseSceneSchedule(tc.spec)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(schedule) != len(tc.expect) {
+				t.Fatalf("expected %d entries, got %d", len(tc.expect), len(schedule))
+			}
+			for i, ss := range schedule {
+				if *ss != tc.expect[i] {
+					t.Errorf("#%d: expected %+v, got %+v", i, tc.expect[i], *ss)
+				}
+			}
+		})
+	}
+}
+
+func TestSceneTransition(t *testing.T) {
+	g := New()
+	g.ShowScene(SceneLeaderBoard)
+	covered := 0.0
+	for range TransitionFrames / 2 {
+		if g.scenes.Current != SceneLobby {
+			t.Fatalf("scene switched before the screen was covered")
+		}
+		p, ok := g.Transition()
+		if !ok {
+			t.Fatalf("transition not running")
+		}
+		covered = TransitionCurtain(p).Alpha
+		g.UpdateTransition()
+	}
+	if g.scenes.Current != SceneLeaderBoard {
+		t.Errorf("scene not switched halfway: %v", g.scenes.Current)
+	}
+	if covered < 0.9 {
+		t.Errorf("screen not covered at the switch: %v", covered)
+	}
+	for range TransitionFrames/2 + 1 {
+		g.UpdateTransition()
+	}
+	if _, ok := g.Transition(); ok {
+		t.Errorf("transition not finished")
+	}
+}
+
+func TestTransitionCurtain(t *testing.T) {
+	defer func(s string) { SceneTransition = s }(SceneTransition)
+	w := float64(Width)
+	for tn, tc := range []struct {
+		transition string
+		p          float64
+		expect     Curtain
+	}{
+		{transition: "fade", p: 0, expect: Curtain{W: w}},
+		{transition: "fade", p: 0.25, expect: Curtain{W: w, Alpha: 0.5}},
+		{transition: "fade", p: 0.5, expect: Curtain{W: w, Alpha: 1}},
+		{transition: "wipe", p: 0.25, expect: Curtain{W: w / 2, Alpha: 1}},
+		{transition: "wipe", p: 0.75, expect: Curtain{X: w / 2, W: w / 2, Alpha: 1}},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			SceneTransition = tc.transition
+			c := TransitionCurtain(tc.p)
+			if c != tc.expect {
+				t.Errorf("expected %+v, got %+v", tc.expect, c)
+			}
+		})
+	}
+}
+
+func TestArena(t *testing.T) {
+	platforms := World.Platforms
+	defer func() { World.Platforms = platforms }()
+	g, p1, p2 := newTestFight()
+	fan := NewViewer()
+	fan.UID, fan.Name, fan.LastSeen = "fan", "fan", time.Now()
+	g.Viewers[fan.UID] = fan
+	g.UIDs = append(g.UIDs, fan.UID)
+
+	for range TransitionFrames {
+		g.UpdateTransition()
+	}
+	if g.scenes.Current != SceneArena {
+		t.Fatalf("fight did not open the arena: %v", g.scenes.Current)
+	}
+	cx := ArenaCenter()
+	if p1.PosX+float64(gopherSize) > cx || p2.PosX < cx-float64(gopherSize)/2 || p1.facingLeft || !p2.facingLeft {
+		t.Errorf("fighters not facing each other in the ring: %v, %v", p1.PosX, p2.PosX)
+	}
+	left, right := Stands()
+	if len(left) != StandRows || len(World.Platforms) != len(platforms)+len(left)+len(right) {
+		t.Fatalf("stands not added to the world: %v", World.Platforms)
+	}
+	if fan.Behavior != BehaviorSit || !g.Seated(fan) || fan.Bottom() != left[0].Y || fan.PosX > left[0].W {
+		t.Errorf("spectator not seated on the front row: %v, %v", fan.PosX, fan.Bottom())
+	}
+
+	g.EndFight(p1, p2)
+	for range ArenaLinger + TransitionFrames + 2 {
+		g.UpdateTransition()
+	}
+	if g.scenes.Current != SceneLobby {
+		t.Errorf("arena still open after the fight: %v", g.scenes.Current)
+	}
+	if len(World.Platforms) != len(platforms) {
+		t.Errorf("stands not removed: %v", World.Platforms)
+	}
+}
+
+func TestUpdateSchedule(t *testing.T) {
+	defer func(n int) { TransitionFrames = n }(TransitionFrames)
+	TransitionFrames = 0
+
+	g := New()
+	now := time.Date(2026, 10, 19, 21, 0, 0, 0, time.Local)
+	schedule, err := ParseSceneSchedule("leaderboard/15m/30s,credits@22:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetSceneSchedule(schedule, now)
+	for tn, tc := range []struct {
+		after  time.Duration
+		expect Scene
+	}{
+		{after: 10 * time.Minute, expect: SceneLobby},
+		{after: 15 * time.Minute, expect: SceneLeaderBoard},
+		{after: 15*time.Minute + 20*time.Second, expect: SceneLeaderBoard},
+		{after: 15*time.Minute + 30*time.Second, expect: SceneLobby},
+		{after: 30 * time.Minute, expect: SceneLeaderBoard},
+		{after: 30*time.Minute + 30*time.Second, expect: SceneLobby},
+		{after: 60 * time.Minute, expect: SceneCredits},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			g.UpdateSchedule(now.Add(tc.after))
+			if g.scenes.Current != tc.expect {
+				t.Errorf("expected %v, got %v", tc.expect, g.scenes.Current)
+			}
+		})
+	}
+}
+
+func TestCreditLines(t *testing.T) {
+	g := New()
+	curr, _ := g.CurrentStream()
+	for _, tc := range []struct {
+		name, stream string
+	}{
+		{"ana", curr},
+		{"bia", "2020-01-01"},
+		{"caio", curr},
+	} {
+		v := NewViewer()
+		v.UID, v.Name, v.Platform, v.LastStream = tc.name, tc.name, "twitch", tc.stream
+		g.Viewers[v.UID] = v
+		g.UIDs = append(g.UIDs, v.UID)
+	}
+	lines := g.CreditLines()
+	for _, name := range []string{"ana (twitch)", "caio (twitch)"} {
+		if !slices.Contains(lines, name) {
+			t.Errorf("chatter %q missing from the credits: %v", name, lines)
+		}
+	}
+	if slices.Contains(lines, "bia (twitch)") {
+		t.Errorf("viewer of another stream in the credits: %v", lines)
+	}
+}
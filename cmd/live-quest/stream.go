@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"io/fs"
+	"maps"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/physics"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	// Headless runs the game without a window, for the browser renderer.
+	Headless bool
+
+	// StreamInterval is how often the game state is sent to the browser
+	// renderer, in frames.
+	StreamInterval = 2
+)
+
+// State is the game state sent to the browser renderer, which draws it
+// on a canvas. Positions are in screen pixels and colors are #rrggbb.
+type State struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Size is the size of the gopher frames, and LineHeight of the texts.
+	Size       int     `json:"size"`
+	LineHeight float64 `json:"lineHeight"`
+
+	// Scene is the current scene; the full screen scenes only have the
+	// Panel, and the arena has the Stands.
+	Scene   string         `json:"scene"`
+	Panel   *Panel         `json:"panel,omitempty"`
+	Stands  []physics.Rect `json:"stands,omitempty"`
+	Curtain *Curtain       `json:"curtain,omitempty"`
+
+	Viewers     []ViewerState `json:"viewers"`
+	Projectiles []SpriteState `json:"projectiles"`
+	Boss        *BossState    `json:"boss,omitempty"`
+	Texts       []TextState   `json:"texts"`
+	HUD         []HUDPanel    `json:"hud"`
+}
+
+// SpriteState is an animation frame drawn at X, Y plus the anchor offset,
+// mirrored when Flip is set.
+type SpriteState struct {
+	Bundle    string  `json:"bundle"`
+	Animation string  `json:"animation"`
+	Frame     int     `json:"frame"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	OffsetX   float64 `json:"offsetX"`
+	OffsetY   float64 `json:"offsetY"`
+	Flip      bool    `json:"flip,omitempty"`
+	Color     string  `json:"color,omitempty"`
+	Color2    string  `json:"color2,omitempty"`
+}
+
+type ViewerState struct {
+	SpriteState
+	Name     string            `json:"name"`
+	Level    int               `json:"level"`
+	Platform string            `json:"platform"`
+	HP       int               `json:"hp"`
+	XP       float64           `json:"xp"`
+	Outfit   map[string]string `json:"outfit,omitempty"`
+	Bubble   []string          `json:"bubble,omitempty"`
+}
+
+type BossState struct {
+	SpriteState
+	Scale float64 `json:"scale"`
+	Title string  `json:"title"`
+	HP    float64 `json:"hp"`
+}
+
+type TextState struct {
+	Text  string  `json:"text"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Color string  `json:"color,omitempty"`
+	// Center aligns the text horizontally at X.
+	Center bool `json:"center,omitempty"`
+}
+
+// sprite returns the state of the animation frame, falling back to the
+// gopher for animations of asset packs, which the browser can't load.
+func sprite(bundle, name string, frame int) SpriteState {
+	if a, ok := assets.Bundles[bundle][name]; !ok || len(a.FrameFiles) == 0 {
+		bundle = DefaultCharacter
+	}
+	a := assets.Bundles[bundle][name]
+	s := SpriteState{Bundle: bundle, Animation: name, Frame: frame}
+	if a != nil {
+		s.Frame = min(frame, len(a.Frames)-1)
+		s.OffsetX, s.OffsetY = anchorOffset(a)
+	}
+	return s
+}
+
+// State returns the state of the scene: the visible gophers and the HUD,
+// or the panel of the full screen scenes.
+func (g *Game) State() *State {
+	s := &State{Width: Width, Height: Height, Size: gopherSize, LineHeight: TextHeight()}
+	s.Scene = g.scenes.Current.String()
+	if p, ok := g.Transition(); ok {
+		c := TransitionCurtain(p)
+		s.Curtain = &c
+	}
+	if !g.ShowsGophers() {
+		s.Panel = g.ScenePanel()
+		return s
+	}
+	if g.scenes.Current == SceneArena {
+		left, right := Stands()
+		for _, p := range append(left, right...) {
+			s.Stands = append(s.Stands, physics.Rect{X: p.X, Y: p.Y, W: p.W, H: World.Floor - p.Y})
+		}
+	}
+	for _, uid := range g.UIDs {
+		v := g.Viewers[uid]
+		if !g.IsVisible(v) {
+			continue
+		}
+		v.CurrAnim()
+		vs := ViewerState{
+			SpriteState: sprite(v.CharacterName(), v.Animation, v.AnimationFrame),
+			Name:        v.Name,
+			Level:       v.Level(),
+			Platform:    v.Platform,
+			HP:          v.HP,
+			XP:          Levels.Curve.Progress(v.XP),
+			Bubble:      v.bubble.Lines,
+		}
+		vs.X, vs.Y, vs.Flip = v.PosX, v.PosY, v.facingLeft
+		vs.Color = HexColor(v.SpriteColor)
+		if v.SpriteColor2.A != 0 {
+			vs.Color2 = HexColor(v.SpriteColor2)
+		}
+		if v.CharacterName() == DefaultCharacter {
+			// Read under the viewer lock like the other fields, and copied
+			// so the state does not share the map with the viewer
+			v.mu.Lock()
+			if len(v.Outfit) > 0 {
+				vs.Outfit = maps.Clone(v.Outfit)
+			}
+			v.mu.Unlock()
+		}
+		if v.bubble.Frames <= 0 {
+			vs.Bubble = nil
+		}
+		s.Viewers = append(s.Viewers, vs)
+	}
+	for _, p := range g.projectiles {
+		ps := sprite(p.Move.Bundle, "flying", p.Frame)
+		ps.X, ps.Y, ps.Flip = p.PosX, p.PosY, p.VelX < 0
+		ps.OffsetX, ps.OffsetY = 0, 0
+		ps.Color = HexColor(p.Move.Color)
+		s.Projectiles = append(s.Projectiles, ps)
+	}
+	if b := g.Boss; b != nil {
+		bs := &BossState{
+			SpriteState: sprite(DefaultCharacter, "standing", g.BossFrame()),
+			Scale:       BossScale,
+			Title:       b.Title(),
+			HP:          float64(b.HP) / float64(b.MaxHP),
+		}
+		bs.X, bs.Y = b.Pos()
+		bs.OffsetX, bs.OffsetY = 0, 0
+		bs.Color = HexColor(BossColor)
+		s.Boss = bs
+	}
+	for _, ft := range g.floatingTexts {
+		s.Texts = append(s.Texts, TextState{Text: ft.Text, X: ft.PosX, Y: ft.PosY, Color: HexColor(color.RGBAModel.Convert(ft.Color).(color.RGBA))})
+	}
+	if title, turn, history := g.FightLines(); title != "" {
+		p1, p2 := g.Viewers[g.FightState.Player1], g.Viewers[g.FightState.Player2]
+		cx, py := (p1.PosX+p2.PosX+float64(gopherSize))/2, float64(Height)/3
+		s.Texts = append(s.Texts,
+			TextState{Text: title, X: cx, Y: py, Center: true},
+			TextState{Text: turn, X: cx, Y: py + 24, Color: HexColor(ColorDefend), Center: true})
+		for i, txt := range history {
+			s.Texts = append(s.Texts, TextState{Text: txt, X: cx, Y: py + 48 + float64(i*24), Center: true})
+		}
+	}
+	s.HUD = g.HUDPanels()
+	return s
+}
+
+// streamHub sends the game state to the connected browsers.
+type streamHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+func (h *streamHub) add() chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients == nil {
+		h.clients = make(map[chan []byte]struct{})
+	}
+	// A single pending state: slow clients skip frames instead of lagging
+	ch := make(chan []byte, 1)
+	h.clients[ch] = struct{}{}
+	return ch
+}
+
+func (h *streamHub) remove(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, ch)
+}
+
+// Len returns the number of connected browsers.
+func (h *streamHub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Publish sends the message to the clients that are not busy.
+func (h *streamHub) Publish(msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// PublishState sends the game state to the browsers every StreamInterval
+// frames; it is called by Update.
+func (g *Game) PublishState() {
+	if g.Count%StreamInterval != 0 || g.stream.Len() == 0 {
+		return
+	}
+	b, err := json.Marshal(g.State())
+	if err != nil {
+		log.E("stream: error serializing the state: %v", err)
+		return
+	}
+	g.stream.Publish(b)
+}
+
+// ServeStream streams the game state over a WebSocket, until the browser
+// disconnects.
+func (g *Game) ServeStream(ws *websocket.Conn) {
+	ch := g.stream.add()
+	defer g.stream.remove(ch)
+	log.I("stream: browser connected from %v", ws.Request().RemoteAddr)
+
+	// The browser sends nothing, so reading detects when it leaves
+	closed := make(chan struct{})
+	go func() {
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		close(closed)
+	}()
+	for {
+		select {
+		case msg := <-ch:
+			ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := websocket.Message.Send(ws, string(msg)); err != nil {
+				log.D("stream: browser disconnected: %v", err)
+				return
+			}
+		case <-closed:
+			log.D("stream: browser disconnected")
+			return
+		}
+	}
+}
+
+// spriteManifest lists the images of the embedded animations, so the
+// browser can load them.
+type spriteManifest struct {
+	Bundles   map[string]map[string]spriteFiles `json:"bundles"`
+	Cosmetics map[string]map[string]spriteFiles `json:"cosmetics"`
+	Images    map[string]string                 `json:"images"`
+}
+
+type spriteFiles struct {
+	Frames []string `json:"frames"`
+	Skins  []string `json:"skins,omitempty"`
+}
+
+func manifestBundles(bundles map[string]assets.Bundle) map[string]map[string]spriteFiles {
+	m := make(map[string]map[string]spriteFiles)
+	for name, b := range bundles {
+		for anim, a := range b {
+			if len(a.FrameFiles) == 0 {
+				continue
+			}
+			if m[name] == nil {
+				m[name] = make(map[string]spriteFiles)
+			}
+			m[name][anim] = spriteFiles{Frames: a.FrameFiles, Skins: a.SkinFiles}
+		}
+	}
+	return m
+}
+
+// ServeSprites serves the manifest of the embedded sprites.
+func (g *Game) ServeSprites(w http.ResponseWriter, r *http.Request) {
+	m := spriteManifest{
+		Bundles:   manifestBundles(assets.Bundles),
+		Cosmetics: manifestBundles(assets.Cosmetics),
+		Images: map[string]string{
+			"barBG":   "img/hp_bar_bg.png",
+			"hpBar":   "img/hp_bar_fg.png",
+			"xpBar":   "img/xp_bar_fg.png",
+			"youtube": "img/youtube_icon.png",
+			"twitch":  "img/twitch_icon.png",
+		},
+	}
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		http.Error(w, "game: error serializing sprites: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var spriteHandler http.Handler
+
+func init() {
+	spriteHandler = http.StripPrefix("/sprites/", http.FileServer(http.FS(spriteFS{assets.Assets})))
+}
+
+// spriteFS only serves the images of the embedded assets.
+type spriteFS struct {
+	fs.FS
+}
+
+func (s spriteFS) Open(name string) (fs.File, error) {
+	if path.Ext(name) != ".png" {
+		return nil, fs.ErrNotExist
+	}
+	return s.FS.Open(name)
+}
+
+// RunHeadless updates the game without a window, for the browser
+// renderer, until Update returns an error.
+func RunHeadless(g *Game) error {
+	log.I("headless: running without a window, open /game.html in the browser")
+	t := time.NewTicker(tickDuration())
+	defer t.Stop()
+	for range t.C {
+		if err := g.Update(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	addTestCharacter(t, "robot")
+	g := newTestViewers("a", "b", "c")
+	a, b, c := g.Viewers["a"], g.Viewers["b"], g.Viewers["c"]
+	a.SpriteColor2 = ColorMagenta
+	a.Wear(SlotHat, "mario")
+	a.bubble = SpeechBubble{Lines: []string{"oi"}, Frames: 10}
+	b.Character = "robot"
+	b.Wear(SlotHat, "mario")
+	c.LastSeen = time.Now().Add(-GopherDrawingTimeout - time.Minute)
+
+	s := g.State()
+	if len(s.Viewers) != 2 {
+		t.Fatalf("expected the 2 visible viewers, got %d", len(s.Viewers))
+	}
+	va, vb := s.Viewers[0], s.Viewers[1]
+	if va.Bundle != DefaultCharacter || va.Animation != "standing" || va.Color != "#9cedff" || va.Color2 != "#ff00ff" {
+		t.Errorf("unexpected sprite: %+v", va.SpriteState)
+	}
+	if va.Outfit[SlotHat] != "mario" || len(va.Bubble) != 1 {
+		t.Errorf("expected the hat and the bubble, got %v and %v", va.Outfit, va.Bubble)
+	}
+	// Packs are not served to the browser, so they are drawn as gophers
+	if vb.Bundle != DefaultCharacter || vb.OffsetX != 0 || vb.Outfit != nil {
+		t.Errorf("expected a plain gopher for the pack character, got %+v", vb)
+	}
+	// The top XP panel has the title and the 3 cells of each viewer
+	if len(s.HUD) != 1 || len(s.HUD[0].Texts) != 10 {
+		t.Errorf("expected the top XP panel, got %+v", s.HUD)
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStreamHub(t *testing.T) {
+	var h streamHub
+	fast, slow := h.add(), h.add()
+	h.Publish([]byte("1"))
+	<-fast
+	h.Publish([]byte("2"))
+	if h.Len() != 2 {
+		t.Fatalf("expected 2 clients, got %d", h.Len())
+	}
+	// The slow client skips the second state instead of blocking
+	if msg := string(<-slow); msg != "1" {
+		t.Errorf("expected the first state, got %v", msg)
+	}
+	if msg := string(<-fast); msg != "2" {
+		t.Errorf("expected the second state, got %v", msg)
+	}
+	h.remove(slow)
+	if h.Len() != 1 {
+		t.Errorf("expected 1 client, got %d", h.Len())
+	}
+}
+
+func TestServeSprites(t *testing.T) {
+	g := New()
+	w := httptest.NewRecorder()
+	g.ServeSprites(w, httptest.NewRequest("GET", "/sprites.json", nil))
+	var m spriteManifest
+	if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	standing := m.Bundles[DefaultCharacter]["standing"]
+	if len(standing.Frames) == 0 || len(m.Cosmetics["hat/mario"]) == 0 {
+		t.Fatalf("expected the gopher and the cosmetics, got %v", standing)
+	}
+	for tn, tc := range []struct {
+		path   string
+		status int
+	}{
+		{path: "/sprites/" + standing.Frames[0], status: 200},
+		{path: "/sprites/animations/gopher/attack/animation.json", status: 404},
+		{path: "/sprites/animations/", status: 404},
+	} {
+		w := httptest.NewRecorder()
+		spriteHandler.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("#%d: %v: expected %d, got %d", tn, tc.path, tc.status, w.Code)
+		}
+	}
+}
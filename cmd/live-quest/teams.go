@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/message"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+var (
+	// TeamHP is the HP of each platform team.
+	TeamHP = 1000
+
+	// TeamMeterSize is the number of messages that charge a team attack.
+	TeamMeterSize = 10
+
+	// TeamBattleDuration is how long the battle lasts; when time is up,
+	// the team with more HP wins.
+	TeamBattleDuration = 5 * time.Minute
+
+	// TeamBonusXP is awarded to the viewers of the winning platform that
+	// chatted during the battle.
+	TeamBonusXP = 100
+
+	// TeamBattleKey starts a team battle.
+	TeamBattleKey = ebiten.KeyT
+
+	ColorTwitch  = color.RGBA{0x91, 0x46, 0xff, 0xff}
+	ColorYoutube = color.RGBA{0xff, 0x00, 0x00, 0xff}
+)
+
+// Teams are the platforms that face each other, from left to right.
+var Teams = []string{message.PlatformTwitch, message.PlatformYoutube}
+
+// TeamBattle is a battle of all Twitch gophers against all YouTube
+// gophers, where every chat message charges the team attack meter.
+type TeamBattle struct {
+	HP    map[string]int `json:"hp"`
+	Meter map[string]int `json:"meter"`
+
+	// Members are the viewers that chatted during the battle.
+	Members map[string]struct{} `json:"members"`
+	Expires time.Time           `json:"expires"`
+}
+
+func NewTeamBattle() *TeamBattle {
+	tb := &TeamBattle{
+		HP:      make(map[string]int),
+		Meter:   make(map[string]int),
+		Members: make(map[string]struct{}),
+		Expires: time.Now().Add(TeamBattleDuration),
+	}
+	for _, team := range Teams {
+		tb.HP[team] = TeamHP
+	}
+	return tb
+}
+
+// Opponent returns the team facing the platform.
+func (tb *TeamBattle) Opponent(team string) string {
+	if team == message.PlatformTwitch {
+		return message.PlatformYoutube
+	}
+	return message.PlatformTwitch
+}
+
+// Charge adds a message to the team meter, returning true when the meter
+// is full and the team attacks.
+func (tb *TeamBattle) Charge(team string) bool {
+	tb.Meter[team]++
+	if tb.Meter[team] < TeamMeterSize {
+		return false
+	}
+	tb.Meter[team] = 0
+	return true
+}
+
+// Over returns true if one of the teams was defeated.
+func (tb *TeamBattle) Over() bool {
+	for _, team := range Teams {
+		if tb.HP[team] <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Winner returns the team with more HP, or an empty string on a draw.
+func (tb *TeamBattle) Winner() string {
+	a, b := Teams[0], Teams[1]
+	switch {
+	case tb.HP[a] > tb.HP[b]:
+		return a
+	case tb.HP[b] > tb.HP[a]:
+		return b
+	}
+	return ""
+}
+
+// TeamDamage returns the damage of a team attack.
+func TeamDamage() int {
+	return 50 + rand.Intn(21)
+}
+
+func teamColor(team string) color.RGBA {
+	if team == message.PlatformYoutube {
+		return ColorYoutube
+	}
+	return ColorTwitch
+}
+
+// StartTeamBattle lines up the teams facing each other.
+func (g *Game) StartTeamBattle() {
+	if g.TeamBattle != nil || g.FightState.CurrentTurn != "" {
+		log.D("teams: unable to start, the arena is busy")
+		return
+	}
+	g.TeamBattle = NewTeamBattle()
+	g.UpdateTeamPositions()
+	log.I("teams: battle started")
+	g.Broadcast(fmt.Sprintf("Batalha de plataformas: Twitch vs YouTube! "+
+		"Cada mensagem carrega o ataque do seu time; a batalha dura %v", TeamBattleDuration))
+}
+
+// TeamBattleCommand handles the moderator command !teams
+func (g *Game) TeamBattleCommand(m message.Message, v *Viewer) {
+	if !m.Moderator {
+		log.D("teams: %v is not a moderator", v.Name)
+		return
+	}
+	g.StartTeamBattle()
+}
+
+// UpdateTeamPositions places each team on one half of the arena.
+func (g *Game) UpdateTeamPositions() {
+	arena := float64(Width) - 400
+	for i, team := range Teams {
+		members := make([]*Viewer, 0, len(g.UIDs))
+		for _, uid := range g.UIDs {
+			if v := g.Viewers[uid]; v.Platform == team {
+				members = append(members, v)
+			}
+		}
+		sort.Sort(ByXP(members))
+
+		half := arena/2 - float64(gopherSize)
+		dx := half / float64(len(members)+1)
+		for j, v := range members {
+			v.FightStance(i == 1)
+			v.VelX, v.VelY = 0, 0
+			v.PosY = float64(Height - gopherSize)
+			// Stronger gophers stay in the front line
+			v.PosX = half - float64(j)*dx
+			if i == 1 {
+				v.PosX = arena - float64(gopherSize) - v.PosX
+			}
+		}
+	}
+}
+
+// ChargeTeam counts the message of the viewer to the team attack.
+func (g *Game) ChargeTeam(v *Viewer) {
+	tb := g.TeamBattle
+	if tb == nil {
+		return
+	}
+	if _, ok := tb.Members[v.UID]; !ok {
+		tb.Members[v.UID] = struct{}{}
+		// Newcomers join the line of their team
+		g.UpdateTeamPositions()
+	}
+	if !tb.Charge(v.Platform) {
+		return
+	}
+	opponent := tb.Opponent(v.Platform)
+	dmg := min(TeamDamage(), tb.HP[opponent])
+	tb.HP[opponent] -= dmg
+	log.D("teams: %v attacked %v for %d", v.Platform, opponent, dmg)
+
+	px, py := teamBarPos(opponent)
+	g.AddFloatingTextAt(px+teamBarWidth/2, py+32, fmt.Sprintf("-%d", dmg), ColorDamage)
+	if tb.Over() {
+		g.EndTeamBattle()
+	}
+}
+
+func (g *Game) UpdateTeamBattle() {
+	if inpututil.IsKeyJustPressed(TeamBattleKey) {
+		g.StartTeamBattle()
+	}
+	if tb := g.TeamBattle; tb != nil && time.Now().After(tb.Expires) {
+		g.EndTeamBattle()
+	}
+}
+
+// EndTeamBattle awards the bonus XP to the winning team.
+func (g *Game) EndTeamBattle() {
+	tb := g.TeamBattle
+	g.TeamBattle = nil
+	for _, uid := range g.UIDs {
+		g.Viewers[uid].Stop()
+	}
+
+	winner := tb.Winner()
+	if winner == "" {
+		log.I("teams: battle ended in a draw")
+		g.Broadcast("A batalha de plataformas terminou empatada!")
+		return
+	}
+	count := 0
+	for uid := range tb.Members {
+		if v, ok := g.Viewers[uid]; ok && v.Platform == winner {
+			v.IncXP(TeamBonusXP)
+			v.Jump()
+			count++
+		}
+	}
+	log.I("teams: %v won the battle", winner)
+	g.Broadcast(fmt.Sprintf("O time %v venceu a batalha de plataformas! %d gophers ganharam %d XP",
+		winner, count, TeamBonusXP))
+}
+
+const teamBarWidth = 600.0
+
+func teamBarPos(team string) (px, py float64) {
+	if team == Teams[0] {
+		return 64, 24
+	}
+	return float64(Width) - 400 - 64 - teamBarWidth, 24
+}
+
+// DrawTeamBattle draws the team HP bars and attack meters at the top of
+// the screen.
+func (g *Game) DrawTeamBattle(screen *ebiten.Image) {
+	tb := g.TeamBattle
+	if tb == nil {
+		return
+	}
+	opts := &ebiten.DrawImageOptions{}
+	drawBar := func(px, py, w, h float64, clr color.Color) {
+		opts.GeoM.Reset()
+		opts.GeoM.Scale(w, h)
+		opts.GeoM.Translate(px, py)
+		opts.ColorScale.Reset()
+		opts.ColorScale.ScaleWithColor(clr)
+		screen.DrawImage(pixel, opts)
+	}
+	bg := color.RGBA{0x40, 0x40, 0x40, 0xff}
+	for _, team := range Teams {
+		px, py := teamBarPos(team)
+		hp := float64(max(0, tb.HP[team])) / float64(TeamHP)
+		meter := float64(tb.Meter[team]) / float64(TeamMeterSize)
+
+		DrawColoredTextAt(screen, fmt.Sprintf("%s %d/%d", team, tb.HP[team], TeamHP), px, py, teamColor(team))
+		drawBar(px, py+24, teamBarWidth, 16, bg)
+		drawBar(px, py+24, teamBarWidth*hp, 16, teamColor(team))
+		drawBar(px, py+44, teamBarWidth, 6, bg)
+		drawBar(px, py+44, teamBarWidth*meter, 6, color.White)
+	}
+
+	remaining := max(0, int(time.Until(tb.Expires).Seconds()))
+	txt := fmt.Sprintf("VS - %ds", remaining)
+	DrawTextAt(screen, txt, (float64(Width)-400-TextWidth(txt))/2, 48)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/codigolandia/live-quest/message"
+)
+
+func TestTeamBattleCharge(t *testing.T) {
+	tb := NewTeamBattle()
+	for i := 1; i < TeamMeterSize; i++ {
+		if tb.Charge(message.PlatformTwitch) {
+			t.Fatalf("unexpected attack after %d messages", i)
+		}
+	}
+	if !tb.Charge(message.PlatformTwitch) {
+		t.Errorf("expected attack after %d messages", TeamMeterSize)
+	}
+	if tb.Meter[message.PlatformTwitch] != 0 {
+		t.Errorf("meter not reset: %d", tb.Meter[message.PlatformTwitch])
+	}
+}
+
+func TestTeamBattleWinner(t *testing.T) {
+	for tn, tc := range []struct {
+		twitch, youtube int
+		expect          string
+		over            bool
+	}{
+		{twitch: 1000, youtube: 1000, expect: ""},
+		{twitch: 900, youtube: 1000, expect: message.PlatformYoutube},
+		{twitch: 10, youtube: 0, expect: message.PlatformTwitch, over: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			tb := NewTeamBattle()
+			tb.HP[message.PlatformTwitch] = tc.twitch
+			tb.HP[message.PlatformYoutube] = tc.youtube
+			if w := tb.Winner(); w != tc.expect {
+				t.Errorf("expected winner %q, got %q", tc.expect, w)
+			}
+			if tb.Over() != tc.over {
+				t.Errorf("expected over to be %v", tc.over)
+			}
+		})
+	}
+}
+
+func TestChargeTeam(t *testing.T) {
+	g := New()
+	tv, yv := NewViewer(), NewViewer()
+	tv.UID, tv.Name, tv.Platform = "tw", "tw", message.PlatformTwitch
+	yv.UID, yv.Name, yv.Platform = "yt", "yt", message.PlatformYoutube
+	for _, v := range []*Viewer{tv, yv} {
+		g.Viewers[v.UID] = v
+		g.UIDs = append(g.UIDs, v.UID)
+	}
+	g.StartTeamBattle()
+	if tv.PosX >= yv.PosX {
+		t.Errorf("teams not facing each other: %v, %v", tv.PosX, yv.PosX)
+	}
+
+	// Twitch chats until YouTube is defeated
+	for i := 0; g.TeamBattle != nil && i < 1000; i++ {
+		g.ChargeTeam(tv)
+	}
+	if g.TeamBattle != nil {
+		t.Fatalf("battle should be over")
+	}
+	if tv.XP != TeamBonusXP || yv.XP != 0 {
+		t.Errorf("unexpected XP: twitch %d, youtube %d", tv.XP, yv.XP)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
+)
+
+// TextOutline is the width of the black outline around texts, in pixels.
+var TextOutline = 2
+
+// TextCacheFrames is how many frames a text is kept in the cache after it
+// was last drawn.
+var TextCacheFrames = 60 * 10
+
+// cachedText is a text rasterized with its outline, so it is drawn with a
+// single image instead of once per glyph and outline pass. Each text has
+// its own small image; Ebiten packs the ones that stop changing in its
+// shared atlases, so drawing many of them is still batched.
+type cachedText struct {
+	img *ebiten.Image
+
+	// origin is where the dot of the text is in the image.
+	origin image.Point
+
+	lastUsed int
+}
+
+// textKey is a text drawn with a face.
+type textKey struct {
+	face font.Face
+	txt  string
+}
+
+// textCache keeps the rasterized texts, in white to be tinted when drawn.
+type textCache struct {
+	texts map[textKey]*cachedText
+	frame int
+}
+
+var texts = &textCache{texts: make(map[textKey]*cachedText)}
+
+// get returns the text drawn with the face from the cache, rasterizing it
+// if needed. Empty texts return nil.
+func (c *textCache) get(face font.Face, txt string) *cachedText {
+	key := textKey{face, txt}
+	if t, ok := c.texts[key]; ok {
+		t.lastUsed = c.frame
+		return t
+	}
+	r := text.BoundString(face, txt)
+	if r.Empty() {
+		return nil
+	}
+	r = r.Inset(-TextOutline)
+	t := &cachedText{
+		img:      ebiten.NewImage(r.Dx(), r.Dy()),
+		origin:   r.Min.Mul(-1),
+		lastUsed: c.frame,
+	}
+	for dx := -TextOutline; dx <= TextOutline; dx++ {
+		for dy := -TextOutline; dy <= TextOutline; dy++ {
+			if dx*dx+dy*dy > TextOutline*TextOutline {
+				continue
+			}
+			text.Draw(t.img, txt, face, t.origin.X+dx, t.origin.Y+dy, color.Black)
+		}
+	}
+	text.Draw(t.img, txt, face, t.origin.X, t.origin.Y, color.White)
+	c.texts[key] = t
+	return t
+}
+
+// Sweep removes the texts that were not drawn recently, like the names of
+// viewers that left; it is called once per frame.
+func (c *textCache) Sweep() {
+	c.frame++
+	for key, t := range c.texts {
+		if c.frame-t.lastUsed > TextCacheFrames {
+			t.img.Deallocate()
+			delete(c.texts, key)
+		}
+	}
+}
+
+func DrawTextAt(screen *ebiten.Image, txt string, px, py float64) {
+	DrawColoredTextAt(screen, txt, px, py, color.White)
+}
+
+// DrawColoredTextAt draws the text with an outline; py is the baseline of
+// the first line, and new lines go down by the line height.
+func DrawColoredTextAt(screen *ebiten.Image, txt string, px, py float64, clr color.Color) {
+	DrawTextWith(screen, face, txt, px, py, clr)
+}
+
+// DrawTextWith draws the text with the face, like the sizes of FaceAt.
+func DrawTextWith(screen *ebiten.Image, face font.Face, txt string, px, py float64, clr color.Color) {
+	t := texts.get(face, txt)
+	if t == nil {
+		return
+	}
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(px-float64(t.origin.X), py-float64(t.origin.Y))
+	opts.ColorScale.ScaleWithColor(clr)
+	screen.DrawImage(t.img, opts)
+}
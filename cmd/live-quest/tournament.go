@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/message"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	TournamentSingle = "single"
+	TournamentDouble = "double"
+)
+
+var (
+	// TournamentPrizes is the XP awarded to the top three players.
+	TournamentPrizes = []int{500, 250, 100}
+
+	// TournamentBreak is the pause between rounds, when the bracket is
+	// displayed on screen.
+	TournamentBreak = 10 * time.Second
+
+	MaxTournamentSize = 32
+)
+
+// Match is a fight in the tournament bracket. Matches without P2 are byes.
+type Match struct {
+	Round  int    `json:"round"`
+	P1     string `json:"p1"`
+	P2     string `json:"p2"`
+	Winner string `json:"winner"`
+}
+
+func (m *Match) Bye() bool {
+	return m.P2 == ""
+}
+
+// Tournament is an elimination bracket fought with the fight engine.
+//
+// Players are eliminated after one loss on single elimination, or after
+// two losses on double elimination. Each round pairs players with the
+// same number of losses, so the winners and losers brackets are played
+// side by side, and the grand final is the last undefeated player against
+// the best player with one loss.
+type Tournament struct {
+	Format string `json:"format"`
+	Size   int    `json:"size"`
+	Open   bool   `json:"open"`
+
+	// Players are in seed order once the tournament starts.
+	Players    []string       `json:"players"`
+	Losses     map[string]int `json:"losses"`
+	Eliminated []string       `json:"eliminated"`
+
+	Matches   []*Match  `json:"matches"`
+	Round     int       `json:"round"`
+	Playing   int       `json:"playing"`
+	NextRound time.Time `json:"nextRound"`
+}
+
+func NewTournament(size int, format string) *Tournament {
+	return &Tournament{
+		Format:  format,
+		Size:    size,
+		Open:    true,
+		Losses:  make(map[string]int),
+		Playing: -1,
+	}
+}
+
+func (t *Tournament) Join(uid string) error {
+	switch {
+	case !t.Open:
+		return fmt.Errorf("as inscrições do torneio estão fechadas")
+	case len(t.Players) >= t.Size:
+		return fmt.Errorf("o torneio está lotado")
+	}
+	for _, p := range t.Players {
+		if p == uid {
+			return fmt.Errorf("você já está inscrito")
+		}
+	}
+	t.Players = append(t.Players, uid)
+	return nil
+}
+
+func (t *Tournament) MaxLosses() int {
+	if t.Format == TournamentDouble {
+		return 2
+	}
+	return 1
+}
+
+// Start closes the registration and creates the first round.
+// Players must be already sorted by seed.
+func (t *Tournament) Start() error {
+	if len(t.Players) < 2 {
+		return fmt.Errorf("o torneio precisa de pelo menos 2 jogadores")
+	}
+	t.Open = false
+	t.NewRound()
+	return nil
+}
+
+func (t *Tournament) Started() bool {
+	return !t.Open
+}
+
+// Alive returns the players not eliminated yet, in seed order.
+func (t *Tournament) Alive() (alive []string) {
+	for _, p := range t.Players {
+		if t.Losses[p] < t.MaxLosses() {
+			alive = append(alive, p)
+		}
+	}
+	return alive
+}
+
+func (t *Tournament) Finished() bool {
+	return t.Started() && len(t.Alive()) <= 1
+}
+
+// NewRound pairs the remaining players, highest against lowest seed,
+// among players with the same number of losses.
+func (t *Tournament) NewRound() {
+	t.Round++
+	groups := make([][]string, t.MaxLosses())
+	for _, p := range t.Alive() {
+		groups[t.Losses[p]] = append(groups[t.Losses[p]], p)
+	}
+	leftover := ""
+	for _, grp := range groups {
+		if leftover != "" && len(grp)%2 == 1 {
+			t.Matches = append(t.Matches, &Match{Round: t.Round, P1: leftover, P2: grp[len(grp)-1]})
+			grp = grp[:len(grp)-1]
+			leftover = ""
+		}
+		for len(grp) >= 2 {
+			t.Matches = append(t.Matches, &Match{Round: t.Round, P1: grp[0], P2: grp[len(grp)-1]})
+			grp = grp[1 : len(grp)-1]
+		}
+		if len(grp) == 1 {
+			leftover = grp[0]
+		}
+	}
+	if leftover != "" {
+		t.Matches = append(t.Matches, &Match{Round: t.Round, P1: leftover, Winner: leftover})
+	}
+}
+
+// NextMatch returns the index of the next match to be fought, or -1.
+func (t *Tournament) NextMatch() int {
+	for i, m := range t.Matches {
+		if m.Winner == "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsPlaying returns true if the fight between the players is the current
+// tournament match.
+func (t *Tournament) IsPlaying(a, b string) bool {
+	if t.Playing < 0 {
+		return false
+	}
+	m := t.Matches[t.Playing]
+	return (m.P1 == a && m.P2 == b) || (m.P1 == b && m.P2 == a)
+}
+
+// Report registers the result of the current match, starting a new round
+// when all matches of the round are over.
+func (t *Tournament) Report(winner, loser string) {
+	t.Matches[t.Playing].Winner = winner
+	t.Playing = -1
+	t.Losses[loser]++
+	if t.Losses[loser] >= t.MaxLosses() {
+		t.Eliminated = append(t.Eliminated, loser)
+	}
+	if t.NextMatch() < 0 && !t.Finished() {
+		t.NewRound()
+		t.NextRound = time.Now().Add(TournamentBreak)
+	}
+}
+
+// Podium returns the champion, followed by the last players eliminated.
+func (t *Tournament) Podium() (podium []string) {
+	podium = append(podium, t.Alive()...)
+	for i := len(t.Eliminated) - 1; i >= 0 && len(podium) < len(TournamentPrizes); i-- {
+		podium = append(podium, t.Eliminated[i])
+	}
+	return podium
+}
+
+// Broadcast sends the message to all connected platforms.
+func (g *Game) Broadcast(msg string) {
+	if yt != nil {
+		g.SendMessage(message.PlatformYoutube, msg)
+	}
+	if tw != nil {
+		g.SendMessage(message.PlatformTwitch, msg)
+	}
+}
+
+// TournamentCommand handles the moderator commands:
+// !tournament open <size> [single|double], !tournament start and
+// !tournament cancel
+func (g *Game) TournamentCommand(m message.Message, v *Viewer) {
+	if !m.Moderator {
+		log.D("tournament: %v is not a moderator", v.Name)
+		return
+	}
+	reply := func(msg string, args ...any) {
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: "+msg, append([]any{v.Name}, args...)...))
+	}
+	fields := strings.Fields(m.Text)
+	args := []string{}
+	for i, f := range fields {
+		if f == "!tournament" {
+			args = fields[i+1:]
+			break
+		}
+	}
+	if len(args) == 0 {
+		reply("use !tournament open <vagas> [single|double], start ou cancel")
+		return
+	}
+
+	switch args[0] {
+	case "open":
+		if g.Tournament != nil {
+			reply("já existe um torneio em andamento")
+			return
+		}
+		size, format := 8, TournamentSingle
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 2 || n > MaxTournamentSize {
+				reply("número de vagas inválido, use entre 2 e %d", MaxTournamentSize)
+				return
+			}
+			size = n
+		}
+		if len(args) > 2 && args[2] == TournamentDouble {
+			format = TournamentDouble
+		}
+		g.Tournament = NewTournament(size, format)
+		log.I("tournament: opened for %d players (%v)", size, format)
+		g.Broadcast(fmt.Sprintf("Torneio aberto com %d vagas! Digite !join para participar", size))
+	case "start":
+		if g.Tournament == nil || g.Tournament.Started() {
+			reply("não há torneio com inscrições abertas")
+			return
+		}
+		g.StartTournament()
+	case "cancel":
+		if g.Tournament != nil {
+			g.Tournament = nil
+			g.Broadcast("O torneio foi cancelado")
+		}
+	}
+}
+
+func (g *Game) JoinTournament(v *Viewer) {
+	t := g.Tournament
+	if t == nil {
+		return
+	}
+	if err := t.Join(v.UID); err != nil {
+		g.SendMessage(v.Platform, fmt.Sprintf("@%s: %v", v.Name, err))
+		return
+	}
+	log.D("tournament: %v joined (%d/%d)", v.Name, len(t.Players), t.Size)
+	if len(t.Players) == t.Size {
+		g.StartTournament()
+	}
+}
+
+func (g *Game) StartTournament() {
+	t := g.Tournament
+	// Seed by rating
+	sort.SliceStable(t.Players, func(i, j int) bool {
+		return g.Viewers[t.Players[i]].CurrRating() > g.Viewers[t.Players[j]].CurrRating()
+	})
+	if err := t.Start(); err != nil {
+		g.Broadcast(fmt.Sprintf("Torneio cancelado: %v", err))
+		g.Tournament = nil
+		return
+	}
+	t.NextRound = time.Now().Add(TournamentBreak)
+	g.Broadcast(fmt.Sprintf("O torneio vai começar com %d jogadores!", len(t.Players)))
+}
+
+// NextTournamentFight starts the next match of the tournament.
+func (g *Game) NextTournamentFight() {
+	t := g.Tournament
+	if !t.Started() || time.Now().Before(t.NextRound) {
+		return
+	}
+	i := t.NextMatch()
+	if i < 0 {
+		return
+	}
+	m := t.Matches[i]
+	t.Playing = i
+	g.StartFight(g.Viewers[m.P1], g.Viewers[m.P2], 0)
+}
+
+func (g *Game) FinishTournament() {
+	t := g.Tournament
+	g.Tournament = nil
+	podium := t.Podium()
+	names := make([]string, 0, len(podium))
+	for i, uid := range podium {
+		v := g.Viewers[uid]
+		v.IncXP(TournamentPrizes[i])
+		names = append(names, fmt.Sprintf("%dº @%s (+%d XP)", i+1, v.Name, TournamentPrizes[i]))
+	}
+	log.I("tournament: finished: %v", names)
+	if len(podium) > 0 {
+		Emit(Event{Type: EventTournamentWon, UID: podium[0]})
+	}
+	g.Broadcast("Fim do torneio! " + strings.Join(names, ", "))
+}
+
+// DrawTournament draws the registered players or the bracket, between
+// the tournament fights.
+func (g *Game) DrawTournament(screen *ebiten.Image) {
+	t := g.Tournament
+	if t == nil || g.FightState.CurrentTurn != "" {
+		return
+	}
+	px, py := 64.0, 64.0
+	if t.Open {
+		DrawTextAt(screen, fmt.Sprintf("Torneio: %d/%d inscritos - digite !join",
+			len(t.Players), t.Size), px, py)
+		for _, uid := range t.Players {
+			py += 24
+			DrawTextAt(screen, g.Viewers[uid].Name, px, py)
+		}
+		return
+	}
+
+	format := "eliminação simples"
+	if t.Format == TournamentDouble {
+		format = "eliminação dupla"
+	}
+	DrawTextAt(screen, fmt.Sprintf("Torneio (%s) - rodada %d", format, t.Round), px, py)
+
+	// Only the latest rounds fit on screen
+	colWidth := 280.0
+	firstRound := max(1, t.Round-int(float64(Width-400)/colWidth)+1)
+	for _, m := range t.Matches {
+		if m.Round < firstRound {
+			continue
+		}
+		col := float64(m.Round - firstRound)
+		y := py + 48 + float64(countMatches(t.Matches, m))*56
+		x := px + col*colWidth
+		g.drawBracketName(screen, m, m.P1, x, y)
+		if m.Bye() {
+			DrawColoredTextAt(screen, "(bye)", x, y+24, ColorMiss)
+		} else {
+			g.drawBracketName(screen, m, m.P2, x, y+24)
+		}
+	}
+}
+
+// countMatches returns the position of the match in its round.
+func countMatches(matches []*Match, m *Match) (n int) {
+	for _, other := range matches {
+		if other == m {
+			return n
+		}
+		if other.Round == m.Round {
+			n++
+		}
+	}
+	return n
+}
+
+// bracketName cuts the name to fit in the bracket, keeping whole runes.
+func bracketName(name string) string {
+	if r := []rune(name); len(r) > 16 {
+		name = string(r[:16])
+	}
+	return name
+}
+
+func (g *Game) drawBracketName(screen *ebiten.Image, m *Match, uid string, px, py float64) {
+	name := bracketName(g.Viewers[uid].Name)
+	var clr color.Color = color.White
+	switch {
+	case m.Winner == uid:
+		clr = ColorHeal
+	case m.Winner != "":
+		clr = ColorMiss
+	}
+	DrawColoredTextAt(screen, name, px, py, clr)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestTournament(format string, players ...string) *Tournament {
+	t := NewTournament(len(players), format)
+	for _, p := range players {
+		t.Join(p)
+	}
+	t.Start()
+	return t
+}
+
+func TestTournamentJoin(t *testing.T) {
+	tt := NewTournament(2, TournamentSingle)
+	if err := tt.Join("a"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := tt.Join("a"); err == nil {
+		t.Errorf("expected error joining twice")
+	}
+	tt.Join("b")
+	if err := tt.Join("c"); err == nil {
+		t.Errorf("expected error joining a full tournament")
+	}
+	if err := NewTournament(8, TournamentSingle).Start(); err == nil {
+		t.Errorf("expected error starting without players")
+	}
+}
+
+func TestTournamentNewRound(t *testing.T) {
+	for tn, tc := range []struct {
+		players []string
+		expect  []Match
+	}{
+		{
+			players: []string{"a", "b", "c", "d"},
+			expect:  []Match{{1, "a", "d", ""}, {1, "b", "c", ""}},
+		},
+		{
+			players: []string{"a", "b", "c"},
+			expect:  []Match{{1, "a", "c", ""}, {1, "b", "", "b"}},
+		},
+		{
+			players: []string{"a", "b"},
+			expect:  []Match{{1, "a", "b", ""}},
+		},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			tt := newTestTournament(TournamentSingle, tc.players...)
+			if len(tt.Matches) != len(tc.expect) {
+				t.Fatalf("expected %d matches, got %d", len(tc.expect), len(tt.Matches))
+			}
+			for i, m := range tt.Matches {
+				if *m != tc.expect[i] {
+					t.Errorf("match %d: expected %v, got %v", i, tc.expect[i], *m)
+				}
+			}
+		})
+	}
+}
+
+// play reports the results until the tournament is over, with the
+// better seed always winning, except for the upsets given.
+func play(tt *Tournament, upsets map[string]bool) {
+	for !tt.Finished() {
+		tt.Playing = tt.NextMatch()
+		m := tt.Matches[tt.Playing]
+		if upsets[m.P2] {
+			tt.Report(m.P2, m.P1)
+		} else {
+			tt.Report(m.P1, m.P2)
+		}
+	}
+}
+
+func TestTournamentSingle(t *testing.T) {
+	tt := newTestTournament(TournamentSingle, "a", "b", "c", "d", "e")
+	play(tt, nil)
+	podium := tt.Podium()
+	if fmt.Sprint(podium) != "[a b c]" {
+		t.Errorf("unexpected podium: %v", podium)
+	}
+	if tt.Round != 3 {
+		t.Errorf("expected 3 rounds, got %d", tt.Round)
+	}
+}
+
+func TestTournamentDouble(t *testing.T) {
+	tt := newTestTournament(TournamentDouble, "a", "b", "c", "d")
+	// a loses to b and must win the losers bracket to face b again
+	play(tt, map[string]bool{"b": true})
+	if tt.Losses["b"] != 0 || tt.Losses["a"] != 2 {
+		t.Errorf("unexpected losses: %v", tt.Losses)
+	}
+	podium := tt.Podium()
+	if fmt.Sprint(podium) != "[b a c]" {
+		t.Errorf("unexpected podium: %v", podium)
+	}
+}
+
+func TestBracketName(t *testing.T) {
+	for tn, tc := range []struct {
+		name, want string
+	}{
+		{name: "gopher", want: "gopher"},
+		{name: "abcdefghijklmnopqr", want: "abcdefghijklmnop"},
+		{name: "JoãoAlvesDaSilvaSauro", want: "JoãoAlvesDaSilva"},
+		{name: "ããããããããããããããããã", want: "ãããããããããããããããã"},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if got := bracketName(tc.name); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
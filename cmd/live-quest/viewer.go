@@ -2,14 +2,17 @@ package main
 
 import (
 	"fmt"
+	"image"
 	"image/color"
+	"math"
 	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/codigolandia/live-quest/assets"
 	"github.com/codigolandia/live-quest/message"
+	"github.com/codigolandia/live-quest/physics"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/colorm"
 )
 
 type Viewer struct {
@@ -20,49 +23,151 @@ type Viewer struct {
 	HP int `json:"hp"`
 	XP int `json:"xp"`
 
-	PosX float64 `json:"posx"`
-	PosY float64 `json:"posy"`
+	// DayXP is the XP earned on the XPDay.
+	DayXP int    `json:"dayXP"`
+	XPDay string `json:"xpDay"`
 
-	VelX float64 `json:"velx"`
-	VelY float64 `json:"vely"`
+	physics.Body
 
 	Animation      string     `json:"animation"`
 	AnimationFrame int        `json:"animationFrame"`
 	SpriteColor    color.RGBA `json:"spriteColor"`
 
+	// SpriteColor2 is the color at the feet of two-tone gophers; it is
+	// transparent when the gopher has a single color.
+	SpriteColor2 color.RGBA `json:"spriteColor2"`
+
 	CompletedChallenges map[string]struct{} `json:"completedChallenges"`
 
+	// RewardedLevel is the highest level whose unlocks were granted.
+	RewardedLevel int                 `json:"rewardedLevel"`
+	Unlocks       map[string]struct{} `json:"unlocks"`
+
+	// Outfit is the cosmetic worn in each slot.
+	Outfit map[string]string `json:"outfit"`
+
+	// Character is the bundle drawn for the viewer, loaded from an asset
+	// pack; it is empty for gophers.
+	Character string `json:"character"`
+
+	Messages     int                  `json:"messages"`
+	FightsWon    int                  `json:"fightsWon"`
+	FightsLost   int                  `json:"fightsLost"`
+	Streak       int                  `json:"streak"`
+	Rating       float64              `json:"rating"`
+	LastStream   string               `json:"lastStream"`
+	StreamStreak int                  `json:"streamStreak"`
+	Achievements map[string]time.Time `json:"achievements"`
+
+	Behavior Behavior  `json:"behavior"`
+	Friend   string    `json:"friend"`
+	LastSeen time.Time `json:"lastSeen"`
+
+	celebrationFrame int
+	behaviorFrames   int
+	sleepFrame       int
+	bubble           SpeechBubble
+
+	// emote is played once, after walking to the emote target if any.
+	emote       string
+	emoteTarget string
+	emoteFrames int
+	// facingLeft mirrors animations that only exist facing right.
+	facingLeft bool
+	tint       bodyTint
+
+	// playing is the animation of the playback state below, which is
+	// restarted when the animation changes.
+	playing       string
+	frameTime     time.Duration
+	backwards     bool
+	finished      bool
+	animHandler   func(event string)
+	animHandlerOf string
+
 	mu sync.Mutex
 }
 
 func NewViewer() *Viewer {
 	v := Viewer{
+		Body:                physics.Body{Hitbox: gopherHitbox},
 		HP:                  100,
 		XP:                  0,
 		SpriteColor:         ColorGopherBlue,
+		Rating:              InitialRating,
 		CompletedChallenges: make(map[string]struct{}),
+		Unlocks:             make(map[string]struct{}),
+		Outfit:              make(map[string]string),
+		Achievements:        make(map[string]time.Time),
 	}
 	return &v
 }
 
+// XPPerLevel is the XP required to reach the first level.
 const XPPerLevel = 100
 
 func (v *Viewer) IncXP(xpDelta int) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
+	before := Levels.Curve.Level(v.XP)
 	v.XP += xpDelta
+	if today := time.Now().Format(dayFormat); v.XPDay != today {
+		v.XPDay, v.DayXP = today, 0
+	}
+	v.DayXP += xpDelta
+	// Levels lost (e.g. by XP wagers) are not celebrated again
+	if after := Levels.Curve.Level(v.XP); after > before && after > v.RewardedLevel {
+		Emit(Event{Type: EventLevelUp, UID: v.UID, Level: after})
+	}
 }
 
 func (v *Viewer) Level() int {
-	return v.XP / XPPerLevel
+	return Levels.Curve.Level(v.XP)
+}
+
+func (v *Viewer) Unlock(u Unlock) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.Unlocks[u.String()] = struct{}{}
+}
+
+// HasUnlocked returns true if the viewer was granted the unlock or has
+// already reached the level required for it.
+func (v *Viewer) HasUnlocked(u Unlock) bool {
+	v.mu.Lock()
+	_, ok := v.Unlocks[u.String()]
+	v.mu.Unlock()
+	if ok {
+		return true
+	}
+	required := Levels.RequiredLevel(u)
+	return required >= 0 && v.Level() >= required
+}
+
+// Attend updates the viewer streak of consecutive streams.
+func (v *Viewer) Attend(stream, previous string) {
+	if v.LastStream == stream {
+		return
+	}
+	if previous != "" && v.LastStream == previous {
+		v.StreamStreak++
+	} else {
+		v.StreamStreak = 1
+	}
+	v.LastStream = stream
 }
 
 func (v *Viewer) CurrAnim() *assets.Animation {
 	if v.Animation == "" {
 		v.Animation = "standing"
 	}
-	a := assets.Bundles["gopher"][v.Animation]
+	a, ok := v.Bundle()[v.Animation]
+	if !ok {
+		// Characters may lack the emotes and other optional animations
+		a = assets.Bundles[DefaultCharacter][v.Animation]
+	}
 	return a
 }
 
@@ -71,6 +176,7 @@ func (v *Viewer) CurrAnimFrames() int {
 }
 
 func (v *Viewer) Stop() {
+	v.facingLeft = false
 	v.Animation = "standing"
 	v.AnimationFrame = rand.Int() % v.CurrAnimFrames()
 }
@@ -89,8 +195,24 @@ func (v *Viewer) WalkRight() {
 	}
 }
 
+// FightStance makes the gopher face its opponent.
+func (v *Viewer) FightStance(facingLeft bool) {
+	v.Animation = "fight_standing"
+	v.AnimationFrame = 0
+	v.facingLeft = facingLeft
+}
+
 func (v *Viewer) Jump() {
-	v.VelY = -150
+	v.Body.Jump(JumpSpeed)
+}
+
+// KnockbackFrom pushes the gopher away from the horizontal position px.
+func (v *Viewer) KnockbackFrom(px float64) {
+	dir := 1.0
+	if v.PosX+float64(gopherSize)/2 < px {
+		dir = -1
+	}
+	v.Knockback(dir*KnockbackSpeed, KnockbackLift)
 }
 
 func (v *Viewer) Damage(value int) {
@@ -100,93 +222,118 @@ func (v *Viewer) Damage(value int) {
 var baseDamage = 10
 
 func (v *Viewer) Attack(other *Viewer) int {
-	if v.XP > other.XP {
+	if v.XP > other.XP && other.XP > 0 {
 		dmg := float64(baseDamage) * (float64(v.XP) / float64(other.XP))
 		return min(int(dmg), 25) - rand.Intn(6)
 	}
 	return baseDamage + rand.Intn(16)
 }
 
-func (v *Viewer) UpdateAnimation(g *Game) {
-	if g.Count%6 == 0 {
-		a := v.CurrAnim()
-		v.AnimationFrame++
-		if v.AnimationFrame >= len(a.Frames) {
-			v.AnimationFrame = 0
-		}
-	}
+// AnimationFinished is dispatched to the animation handler after one-shot
+// animations show their last frame, and after each cycle of the others.
+const AnimationFinished = "finished"
+
+// OnAnimation sets the handler of the current animation, that receives
+// its frame events and AnimationFinished. The handler is dropped when
+// another animation starts.
+func (v *Viewer) OnAnimation(handler func(event string)) {
+	v.animHandler, v.animHandlerOf = handler, v.Animation
 }
 
-func (v *Viewer) Update(g *Game) {
-	v.UpdateAnimation(g)
-	if g.Count%200 == 0 {
-		switch rand.Intn(3) {
-		case 0:
-			v.VelX = 0
-			v.Stop()
-		case 1:
-			v.VelX = 0.7
-			v.WalkRight()
-		case 2:
-			v.VelX = -0.7
-			v.WalkLeft()
+func (v *Viewer) dispatch(events ...string) {
+	for _, e := range events {
+		if v.animHandler == nil || v.animHandlerOf != v.Animation {
+			return
 		}
+		v.animHandler(e)
 	}
+}
 
-	// Moving
-	v.PosX = v.PosX + v.VelX
-	v.PosY = v.PosY + v.VelY
-	v.VelY = v.VelY + gravity
-	if v.VelY < 0 {
-		v.VelY = 0
-	}
+// tickDuration is the game time of an update.
+func tickDuration() time.Duration {
+	return time.Second / time.Duration(ebiten.TPS())
+}
 
-	// Screen border
-	screenMargin := 400.0
-	if v.PosX > float64(Width)-float64(gopherSize)-screenMargin {
-		v.PosX = float64(Width) - float64(gopherSize) - screenMargin
-		v.VelX = -2
-		v.WalkLeft()
+// UpdateAnimation plays the current animation for one tick, showing each
+// frame for its duration and dispatching the frame events.
+func (v *Viewer) UpdateAnimation(g *Game) {
+	if v.celebrationFrame > 0 {
+		v.celebrationFrame--
 	}
-	if v.PosX < 0 {
-		v.PosX = 0
-		v.VelX = 2
-		v.WalkRight()
+	a := v.CurrAnim()
+	if v.playing != v.Animation {
+		v.playing = v.Animation
+		v.frameTime, v.backwards, v.finished = 0, false, false
+		if v.animHandlerOf != v.Animation {
+			v.animHandler = nil
+		}
+		v.AnimationFrame = min(v.AnimationFrame, len(a.Frames)-1)
+		v.dispatch(a.EventsAt(v.AnimationFrame)...)
 	}
-	if v.PosY < 0 {
-		v.PosY = 0
-		v.VelY = 50
+	if v.finished {
+		return
 	}
-	floor := float64(Height - gopherSize)
-	if v.PosY > floor {
-		v.PosY = floor
-		v.VelY = 0
+	v.frameTime += tickDuration()
+	for v.frameTime >= a.FrameDuration(v.AnimationFrame) {
+		v.frameTime -= a.FrameDuration(v.AnimationFrame)
+		next, backwards, finished := a.Next(v.AnimationFrame, v.backwards)
+		v.AnimationFrame, v.backwards = next, backwards
+		if finished {
+			v.finished = a.Mode == assets.PlayOnce
+			v.dispatch(AnimationFinished)
+		}
+		// The handler may start another animation
+		if v.finished || v.playing != v.Animation {
+			return
+		}
+		v.dispatch(a.EventsAt(v.AnimationFrame)...)
 	}
 }
 
+func (v *Viewer) Update(g *Game) {
+	v.UpdateAnimation(g)
+	g.Think(v)
+}
+
+// Draw draws the gopher with its bars and name. The game draws all the
+// gophers in passes instead, see Game.DrawViewers.
 func (v *Viewer) Draw(screen *ebiten.Image) {
-	frame := v.CurrAnim().Frames[v.AnimationFrame]
-	opts := &colorm.DrawImageOptions{}
+	v.DrawSprite(screen)
+	v.DrawBars(screen)
+	v.DrawNameTag(screen)
+}
+
+// DrawSprite draws the current animation frame, with the cosmetics.
+func (v *Viewer) DrawSprite(screen *ebiten.Image) {
+	a := v.CurrAnim()
+	frame := a.Frames[v.AnimationFrame]
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(anchorOffset(a))
+	if v.facingLeft {
+		opts.GeoM.Scale(-1, 1)
+		opts.GeoM.Translate(float64(gopherSize), 0)
+	}
 	opts.GeoM.Translate(v.PosX, v.PosY)
-	colorTx := colorm.ColorM{}
-	colorTx.ScaleWithColor(v.SpriteColor)
-	colorm.DrawImage(screen, frame, colorTx, opts)
-	if v.AnimationFrame < len(v.CurrAnim().Skins) {
-		colorTx.Reset()
-		colorm.DrawImage(screen, v.CurrAnim().Skins[v.AnimationFrame], colorTx, opts)
+	v.DrawBody(screen, frame, opts)
+	v.DrawCosmetics(screen, opts, SlotOutfit)
+	if v.AnimationFrame < len(a.Skins) {
+		screen.DrawImage(a.Skins[v.AnimationFrame], opts)
 	}
+	v.DrawCosmetics(screen, opts, SlotEyes, SlotAccessory, SlotHat)
+}
 
-	// Operations
+// DrawBars draws the HP and XP bars, and the platform icon.
+func (v *Viewer) DrawBars(screen *ebiten.Image) {
 	hpBarH := float64(8)
 	xpBarH := float64(8)
 
 	// Draw HP Bar
 	barOpts := &ebiten.DrawImageOptions{}
-	barOpts.GeoM.Translate(v.PosX, v.PosY-(textScaleY*12)-hpBarH-1)
+	barOpts.GeoM.Translate(v.PosX, v.PosY-TextHeight()-hpBarH-1)
 	screen.DrawImage(assets.BarBG, barOpts)
 	barOpts.GeoM.Reset()
 	barOpts.GeoM.Scale(float64(v.HP)/100.0, 1)
-	barOpts.GeoM.Translate(v.PosX, v.PosY-(textScaleY*12)-hpBarH)
+	barOpts.GeoM.Translate(v.PosX, v.PosY-TextHeight()-hpBarH)
 	screen.DrawImage(assets.HPBarFG, barOpts)
 
 	// Draw Platform Icon
@@ -200,25 +347,96 @@ func (v *Viewer) Draw(screen *ebiten.Image) {
 
 	// Draw XP Bar
 	barOpts = &ebiten.DrawImageOptions{}
-	barOpts.GeoM.Translate(v.PosX, v.PosY-(textScaleY*12)-hpBarH-xpBarH-1)
+	barOpts.GeoM.Translate(v.PosX, v.PosY-TextHeight()-hpBarH-xpBarH-1)
 	screen.DrawImage(assets.BarBG, barOpts)
 	barOpts.GeoM.Reset()
-	// 2||........300
-	// (xpAtual - xpNivelAtual) / xpPorNivel
-	xpProgress := v.XP - (v.Level() * XPPerLevel)
-	barOpts.GeoM.Scale(float64(xpProgress)/float64(XPPerLevel), 1)
-	barOpts.GeoM.Translate(v.PosX, v.PosY-(textScaleY*12)-hpBarH-xpBarH)
+	barOpts.GeoM.Scale(Levels.Curve.Progress(v.XP), 1)
+	barOpts.GeoM.Translate(v.PosX, v.PosY-TextHeight()-hpBarH-xpBarH)
 	screen.DrawImage(assets.XPBarFG, barOpts)
+}
 
-	// Draw viewer name with shadow
+// DrawNameTag draws the name with shadow, the badges and the effects
+// around the gopher.
+func (v *Viewer) DrawNameTag(screen *ebiten.Image) {
 	nameTag := fmt.Sprintf("%s [%v]", v.Name, v.Level())
-	nameTagLen := len(nameTag) * pixelPerChar
+	nameTagLen := TextWidth(nameTag)
 
 	//  |...[..........].
 	//  |.....PX...........
 	//  |.....|^^^^^|......
-	px, py := v.PosX-(float64(nameTagLen)-float64(gopherSize))/2, int(v.PosY)-2
+	px, py := v.PosX-(nameTagLen-float64(gopherSize))/2, int(v.PosY)-2
 	DrawTextAt(screen, nameTag, float64(px), float64(py))
+	v.DrawBadges(screen, px+nameTagLen+4, float64(py))
+
+	v.DrawCelebration(screen)
+	v.DrawZzz(screen)
+}
+
+// gradientBands is the number of color bands of two-tone gophers.
+const gradientBands = 8
+
+// bodyTint caches the color scales of the body bands, so they are only
+// computed when the gopher colors change.
+type bodyTint struct {
+	colors [2]color.RGBA
+	bands  []ebiten.ColorScale
+}
+
+// BodyTint returns the color scale of each band of the body: a single
+// one, or gradientBands for two-tone gophers.
+func (v *Viewer) BodyTint() []ebiten.ColorScale {
+	colors := [2]color.RGBA{v.SpriteColor, v.SpriteColor2}
+	if v.tint.bands != nil && v.tint.colors == colors {
+		return v.tint.bands
+	}
+	v.tint = bodyTint{colors: colors}
+	if v.SpriteColor2.A == 0 {
+		var cs ebiten.ColorScale
+		cs.ScaleWithColor(v.SpriteColor)
+		v.tint.bands = []ebiten.ColorScale{cs}
+		return v.tint.bands
+	}
+	for i := 0; i < gradientBands; i++ {
+		var cs ebiten.ColorScale
+		cs.ScaleWithColor(lerpColor(v.SpriteColor, v.SpriteColor2, float64(i)/float64(gradientBands-1)))
+		v.tint.bands = append(v.tint.bands, cs)
+	}
+	return v.tint.bands
+}
+
+// DrawBody draws the frame tinted with the gopher color. Two-tone gophers
+// are drawn in horizontal bands, from the first color at the head to the
+// second at the feet. The tint is a vertex color, so gophers of any color
+// are batched in the same draw call.
+func (v *Viewer) DrawBody(screen, frame *ebiten.Image, opts *ebiten.DrawImageOptions) {
+	tint := v.BodyTint()
+	if len(tint) == 1 {
+		bodyOpts := *opts
+		bodyOpts.ColorScale = tint[0]
+		screen.DrawImage(frame, &bodyOpts)
+		return
+	}
+	b := frame.Bounds()
+	band := b.Dy() / len(tint)
+	bandOpts := &ebiten.DrawImageOptions{}
+	for i, cs := range tint {
+		bandOpts.ColorScale = cs
+		bandOpts.GeoM.Reset()
+		bandOpts.GeoM.Translate(0, float64(i*band))
+		bandOpts.GeoM.Concat(opts.GeoM)
+		r := image.Rect(b.Min.X, b.Min.Y+i*band, b.Max.X, b.Min.Y+(i+1)*band)
+		if i == len(tint)-1 {
+			r.Max.Y = b.Max.Y
+		}
+		screen.DrawImage(frame.SubImage(r).(*ebiten.Image), bandOpts)
+	}
+}
+
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 0xff}
 }
 
 func (v *Viewer) MarkCompleted(challenge string) {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/codigolandia/live-quest/assets"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestUpdateAnimation(t *testing.T) {
+	// A pingpong animation of 3 frames, with an event on the last one
+	assets.Bundles[DefaultCharacter]["test"] = &assets.Animation{
+		Frames:    make([]*ebiten.Image, 3),
+		Mode:      assets.PlayPingPong,
+		Durations: []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 200 * time.Millisecond},
+		Events:    []assets.FrameEvent{{Frame: 2, Name: "peak"}},
+	}
+	t.Cleanup(func() { delete(assets.Bundles[DefaultCharacter], "test") })
+
+	v := NewViewer()
+	v.Play("test")
+	var events []string
+	var frames []int
+	v.OnAnimation(func(event string) {
+		events = append(events, event)
+	})
+	// 700ms: 0 (100ms), 1 (50ms), 2 (200ms), 1 (50ms), 0 (100ms), 1, 2
+	for range ebiten.TPS() * 7 / 10 {
+		v.UpdateAnimation(nil)
+		if len(frames) == 0 || frames[len(frames)-1] != v.AnimationFrame {
+			frames = append(frames, v.AnimationFrame)
+		}
+	}
+	if expect := []int{0, 1, 2, 1, 0, 1, 2}; !slices.Equal(frames, expect) {
+		t.Errorf("expected the frames %v, got %v", expect, frames)
+	}
+	if expect := []string{"peak", AnimationFinished, "peak"}; !slices.Equal(events, expect) {
+		t.Errorf("expected the events %v, got %v", expect, events)
+	}
+
+	v.Play("sit")
+	if v.animHandler != nil {
+		t.Errorf("the handler should be dropped by another animation")
+	}
+	// The ticks are played after the previous cases
+	for tn, tc := range []struct {
+		ticks  int
+		frame  int
+		finish bool
+	}{
+		{ticks: 1, frame: 0},
+		{ticks: 6, frame: 1},
+		{ticks: 24, frame: 3, finish: true},
+		// Finished only once
+		{ticks: 60, frame: 3},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			finished := false
+			v.OnAnimation(func(event string) {
+				finished = finished || event == AnimationFinished
+			})
+			for range tc.ticks {
+				v.UpdateAnimation(nil)
+			}
+			if v.AnimationFrame != tc.frame || finished != tc.finish {
+				t.Errorf("expected frame %d and finished %v, got %d and %v",
+					tc.frame, tc.finish, v.AnimationFrame, finished)
+			}
+		})
+	}
+}
+
+func TestBodyTint(t *testing.T) {
+	v := NewViewer()
+	if tint := v.BodyTint(); len(tint) != 1 {
+		t.Fatalf("expected a single band, got %d", len(tint))
+	}
+	v.SpriteColor2 = color.RGBA{0xff, 0, 0, 0xff}
+	tint := v.BodyTint()
+	if len(tint) != gradientBands {
+		t.Fatalf("expected %d bands, got %d", gradientBands, len(tint))
+	}
+	if r, g := tint[0].R(), tint[0].G(); r >= g {
+		t.Errorf("expected the first band in the first color, got r=%v g=%v", r, g)
+	}
+	if last := tint[len(tint)-1]; last.R() != 1 || last.G() != 0 {
+		t.Errorf("expected the last band in the second color, got r=%v g=%v", last.R(), last.G())
+	}
+	if cached := v.BodyTint(); &cached[0] != &tint[0] {
+		t.Errorf("expected the cached bands")
+	}
+	v.SpriteColor = color.RGBA{0, 0, 0xff, 0xff}
+	if changed := v.BodyTint(); &changed[0] == &tint[0] {
+		t.Errorf("expected new bands after changing the color")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+
+	"github.com/codigolandia/live-quest/log"
+	"github.com/codigolandia/live-quest/physics"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var (
+	// Resolution is the size of the game screen, as WIDTHxHEIGHT.
+	Resolution = "1920x1080"
+
+	// BackgroundSpec is the background of the screen: a chroma-key color
+	// (green, magenta or #rrggbb), transparent or an image file. Empty is
+	// green, or transparent in overlay mode.
+	BackgroundSpec string
+
+	// Overlay opens a transparent window that stays on top of the others
+	// and lets the mouse clicks through, to be captured as is.
+	Overlay bool
+
+	// Decorated keeps the window title bar and borders in overlay mode.
+	Decorated bool
+)
+
+// Background is drawn behind the game.
+type Background struct {
+	// Color fills the screen; transparent colors leave it clear.
+	Color color.RGBA
+
+	// Image, if set, is stretched over the screen instead.
+	Image *ebiten.Image
+}
+
+// Keyed returns true if the background is removed by a chroma-key filter,
+// so the gophers must not have colors close to it.
+func (b Background) Keyed() bool {
+	return b.Image == nil && b.Color.A == 0xff
+}
+
+// ScreenBackground is the background of the game, set by SetBackground.
+var ScreenBackground = Background{Color: ColorGreen}
+
+// ParseResolution parses a resolution like 1280x720.
+func ParseResolution(s string) (w, h int, err error) {
+	if _, err := fmt.Sscanf(strings.ToLower(s), "%dx%d", &w, &h); err != nil {
+		return 0, 0, fmt.Errorf("invalid resolution %q, expected WIDTHxHEIGHT", s)
+	}
+	if w < 640 || h < 360 {
+		return 0, 0, fmt.Errorf("resolution %dx%d is too small, the minimum is 640x360", w, h)
+	}
+	return w, h, nil
+}
+
+// SetResolution changes the screen size, resizing the world to it.
+func SetResolution(w, h int) {
+	Width, Height = w, h
+	World = physics.NewWorld(float64(Width)-400, float64(Height))
+}
+
+// ParseBackground parses the background color: green, magenta,
+// transparent or any color accepted by !color. Other values are image
+// files, returned in path.
+func ParseBackground(s string) (c color.RGBA, path string, err error) {
+	switch strings.ToLower(s) {
+	case "green", "verde":
+		return ColorGreen, "", nil
+	case "magenta":
+		return ColorMagenta, "", nil
+	case "transparent", "transparente":
+		return color.RGBA{}, "", nil
+	}
+	if c, err := ParseColor(s); err == nil {
+		return c, "", nil
+	}
+	if _, err := os.Stat(s); err != nil {
+		return c, "", fmt.Errorf("invalid background %q: not a color or an image file", s)
+	}
+	return c, s, nil
+}
+
+// SetBackground sets the screen background from the spec, keeping the
+// green screen if it is invalid.
+func SetBackground(spec string) {
+	if spec == "" {
+		spec = "green"
+		if Overlay {
+			spec = "transparent"
+		}
+	}
+	c, path, err := ParseBackground(spec)
+	if err != nil {
+		log.E("window: %v", err)
+		return
+	}
+	if path == "" {
+		ScreenBackground = Background{Color: c}
+		return
+	}
+	fd, err := os.Open(path)
+	if err != nil {
+		log.E("window: unable to open the background: %v", err)
+		return
+	}
+	defer fd.Close()
+	img, _, err := image.Decode(fd)
+	if err != nil {
+		log.E("window: unable to decode the background %v: %v", path, err)
+		return
+	}
+	ScreenBackground = Background{Image: ebiten.NewImageFromImage(img)}
+}
+
+// DrawBackground fills the screen with the background.
+func DrawBackground(screen *ebiten.Image) {
+	b := ScreenBackground
+	if b.Image != nil {
+		opts := &ebiten.DrawImageOptions{}
+		size := b.Image.Bounds().Size()
+		opts.GeoM.Scale(float64(Width)/float64(size.X), float64(Height)/float64(size.Y))
+		opts.Filter = ebiten.FilterLinear
+		screen.DrawImage(b.Image, opts)
+		return
+	}
+	if b.Color.A > 0 {
+		screen.Fill(b.Color)
+	}
+}
+
+// RunWindow opens the game window, in overlay mode if enabled.
+func RunWindow(g *Game, title string) error {
+	ebiten.SetWindowSize(Width, Height)
+	ebiten.SetWindowTitle(title)
+	opts := &ebiten.RunGameOptions{}
+	if Overlay {
+		opts.ScreenTransparent = true
+		ebiten.SetWindowDecorated(Decorated)
+		ebiten.SetWindowFloating(true)
+		ebiten.SetWindowMousePassthrough(true)
+		log.I("window: overlay mode, press Esc on the focused window to close")
+	}
+	return ebiten.RunGameWithOptions(g, opts)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codigolandia/live-quest/physics"
+)
+
+func TestParseResolution(t *testing.T) {
+	for tn, tc := range []struct {
+		s    string
+		w, h int
+		err  bool
+	}{
+		{s: "1920x1080", w: 1920, h: 1080},
+		{s: "1280X720", w: 1280, h: 720},
+		{s: "2560x1440", w: 2560, h: 1440},
+		{s: "320x200", err: true},
+		{s: "1080p", err: true},
+		{s: "", err: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			w, h, err := ParseResolution(tc.s)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got %dx%d", w, h)
+				}
+				return
+			}
+			if err != nil || w != tc.w || h != tc.h {
+				t.Errorf("expected %dx%d, got %dx%d (%v)", tc.w, tc.h, w, h, err)
+			}
+		})
+	}
+}
+
+func TestParseBackground(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "bg.png")
+	if err := os.WriteFile(img, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for tn, tc := range []struct {
+		s     string
+		color color.RGBA
+		path  string
+		err   bool
+	}{
+		{s: "green", color: ColorGreen},
+		{s: "Magenta", color: ColorMagenta},
+		{s: "transparent", color: color.RGBA{}},
+		{s: "#0000ff", color: color.RGBA{0, 0, 0xff, 0xff}},
+		{s: img, path: img},
+		{s: "missing.png", err: true},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			c, path, err := ParseBackground(tc.s)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil || c != tc.color || path != tc.path {
+				t.Errorf("expected %v %q, got %v %q (%v)", tc.color, tc.path, c, path, err)
+			}
+		})
+	}
+}
+
+func TestReadableBackground(t *testing.T) {
+	defer func(b Background) { ScreenBackground = b }(ScreenBackground)
+
+	ScreenBackground = Background{Color: ColorMagenta}
+	if Readable(ColorMagenta) || !Readable(ColorGreen) {
+		t.Errorf("expected magenta to be keyed out instead of green")
+	}
+	ScreenBackground = Background{}
+	if !Readable(ColorGreen) {
+		t.Errorf("expected any color on transparent backgrounds")
+	}
+}
+
+func TestFitPlatforms(t *testing.T) {
+	w := physics.NewWorld(880, 720)
+	w.Platforms = []physics.Platform{
+		{X: 200, Y: 960, W: 300, OneWay: true},
+		{X: 1000, Y: 960, W: 300},
+		{X: 300, Y: 1200, W: 100},
+	}
+	s := 720.0 / SceneHeight
+	FitPlatforms(w, s)
+	// The last platform is below the floor
+	expect := []physics.Platform{
+		{X: 200 * s, Y: 960 * s, W: 300 * s, OneWay: true},
+		{X: 1000 * s, Y: 960 * s, W: 300 * s},
+	}
+	if !reflect.DeepEqual(w.Platforms, expect) {
+		t.Errorf("unexpected platforms:\nwant: %v\n got: %v", expect, w.Platforms)
+	}
+}
@@ -28,4 +28,8 @@ type Message struct {
 
 	// Platform is the name of the source platform.
 	Platform string `json:"platform"`
+
+	// Moderator is true when the author is the channel owner or one
+	// of the chat moderators.
+	Moderator bool `json:"moderator"`
 }
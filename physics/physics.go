@@ -0,0 +1,265 @@
+// Package physics implements the movement and collision of the gophers.
+//
+// The world is updated with a fixed timestep, so the simulation does not
+// depend on the frame rate and is deterministic. Velocities are in pixels
+// per second, and positions are the top left corner of the sprite; the
+// hitbox of the body is relative to it.
+package physics
+
+import (
+	"math"
+	"sort"
+)
+
+// Timestep is the duration of a simulation step, in seconds.
+const Timestep = 1.0 / 60
+
+// MaxSteps is the maximum number of steps in a single update, to avoid
+// freezing the game when it falls behind.
+const MaxSteps = 5
+
+// Rect is an axis aligned rectangle.
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Platform is a surface bodies can stand on. One-way platforms can be
+// crossed from below, while the others also block bodies jumping into
+// them.
+type Platform struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	W      float64 `json:"w"`
+	OneWay bool    `json:"oneWay"`
+}
+
+// Body is an object moved by the physics world.
+type Body struct {
+	PosX float64 `json:"posx"`
+	PosY float64 `json:"posy"`
+
+	// VelX is the speed the body walks at; VelY is the vertical speed.
+	VelX float64 `json:"velx"`
+	VelY float64 `json:"vely"`
+
+	// KnockX is the horizontal speed from knockbacks, that slows down
+	// with friction while the body is on the ground.
+	KnockX float64 `json:"knockx"`
+
+	// Hitbox is the solid area, relative to the position.
+	Hitbox Rect `json:"-"`
+
+	// Static bodies do not move, but other bodies collide with them.
+	Static bool `json:"-"`
+
+	// OnGround is true if the body is standing on the floor, on a
+	// platform or on top of another body.
+	OnGround bool `json:"-"`
+
+	// Wall is -1 or 1 when the body hit the left or right world limits
+	// during the last update, and 0 otherwise.
+	Wall int `json:"-"`
+
+	prevTop, prevBottom float64
+}
+
+func (b *Body) Left() float64   { return b.PosX + b.Hitbox.X }
+func (b *Body) Right() float64  { return b.Left() + b.Hitbox.W }
+func (b *Body) Top() float64    { return b.PosY + b.Hitbox.Y }
+func (b *Body) Bottom() float64 { return b.Top() + b.Hitbox.H }
+
+// SetBottom moves the body so its hitbox bottom is at y.
+func (b *Body) SetBottom(y float64) {
+	b.PosY = y - b.Hitbox.Y - b.Hitbox.H
+}
+
+// Jump makes the body jump with the given speed, if it is standing on
+// something.
+func (b *Body) Jump(speed float64) bool {
+	if !b.OnGround {
+		return false
+	}
+	b.VelY = -speed
+	b.OnGround = false
+	return true
+}
+
+// Knockback pushes the body horizontally, lifting it from the ground.
+func (b *Body) Knockback(vx, vy float64) {
+	b.KnockX += vx
+	b.VelY = -vy
+	b.OnGround = false
+}
+
+// Overlaps returns true if the hitboxes intersect.
+func (b *Body) Overlaps(other *Body) bool {
+	return b.Left() < other.Right() && b.Right() > other.Left() &&
+		b.Top() < other.Bottom() && b.Bottom() > other.Top()
+}
+
+// World holds the limits and the platforms where bodies move.
+type World struct {
+	// Gravity is the vertical acceleration, in pixels per second squared.
+	Gravity float64 `json:"gravity"`
+
+	// Friction slows down knockbacks of bodies on the ground, in pixels
+	// per second squared.
+	Friction float64 `json:"friction"`
+
+	// Left, Right, Top and Floor are the limits of the world.
+	Left  float64 `json:"left"`
+	Right float64 `json:"right"`
+	Top   float64 `json:"top"`
+	Floor float64 `json:"floor"`
+
+	Platforms []Platform `json:"platforms"`
+
+	// Separation is the fraction of the overlap between two bodies side
+	// by side that is resolved each step; lower values make crowds push
+	// each other more softly.
+	Separation float64 `json:"separation"`
+
+	accumulator float64
+}
+
+// NewWorld returns a world with default gravity and friction.
+func NewWorld(width, height float64) *World {
+	return &World{
+		Gravity:    1800,
+		Friction:   1200,
+		Right:      width,
+		Floor:      height,
+		Separation: 0.2,
+	}
+}
+
+// Update advances the simulation by dt seconds, in fixed steps. The time
+// left is carried to the next update.
+func (w *World) Update(dt float64, bodies []*Body) (steps int) {
+	w.accumulator += dt
+	for _, b := range bodies {
+		b.Wall = 0
+	}
+	for w.accumulator >= Timestep && steps < MaxSteps {
+		w.Step(bodies)
+		w.accumulator -= Timestep
+		steps++
+	}
+	if steps == MaxSteps {
+		w.accumulator = 0
+	}
+	return steps
+}
+
+// Step advances the simulation by a single timestep.
+func (w *World) Step(bodies []*Body) {
+	for _, b := range bodies {
+		if !b.Static {
+			w.move(b)
+		}
+	}
+	w.collide(bodies)
+}
+
+func (w *World) move(b *Body) {
+	dt := Timestep
+	b.prevTop, b.prevBottom = b.Top(), b.Bottom()
+
+	b.VelY += w.Gravity * dt
+	b.PosX += (b.VelX + b.KnockX) * dt
+	b.PosY += b.VelY * dt
+	if b.OnGround && b.KnockX != 0 {
+		slow := w.Friction * dt
+		if math.Abs(b.KnockX) <= slow {
+			b.KnockX = 0
+		} else {
+			b.KnockX -= math.Copysign(slow, b.KnockX)
+		}
+	}
+	b.OnGround = false
+
+	// Limits
+	if b.Left() < w.Left {
+		b.PosX = w.Left - b.Hitbox.X
+		b.KnockX = 0
+		b.Wall = -1
+	}
+	if w.Right > w.Left && b.Right() > w.Right {
+		b.PosX = w.Right - b.Hitbox.X - b.Hitbox.W
+		b.KnockX = 0
+		b.Wall = 1
+	}
+	if b.Top() < w.Top {
+		b.PosY = w.Top - b.Hitbox.Y
+		b.VelY = 0
+	}
+	if b.Bottom() >= w.Floor {
+		w.land(b, w.Floor)
+	}
+
+	// Platforms
+	for _, p := range w.Platforms {
+		if b.Right() <= p.X || b.Left() >= p.X+p.W {
+			continue
+		}
+		switch {
+		case b.VelY >= 0 && b.prevBottom <= p.Y && b.Bottom() >= p.Y:
+			// Only bodies that were above the platform land on it
+			w.land(b, p.Y)
+		case !p.OneWay && b.VelY < 0 && b.prevTop >= p.Y && b.Top() < p.Y:
+			b.PosY = p.Y - b.Hitbox.Y
+			b.VelY = 0
+		}
+	}
+}
+
+func (w *World) land(b *Body, y float64) {
+	b.SetBottom(y)
+	b.VelY = 0
+	b.OnGround = true
+}
+
+// collide stacks falling bodies on top of others and pushes apart the
+// ones side by side. Bodies are sorted by their left side, so only the
+// neighbors that overlap horizontally are checked.
+func (w *World) collide(bodies []*Body) {
+	sorted := make([]*Body, len(bodies))
+	copy(sorted, bodies)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Left() < sorted[j].Left()
+	})
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if b.Left() >= a.Right() {
+				break
+			}
+			if a.Overlaps(b) {
+				w.resolve(a, b)
+			}
+		}
+	}
+}
+
+func (w *World) resolve(a, b *Body) {
+	switch {
+	case !a.Static && a.VelY >= 0 && a.prevBottom <= b.Top():
+		w.land(a, b.Top())
+	case !b.Static && b.VelY >= 0 && b.prevBottom <= a.Top():
+		w.land(b, a.Top())
+	default:
+		// a is on the left side of b
+		push := (a.Right() - b.Left()) * w.Separation
+		switch {
+		case a.Static:
+			b.PosX += 2 * push
+		case b.Static:
+			a.PosX -= 2 * push
+		default:
+			a.PosX -= push
+			b.PosX += push
+		}
+	}
+}
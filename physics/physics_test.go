@@ -0,0 +1,183 @@
+package physics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+var testHitbox = Rect{X: 10, Y: 10, W: 20, H: 30}
+
+func newTestBody(px, bottom float64) *Body {
+	b := &Body{PosX: px, Hitbox: testHitbox}
+	b.SetBottom(bottom)
+	return b
+}
+
+func TestUpdateFixedTimestep(t *testing.T) {
+	for tn, tc := range []struct {
+		dt     []float64
+		expect []int
+	}{
+		{dt: []float64{Timestep}, expect: []int{1}},
+		{dt: []float64{Timestep / 2, Timestep / 2, Timestep / 2}, expect: []int{0, 1, 0}},
+		{dt: []float64{2.5 * Timestep, Timestep / 2}, expect: []int{2, 1}},
+		{dt: []float64{1, Timestep}, expect: []int{MaxSteps, 1}},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			w := NewWorld(1000, 500)
+			for i, dt := range tc.dt {
+				// Avoid rounding errors on the accumulator
+				if got := w.Update(dt+1e-9, nil); got != tc.expect[i] {
+					t.Errorf("update %d: expected %d steps, got %d", i, tc.expect[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestJumpArc(t *testing.T) {
+	w := NewWorld(1000, 500)
+	b := newTestBody(100, 500)
+	w.Step([]*Body{b})
+	if !b.OnGround {
+		t.Fatalf("body should be on the ground")
+	}
+	if !b.Jump(600) {
+		t.Fatalf("body should be able to jump")
+	}
+	if b.Jump(600) {
+		t.Errorf("body should not jump in the air")
+	}
+
+	peak, steps := b.Bottom(), 0
+	for !b.OnGround && steps < 1000 {
+		w.Step([]*Body{b})
+		peak = min(peak, b.Bottom())
+		steps++
+	}
+	// v²/2g, with a small error from the discrete integration
+	height := 500 - peak
+	if expected := 600.0 * 600 / (2 * w.Gravity); math.Abs(height-expected) > 5 {
+		t.Errorf("expected jump height around %.0f, got %.2f", expected, height)
+	}
+	// 2v/g seconds in the air
+	if expected := 2 * 600 / w.Gravity / Timestep; math.Abs(float64(steps)-expected) > 2 {
+		t.Errorf("expected %.0f steps in the air, got %d", expected, steps)
+	}
+	if b.Bottom() != 500 {
+		t.Errorf("expected to land on the floor, bottom at %v", b.Bottom())
+	}
+}
+
+func TestOneWayPlatform(t *testing.T) {
+	w := NewWorld(1000, 500)
+	w.Platforms = []Platform{{X: 0, Y: 300, W: 200, OneWay: true}}
+
+	// Jumping from below crosses the platform and lands on it
+	b := newTestBody(50, 500)
+	b.OnGround = true
+	b.Jump(1000)
+	crossed := false
+	for i := 0; i < 200 && !(b.OnGround && crossed); i++ {
+		w.Step([]*Body{b})
+		crossed = crossed || b.Bottom() < 300
+	}
+	if !crossed {
+		t.Fatalf("body should cross the platform from below")
+	}
+	if b.Bottom() != 300 {
+		t.Errorf("expected to land on the platform, bottom at %v", b.Bottom())
+	}
+
+	// Walking off the edge falls to the floor
+	b.VelX = 300
+	for i := 0; i < 200; i++ {
+		w.Step([]*Body{b})
+	}
+	if b.Bottom() != 500 {
+		t.Errorf("expected to fall to the floor, bottom at %v", b.Bottom())
+	}
+}
+
+func TestSolidPlatform(t *testing.T) {
+	w := NewWorld(1000, 500)
+	w.Platforms = []Platform{{X: 0, Y: 300, W: 200}}
+
+	b := newTestBody(50, 500)
+	b.OnGround = true
+	b.Jump(1000)
+	for i := 0; i < 200; i++ {
+		w.Step([]*Body{b})
+		if b.Top() < 300 {
+			t.Fatalf("body crossed a solid platform from below: %v", b.Top())
+		}
+	}
+	if b.Bottom() != 500 {
+		t.Errorf("expected to fall back to the floor, bottom at %v", b.Bottom())
+	}
+}
+
+func TestStacking(t *testing.T) {
+	w := NewWorld(1000, 500)
+	below := newTestBody(100, 500)
+	above := newTestBody(105, 300)
+	bodies := []*Body{below, above}
+	for i := 0; i < 120; i++ {
+		w.Step(bodies)
+	}
+	if above.Bottom() != below.Top() {
+		t.Errorf("expected to stack at %v, bottom at %v", below.Top(), above.Bottom())
+	}
+	if !above.OnGround || !below.OnGround {
+		t.Errorf("both bodies should be standing")
+	}
+}
+
+func TestSeparation(t *testing.T) {
+	w := NewWorld(1000, 500)
+	a := newTestBody(100, 500)
+	b := newTestBody(110, 500)
+	bodies := []*Body{b, a}
+	for i := 0; i < 120; i++ {
+		w.Step(bodies)
+	}
+	// The overlap shrinks on each step
+	if overlap := a.Right() - b.Left(); overlap > 0.01 {
+		t.Errorf("bodies still overlap: %v, %v", a.Right(), b.Left())
+	}
+	if a.PosX >= 100 || b.PosX <= 110 {
+		t.Errorf("bodies should be pushed apart: %v, %v", a.PosX, b.PosX)
+	}
+}
+
+func TestKnockback(t *testing.T) {
+	w := NewWorld(1000, 500)
+	b := newTestBody(500, 500)
+	w.Step([]*Body{b})
+	b.Knockback(300, 200)
+	for i := 0; i < 120; i++ {
+		w.Step([]*Body{b})
+	}
+	if b.KnockX != 0 {
+		t.Errorf("knockback should stop with friction, got %v", b.KnockX)
+	}
+	if b.PosX <= 500 || !b.OnGround {
+		t.Errorf("expected to be pushed right and land, got %v", b.PosX)
+	}
+}
+
+func TestWorldLimits(t *testing.T) {
+	w := NewWorld(1000, 500)
+	left := newTestBody(-10, 500)
+	left.VelX = -100
+	right := newTestBody(990, 500)
+	right.VelX = 100
+	w.Update(Timestep+1e-9, []*Body{left, right})
+	if left.Left() != 0 || left.Wall != -1 {
+		t.Errorf("unexpected left limit: %v, wall %v", left.Left(), left.Wall)
+	}
+	if right.Right() != 1000 || right.Wall != 1 {
+		t.Errorf("unexpected right limit: %v, wall %v", right.Right(), right.Wall)
+	}
+}
@@ -0,0 +1,236 @@
+package tiled
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+type jsonMap struct {
+	Orientation string         `json:"orientation"`
+	Infinite    bool           `json:"infinite"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	TileWidth   int            `json:"tilewidth"`
+	TileHeight  int            `json:"tileheight"`
+	Tilesets    []jsonTileset  `json:"tilesets"`
+	Layers      []jsonLayer    `json:"layers"`
+	Properties  jsonProperties `json:"properties"`
+}
+
+type jsonTileset struct {
+	FirstGID   int    `json:"firstgid"`
+	Source     string `json:"source"`
+	Name       string `json:"name"`
+	Image      string `json:"image"`
+	TileWidth  int    `json:"tilewidth"`
+	TileHeight int    `json:"tileheight"`
+	TileCount  int    `json:"tilecount"`
+	Columns    int    `json:"columns"`
+	Margin     int    `json:"margin"`
+	Spacing    int    `json:"spacing"`
+}
+
+type jsonProperties []struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+func (p jsonProperties) decode() Properties {
+	props := Properties{}
+	for _, prop := range p {
+		props[prop.Name] = fmt.Sprint(prop.Value)
+	}
+	return props
+}
+
+type jsonLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Visible     *bool           `json:"visible"`
+	Opacity     *float64        `json:"opacity"`
+	OffsetX     float64         `json:"offsetx"`
+	OffsetY     float64         `json:"offsety"`
+	ParallaxX   *float64        `json:"parallaxx"`
+	ParallaxY   *float64        `json:"parallaxy"`
+	RepeatX     bool            `json:"repeatx"`
+	RepeatY     bool            `json:"repeaty"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Data        json.RawMessage `json:"data"`
+	Objects     []jsonObject    `json:"objects"`
+	Image       string          `json:"image"`
+	Layers      []jsonLayer     `json:"layers"`
+	Properties  jsonProperties  `json:"properties"`
+}
+
+type jsonObject struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Class      string         `json:"class"`
+	Type       string         `json:"type"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Properties jsonProperties `json:"properties"`
+}
+
+func (l *loader) decodeJSON(b []byte) (*Map, error) {
+	var jm jsonMap
+	if err := json.Unmarshal(b, &jm); err != nil {
+		return nil, err
+	}
+	if jm.Orientation != "" && jm.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("%v maps are not supported", jm.Orientation)
+	}
+	if jm.Infinite {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+	m := &Map{
+		Width:      jm.Width,
+		Height:     jm.Height,
+		TileWidth:  jm.TileWidth,
+		TileHeight: jm.TileHeight,
+		Properties: jm.Properties.decode(),
+	}
+	for _, jt := range jm.Tilesets {
+		ts, err := l.jsonTileset(jt)
+		if err != nil {
+			return nil, err
+		}
+		m.Tilesets = append(m.Tilesets, ts)
+	}
+	if err := l.jsonLayers(m, jm.Layers, root); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// jsonTileset loads the tileset, from the JSON or TSX file if it is
+// external.
+func (l *loader) jsonTileset(jt jsonTileset) (*Tileset, error) {
+	dir := l.dir
+	if jt.Source != "" {
+		name := path.Join(dir, jt.Source)
+		if strings.ToLower(path.Ext(name)) == ".tsx" {
+			return l.xmlTileset(xmlTileset{FirstGID: jt.FirstGID, Source: jt.Source}, dir)
+		}
+		b, err := fs.ReadFile(l.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		firstGID := jt.FirstGID
+		if err := json.Unmarshal(b, &jt); err != nil {
+			return nil, fmt.Errorf("%v: %w", jt.Source, err)
+		}
+		jt.FirstGID = firstGID
+		dir = path.Dir(name)
+	}
+	ts := &Tileset{
+		FirstGID:   jt.FirstGID,
+		Name:       jt.Name,
+		TileWidth:  jt.TileWidth,
+		TileHeight: jt.TileHeight,
+		TileCount:  jt.TileCount,
+		Columns:    jt.Columns,
+		Margin:     jt.Margin,
+		Spacing:    jt.Spacing,
+	}
+	if err := l.tileset(ts, dir, jt.Image); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (l *loader) jsonLayers(m *Map, layers []jsonLayer, g group) error {
+	for _, jl := range layers {
+		layer := &Layer{
+			Name:       jl.Name,
+			Visible:    jl.Visible == nil || *jl.Visible,
+			Opacity:    1,
+			OffsetX:    jl.OffsetX,
+			OffsetY:    jl.OffsetY,
+			ParallaxX:  1,
+			ParallaxY:  1,
+			RepeatX:    jl.RepeatX,
+			RepeatY:    jl.RepeatY,
+			Properties: jl.Properties.decode(),
+		}
+		if jl.Opacity != nil {
+			layer.Opacity = *jl.Opacity
+		}
+		if jl.ParallaxX != nil {
+			layer.ParallaxX = *jl.ParallaxX
+		}
+		if jl.ParallaxY != nil {
+			layer.ParallaxY = *jl.ParallaxY
+		}
+		switch jl.Type {
+		case "group":
+			if err := l.jsonLayers(m, jl.Layers, g.child(layer)); err != nil {
+				return err
+			}
+			continue
+		case "tilelayer":
+			layer.Type = TileLayer
+			tiles, err := jl.tiles()
+			if err != nil {
+				return fmt.Errorf("layer %q: %w", jl.Name, err)
+			}
+			layer.Tiles = tiles
+		case "objectgroup":
+			layer.Type = ObjectLayer
+			for _, jo := range jl.Objects {
+				o := Object{
+					ID:         jo.ID,
+					Name:       jo.Name,
+					Class:      jo.Class,
+					X:          jo.X,
+					Y:          jo.Y,
+					W:          jo.Width,
+					H:          jo.Height,
+					Properties: jo.Properties.decode(),
+				}
+				if o.Class == "" {
+					o.Class = jo.Type
+				}
+				layer.Objects = append(layer.Objects, o)
+			}
+		case "imagelayer":
+			layer.Type = ImageLayer
+			if jl.Image == "" {
+				continue
+			}
+			img, err := l.image(l.dir, jl.Image)
+			if err != nil {
+				return fmt.Errorf("layer %q: %w", jl.Name, err)
+			}
+			layer.Image = img
+		default:
+			return fmt.Errorf("layer %q: unknown type %q", jl.Name, jl.Type)
+		}
+		g.apply(layer)
+		m.Layers = append(m.Layers, layer)
+	}
+	return nil
+}
+
+// tiles decodes the data of the tile layer: an array of GIDs, or a
+// base64 string.
+func (jl *jsonLayer) tiles() ([]uint32, error) {
+	if jl.Encoding == "base64" {
+		var data string
+		if err := json.Unmarshal(jl.Data, &data); err != nil {
+			return nil, fmt.Errorf("invalid base64 tile data: %w", err)
+		}
+		return decodeTiles(data, jl.Encoding, jl.Compression)
+	}
+	var tiles []uint32
+	if err := json.Unmarshal(jl.Data, &tiles); err != nil {
+		return nil, fmt.Errorf("invalid tile data: %w", err)
+	}
+	return tiles, nil
+}
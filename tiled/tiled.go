@@ -0,0 +1,464 @@
+// Package tiled loads maps made with the Tiled editor (mapeditor.org), in
+// the TMX or in the JSON format.
+//
+// Only orthogonal, finite maps are supported. Tile layers are rendered to
+// images, image layers are decoded, and the collision layers are turned
+// into platforms of the physics world.
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/codigolandia/live-quest/physics"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Flags of the tile GIDs, for flipped tiles.
+const (
+	FlipHorizontal uint32 = 0x80000000
+	FlipVertical   uint32 = 0x40000000
+	FlipDiagonal   uint32 = 0x20000000
+	flipMask              = FlipHorizontal | FlipVertical | FlipDiagonal
+)
+
+// Map is a map loaded from a TMX or JSON file.
+type Map struct {
+	// Width and Height are the size of the map, in tiles.
+	Width  int
+	Height int
+
+	TileWidth  int
+	TileHeight int
+
+	Tilesets []*Tileset
+
+	// Layers are in drawing order; groups are flattened, with their
+	// offsets, parallax and visibility applied to their layers.
+	Layers []*Layer
+
+	Properties Properties
+}
+
+// PixelSize returns the size of the map, in pixels.
+func (m *Map) PixelSize() (w, h int) {
+	return m.Width * m.TileWidth, m.Height * m.TileHeight
+}
+
+// Tileset is an image with the tiles in a grid.
+type Tileset struct {
+	// FirstGID is the GID of the first tile of the set in the map.
+	FirstGID int
+	Name     string
+
+	TileWidth  int
+	TileHeight int
+	TileCount  int
+	Columns    int
+	Margin     int
+	Spacing    int
+
+	Image image.Image
+}
+
+// Tile returns the area of the image with the tile of the local id.
+func (ts *Tileset) Tile(id int) image.Rectangle {
+	x := ts.Margin + id%ts.Columns*(ts.TileWidth+ts.Spacing)
+	y := ts.Margin + id/ts.Columns*(ts.TileHeight+ts.Spacing)
+	return image.Rect(x, y, x+ts.TileWidth, y+ts.TileHeight).Add(ts.Image.Bounds().Min)
+}
+
+// LayerType identifies the kind of layer.
+type LayerType int
+
+const (
+	TileLayer LayerType = iota
+	ObjectLayer
+	ImageLayer
+)
+
+func (t LayerType) String() string {
+	switch t {
+	case TileLayer:
+		return "tilelayer"
+	case ObjectLayer:
+		return "objectgroup"
+	case ImageLayer:
+		return "imagelayer"
+	}
+	return "invalid"
+}
+
+// Layer is a layer of the map.
+type Layer struct {
+	Type    LayerType
+	Name    string
+	Visible bool
+	Opacity float64
+
+	// OffsetX and OffsetY move the layer, in pixels.
+	OffsetX float64
+	OffsetY float64
+
+	// ParallaxX and ParallaxY are how much the layer moves with the
+	// camera: 1 moves with the map, lower values are far away and 0 is
+	// fixed on the screen.
+	ParallaxX float64
+	ParallaxY float64
+
+	// RepeatX and RepeatY tile image layers over the screen.
+	RepeatX bool
+	RepeatY bool
+
+	// Tiles are the GIDs of the tile layers, row by row, with the flip
+	// flags; zero is an empty cell.
+	Tiles []uint32
+
+	Objects []Object
+
+	// Image is the image of the image layers.
+	Image image.Image
+
+	Properties Properties
+}
+
+// Collision returns true if the layer is a collision layer: its name is
+// "collision" or it has the collision property set.
+func (l *Layer) Collision() bool {
+	if v, ok := l.Properties["collision"]; ok {
+		return v == "true"
+	}
+	name := strings.ToLower(l.Name)
+	return name == "collision" || name == "collisions"
+}
+
+// Object is a rectangle of an object layer.
+type Object struct {
+	ID    int
+	Name  string
+	Class string
+
+	X, Y, W, H float64
+
+	Properties Properties
+}
+
+// Properties are the custom properties of maps, layers and objects, as
+// strings: booleans are "true" or "false".
+type Properties map[string]string
+
+// Bool returns true if the property is set to true.
+func (p Properties) Bool(name string) bool {
+	return p[name] == "true"
+}
+
+// Float returns the number in the property, or def if it is not a number.
+func (p Properties) Float(name string, def float64) float64 {
+	if f, err := strconv.ParseFloat(p[name], 64); err == nil {
+		return f
+	}
+	return def
+}
+
+// Load loads the map from the TMX or JSON (.tmj or .json) file. External
+// tilesets and images are loaded relative to the file that references
+// them.
+func Load(fsys fs.FS, name string) (*Map, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	l := &loader{fsys: fsys, dir: path.Dir(name)}
+	var m *Map
+	switch strings.ToLower(path.Ext(name)) {
+	case ".tmx":
+		m, err = l.decodeTMX(b)
+	case ".tmj", ".json":
+		m, err = l.decodeJSON(b)
+	default:
+		return nil, fmt.Errorf("%v: unknown map format, expected .tmx, .tmj or .json", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	return m, nil
+}
+
+func (m *Map) validate() error {
+	if m.Width <= 0 || m.Height <= 0 || m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return fmt.Errorf("invalid map size %dx%d with tiles of %dx%d",
+			m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+	for _, l := range m.Layers {
+		if l.Type != TileLayer {
+			continue
+		}
+		if len(l.Tiles) != m.Width*m.Height {
+			return fmt.Errorf("layer %q has %d tiles, expected %d", l.Name, len(l.Tiles), m.Width*m.Height)
+		}
+		for _, gid := range l.Tiles {
+			if gid != 0 && m.tileset(gid) == nil {
+				return fmt.Errorf("layer %q has the tile %d, not in any tileset", l.Name, gid&^flipMask)
+			}
+		}
+	}
+	return nil
+}
+
+// tileset returns the tileset of the GID, or nil.
+func (m *Map) tileset(gid uint32) *Tileset {
+	id := int(gid &^ flipMask)
+	var found *Tileset
+	for _, ts := range m.Tilesets {
+		if ts.FirstGID <= id && id < ts.FirstGID+ts.TileCount && (found == nil || ts.FirstGID > found.FirstGID) {
+			found = ts
+		}
+	}
+	return found
+}
+
+// Render draws the tile layer on an image with the size of the map.
+func (m *Map) Render(l *Layer) *image.NRGBA {
+	w, h := m.PixelSize()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if l.Type != TileLayer {
+		return dst
+	}
+	for i, gid := range l.Tiles {
+		if gid == 0 {
+			continue
+		}
+		ts := m.tileset(gid)
+		src := ts.Tile(int(gid&^flipMask) - ts.FirstGID)
+		// Tiles bigger than the grid grow up from the bottom of the cell
+		at := image.Pt(i%m.Width*m.TileWidth, (i/m.Width+1)*m.TileHeight-ts.TileHeight)
+		if gid&flipMask == 0 {
+			draw.Draw(dst, image.Rectangle{at, at.Add(src.Size())}, ts.Image, src.Min, draw.Over)
+			continue
+		}
+		drawFlipped(dst, at, ts.Image, src, gid)
+	}
+	return dst
+}
+
+// drawFlipped draws the tile flipped by the flags of the GID. The diagonal
+// flip swaps the axes before the horizontal and vertical flips.
+func drawFlipped(dst draw.Image, at image.Point, img image.Image, src image.Rectangle, gid uint32) {
+	tile := image.NewNRGBA(image.Rect(0, 0, src.Dx(), src.Dy()))
+	w, h := src.Dx(), src.Dy()
+	if gid&FlipDiagonal != 0 {
+		tile = image.NewNRGBA(image.Rect(0, 0, h, w))
+		w, h = h, w
+	}
+	for y := range h {
+		for x := range w {
+			sx, sy := x, y
+			if gid&FlipHorizontal != 0 {
+				sx = w - 1 - x
+			}
+			if gid&FlipVertical != 0 {
+				sy = h - 1 - y
+			}
+			if gid&FlipDiagonal != 0 {
+				sx, sy = sy, sx
+			}
+			tile.Set(x, y, img.At(src.Min.X+sx, src.Min.Y+sy))
+		}
+	}
+	draw.Draw(dst, tile.Bounds().Add(at), tile, image.Point{}, draw.Over)
+}
+
+// Platforms returns the platforms of the collision layers. Each object is
+// a platform at its top; in tile layers, each run of tiles in a row with
+// nothing above is a platform. Platforms are one-way when the layer or
+// the object has the oneWay property set, or the object class is oneway.
+func (m *Map) Platforms() []physics.Platform {
+	var platforms []physics.Platform
+	for _, l := range m.Layers {
+		if !l.Collision() {
+			continue
+		}
+		oneWay := l.Properties.Bool("oneWay")
+		switch l.Type {
+		case ObjectLayer:
+			for _, o := range l.Objects {
+				if o.W <= 0 {
+					continue
+				}
+				platforms = append(platforms, physics.Platform{
+					X:      o.X + l.OffsetX,
+					Y:      o.Y + l.OffsetY,
+					W:      o.W,
+					OneWay: oneWay || o.Properties.Bool("oneWay") || strings.EqualFold(o.Class, "oneway"),
+				})
+			}
+		case TileLayer:
+			solid := func(x, y int) bool {
+				return y >= 0 && l.Tiles[y*m.Width+x] != 0
+			}
+			for y := range m.Height {
+				for x := 0; x < m.Width; x++ {
+					start := x
+					for x < m.Width && solid(x, y) && !solid(x, y-1) {
+						x++
+					}
+					if x == start {
+						continue
+					}
+					platforms = append(platforms, physics.Platform{
+						X:      float64(start*m.TileWidth) + l.OffsetX,
+						Y:      float64(y*m.TileHeight) + l.OffsetY,
+						W:      float64((x - start) * m.TileWidth),
+						OneWay: oneWay,
+					})
+				}
+			}
+		}
+	}
+	return platforms
+}
+
+// loader loads the files referenced by the map.
+type loader struct {
+	fsys fs.FS
+	dir  string
+}
+
+// image decodes the image at the path relative to dir.
+func (l *loader) image(dir, src string) (image.Image, error) {
+	fd, err := l.fsys.Open(path.Join(dir, src))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	img, _, err := image.Decode(fd)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", src, err)
+	}
+	return img, nil
+}
+
+// tileset completes the tileset: it loads its image and computes the
+// columns and the tile count when the file omits them.
+func (l *loader) tileset(ts *Tileset, dir, src string) error {
+	if src == "" {
+		return fmt.Errorf("tileset %q: image collections are not supported", ts.Name)
+	}
+	img, err := l.image(dir, src)
+	if err != nil {
+		return fmt.Errorf("tileset %q: %w", ts.Name, err)
+	}
+	ts.Image = img
+	if ts.TileWidth <= 0 || ts.TileHeight <= 0 {
+		return fmt.Errorf("tileset %q: invalid tile size %dx%d", ts.Name, ts.TileWidth, ts.TileHeight)
+	}
+	size := img.Bounds().Size()
+	if ts.Columns <= 0 {
+		ts.Columns = (size.X - 2*ts.Margin + ts.Spacing) / (ts.TileWidth + ts.Spacing)
+	}
+	if ts.TileCount <= 0 {
+		rows := (size.Y - 2*ts.Margin + ts.Spacing) / (ts.TileHeight + ts.Spacing)
+		ts.TileCount = ts.Columns * rows
+	}
+	if ts.Columns <= 0 {
+		return fmt.Errorf("tileset %q: image %v smaller than a tile", ts.Name, src)
+	}
+	return nil
+}
+
+// decodeTiles decodes the tile data in CSV or base64, optionally
+// compressed with zlib or gzip.
+func decodeTiles(data, encoding, compression string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var tiles []uint32
+		for _, f := range strings.FieldsFunc(data, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+		}) {
+			gid, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile %q", f)
+			}
+			tiles = append(tiles, uint32(gid))
+		}
+		return tiles, nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 tile data: %w", err)
+		}
+		var r io.Reader = bytes.NewReader(b)
+		switch compression {
+		case "":
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, fmt.Errorf("invalid zlib tile data: %w", err)
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, fmt.Errorf("invalid gzip tile data: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("%v compression is not supported", compression)
+		}
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("invalid %v tile data: %w", compression, err)
+		}
+		if len(b)%4 != 0 {
+			return nil, fmt.Errorf("tile data with %d bytes, not a multiple of 4", len(b))
+		}
+		tiles := make([]uint32, len(b)/4)
+		for i := range tiles {
+			tiles[i] = binary.LittleEndian.Uint32(b[i*4:])
+		}
+		return tiles, nil
+	}
+	return nil, fmt.Errorf("%v encoding is not supported", encoding)
+}
+
+// group holds the settings of a group layer, applied to its layers.
+type group struct {
+	visible              bool
+	opacity              float64
+	offsetX, offsetY     float64
+	parallaxX, parallaxY float64
+}
+
+var root = group{visible: true, opacity: 1, parallaxX: 1, parallaxY: 1}
+
+func (g group) apply(l *Layer) {
+	l.Visible = l.Visible && g.visible
+	l.Opacity *= g.opacity
+	l.OffsetX += g.offsetX
+	l.OffsetY += g.offsetY
+	l.ParallaxX *= g.parallaxX
+	l.ParallaxY *= g.parallaxY
+}
+
+func (g group) child(l *Layer) group {
+	return group{
+		visible:   g.visible && l.Visible,
+		opacity:   g.opacity * l.Opacity,
+		offsetX:   g.offsetX + l.OffsetX,
+		offsetY:   g.offsetY + l.OffsetY,
+		parallaxX: g.parallaxX * l.ParallaxX,
+		parallaxY: g.parallaxY * l.ParallaxY,
+	}
+}
@@ -0,0 +1,230 @@
+package tiled
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codigolandia/live-quest/physics"
+)
+
+var (
+	red   = color.NRGBA{0xff, 0, 0, 0xff}
+	green = color.NRGBA{0, 0xff, 0, 0xff}
+	blue  = color.NRGBA{0, 0, 0xff, 0xff}
+	white = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// testTiles is a tileset with 4 tiles of 8x8: red, green, blue and a white
+// one with a red pixel at the top left corner, to test the flips.
+func testTiles() *fstest.MapFile {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			img.Set(x, y, []color.NRGBA{red, green, blue, white}[y/8*2+x/8])
+		}
+	}
+	img.Set(8, 8, red)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func zlibTiles(tiles ...uint32) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	binary.Write(w, binary.LittleEndian, tiles)
+	w.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+const testTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="4" height="3" tilewidth="8" tileheight="8" infinite="0">
+ <properties>
+  <property name="theme" value="forest"/>
+ </properties>
+ <tileset firstgid="1" source="tiles.tsx"/>
+ <imagelayer id="1" name="sky" parallaxx="0.5" repeatx="1">
+  <image source="../img/sky.png" width="16" height="16"/>
+ </imagelayer>
+ <group id="2" name="stage" offsetx="2" opacity="0.5">
+  <layer id="3" name="ground" width="4" height="3">
+   <data encoding="csv">
+0,0,0,0,
+0,1,2,0,
+3,3,3,3
+</data>
+  </layer>
+ </group>
+ <layer id="4" name="collision" width="4" height="3" visible="0">
+  <data encoding="base64" compression="zlib">` + "%s" + `</data>
+ </layer>
+ <objectgroup id="5" name="ledges">
+  <properties>
+   <property name="collision" type="bool" value="true"/>
+  </properties>
+  <object id="1" x="4" y="2" width="12" height="2" type="oneway"/>
+ </objectgroup>
+</map>`
+
+const testTSX = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="tiles" tilewidth="8" tileheight="8" tilecount="4" columns="2">
+ <image source="tiles.png" width="16" height="16"/>
+</tileset>`
+
+const testJSON = `{
+	"orientation": "orthogonal", "width": 4, "height": 3, "tilewidth": 8, "tileheight": 8,
+	"properties": [{"name": "theme", "type": "string", "value": "forest"}],
+	"tilesets": [{"firstgid": 1, "source": "tiles.tsj"}],
+	"layers": [
+		{"type": "imagelayer", "name": "sky", "image": "../img/sky.png", "parallaxx": 0.5, "repeatx": true},
+		{"type": "group", "name": "stage", "offsetx": 2, "opacity": 0.5, "layers": [
+			{"type": "tilelayer", "name": "ground", "width": 4, "height": 3,
+			 "data": [0, 0, 0, 0, 0, 1, 2, 0, 3, 3, 3, 3]}
+		]},
+		{"type": "tilelayer", "name": "collision", "width": 4, "height": 3, "visible": false,
+		 "encoding": "base64", "compression": "zlib", "data": "%s"},
+		{"type": "objectgroup", "name": "ledges",
+		 "properties": [{"name": "collision", "type": "bool", "value": true}],
+		 "objects": [{"id": 1, "x": 4, "y": 2, "width": 12, "height": 2, "type": "oneway"}]}
+	]
+}`
+
+const testTSJ = `{"name": "tiles", "image": "tiles.png", "tilewidth": 8, "tileheight": 8, "tilecount": 4, "columns": 2}`
+
+func testMaps() fstest.MapFS {
+	collision := zlibTiles(0, 0, 0, 0, 0, 1, 1, 0, 1, 1, 1, 1)
+	return fstest.MapFS{
+		"maps/forest.tmx":  {Data: fmt.Appendf(nil, testTMX, collision)},
+		"maps/forest.tmj":  {Data: fmt.Appendf(nil, testJSON, collision)},
+		"maps/tiles.tsx":   {Data: []byte(testTSX)},
+		"maps/tiles.tsj":   {Data: []byte(testTSJ)},
+		"maps/tiles.png":   testTiles(),
+		"img/sky.png":      testTiles(),
+		"maps/broken.tmx":  {Data: []byte(`<map width="4" height="3" tilewidth="8" tileheight="8"><tileset firstgid="1" source="tiles.tsx"/><layer name="ground"><data encoding="csv">1,2,3</data></layer></map>`)},
+		"maps/missing.tmj": {Data: []byte(`{"width": 1, "height": 1, "tilewidth": 8, "tileheight": 8, "tilesets": [{"firstgid": 1, "source": "tiles.tsj"}], "layers": [{"type": "tilelayer", "name": "ground", "data": [9]}]}`)},
+		"maps/iso.tmx":     {Data: []byte(`<map orientation="isometric" width="1" height="1" tilewidth="8" tileheight="8"></map>`)},
+		"maps/map.txt":     {Data: []byte(`hello`)},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	for _, name := range []string{"maps/forest.tmx", "maps/forest.tmj"} {
+		t.Run(name, func(t *testing.T) {
+			m, err := Load(testMaps(), name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w, h := m.PixelSize(); w != 32 || h != 24 {
+				t.Errorf("unexpected size %dx%d", w, h)
+			}
+			if m.Properties["theme"] != "forest" {
+				t.Errorf("unexpected properties: %v", m.Properties)
+			}
+			if len(m.Tilesets) != 1 || m.Tilesets[0].Columns != 2 || m.Tilesets[0].Image == nil {
+				t.Fatalf("tileset not loaded: %+v", m.Tilesets)
+			}
+			if len(m.Layers) != 4 {
+				t.Fatalf("expected 4 layers, got %d", len(m.Layers))
+			}
+			sky, ground, collision, ledges := m.Layers[0], m.Layers[1], m.Layers[2], m.Layers[3]
+			if sky.Type != ImageLayer || sky.Image == nil || sky.ParallaxX != 0.5 || !sky.RepeatX {
+				t.Errorf("unexpected sky layer: %+v", sky)
+			}
+			if ground.Type != TileLayer || ground.OffsetX != 2 || ground.Opacity != 0.5 || !ground.Visible {
+				t.Errorf("group not applied to the ground layer: %+v", ground)
+			}
+			if collision.Visible || !collision.Collision() {
+				t.Errorf("unexpected collision layer: %+v", collision)
+			}
+			if ledges.Type != ObjectLayer || len(ledges.Objects) != 1 || ledges.Objects[0].Class != "oneway" {
+				t.Errorf("unexpected objects: %+v", ledges)
+			}
+			expect := []physics.Platform{
+				{X: 8, Y: 8, W: 16},
+				{X: 0, Y: 16, W: 8},
+				{X: 24, Y: 16, W: 8},
+				{X: 4, Y: 2, W: 12, OneWay: true},
+			}
+			if p := m.Platforms(); !reflect.DeepEqual(p, expect) {
+				t.Errorf("unexpected platforms:\nwant: %v\n got: %v", expect, p)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for tn, name := range []string{
+		"maps/broken.tmx",
+		"maps/missing.tmj",
+		"maps/iso.tmx",
+		"maps/map.txt",
+		"maps/none.tmx",
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if _, err := Load(testMaps(), name); err == nil {
+				t.Errorf("%v: expected an error", name)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	m, err := Load(testMaps(), "maps/forest.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := m.Render(m.Layers[1])
+	for tn, tc := range []struct {
+		x, y   int
+		expect color.Color
+	}{
+		{x: 0, y: 0, expect: color.NRGBA{}},
+		{x: 8, y: 8, expect: red},
+		{x: 16, y: 8, expect: green},
+		{x: 31, y: 23, expect: blue},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			if c := img.At(tc.x, tc.y); c != tc.expect {
+				t.Errorf("expected %v at %d,%d, got %v", tc.expect, tc.x, tc.y, c)
+			}
+		})
+	}
+}
+
+func TestFlippedTiles(t *testing.T) {
+	m, err := Load(testMaps(), "maps/forest.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The red pixel is at the top left corner of the white tile
+	for tn, tc := range []struct {
+		flags uint32
+		x, y  int
+	}{
+		{flags: 0, x: 0, y: 0},
+		{flags: FlipHorizontal, x: 7, y: 0},
+		{flags: FlipVertical, x: 0, y: 7},
+		{flags: FlipHorizontal | FlipVertical, x: 7, y: 7},
+		{flags: FlipDiagonal | FlipHorizontal, x: 7, y: 0},
+	} {
+		t.Run(fmt.Sprintf("#%d", tn), func(t *testing.T) {
+			l := &Layer{Type: TileLayer, Tiles: make([]uint32, m.Width*m.Height)}
+			l.Tiles[0] = 4 | tc.flags
+			img := m.Render(l)
+			if c := img.At(tc.x, tc.y); c != red {
+				t.Errorf("expected red at %d,%d, got %v", tc.x, tc.y, c)
+			}
+		})
+	}
+}
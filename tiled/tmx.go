@@ -0,0 +1,257 @@
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+type xmlMap struct {
+	Orientation string        `xml:"orientation,attr"`
+	Infinite    int           `xml:"infinite,attr"`
+	Width       int           `xml:"width,attr"`
+	Height      int           `xml:"height,attr"`
+	TileWidth   int           `xml:"tilewidth,attr"`
+	TileHeight  int           `xml:"tileheight,attr"`
+	Tilesets    []xmlTileset  `xml:"tileset"`
+	Properties  xmlProperties `xml:"properties"`
+	Layers      []xmlLayer    `xml:",any"`
+}
+
+type xmlTileset struct {
+	FirstGID   int       `xml:"firstgid,attr"`
+	Source     string    `xml:"source,attr"`
+	Name       string    `xml:"name,attr"`
+	TileWidth  int       `xml:"tilewidth,attr"`
+	TileHeight int       `xml:"tileheight,attr"`
+	TileCount  int       `xml:"tilecount,attr"`
+	Columns    int       `xml:"columns,attr"`
+	Margin     int       `xml:"margin,attr"`
+	Spacing    int       `xml:"spacing,attr"`
+	Image      *xmlImage `xml:"image"`
+}
+
+type xmlImage struct {
+	Source string `xml:"source,attr"`
+}
+
+type xmlProperties struct {
+	Properties []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+		Text  string `xml:",chardata"`
+	} `xml:"property"`
+}
+
+func (p xmlProperties) decode() Properties {
+	props := Properties{}
+	for _, prop := range p.Properties {
+		// Multiline strings are in the text of the property
+		if prop.Value == "" {
+			prop.Value = prop.Text
+		}
+		props[prop.Name] = prop.Value
+	}
+	return props
+}
+
+// xmlLayer is any kind of layer, by the element name: layer, objectgroup,
+// imagelayer or group.
+type xmlLayer struct {
+	XMLName   xml.Name
+	Name      string   `xml:"name,attr"`
+	Visible   *int     `xml:"visible,attr"`
+	Opacity   *float64 `xml:"opacity,attr"`
+	OffsetX   float64  `xml:"offsetx,attr"`
+	OffsetY   float64  `xml:"offsety,attr"`
+	ParallaxX *float64 `xml:"parallaxx,attr"`
+	ParallaxY *float64 `xml:"parallaxy,attr"`
+	RepeatX   int      `xml:"repeatx,attr"`
+	RepeatY   int      `xml:"repeaty,attr"`
+
+	Properties xmlProperties `xml:"properties"`
+	Data       *xmlData      `xml:"data"`
+	Objects    []xmlObject   `xml:"object"`
+	Image      *xmlImage     `xml:"image"`
+	Layers     []xmlLayer    `xml:",any"`
+}
+
+type xmlData struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Text        string `xml:",chardata"`
+	Tiles       []struct {
+		GID uint32 `xml:"gid,attr"`
+	} `xml:"tile"`
+	Chunks []struct{} `xml:"chunk"`
+}
+
+type xmlObject struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Class      string        `xml:"class,attr"`
+	Type       string        `xml:"type,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Properties xmlProperties `xml:"properties"`
+}
+
+func (l *loader) decodeTMX(b []byte) (*Map, error) {
+	var xm xmlMap
+	if err := xml.Unmarshal(b, &xm); err != nil {
+		return nil, err
+	}
+	if xm.Orientation != "" && xm.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("%v maps are not supported", xm.Orientation)
+	}
+	if xm.Infinite != 0 {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+	m := &Map{
+		Width:      xm.Width,
+		Height:     xm.Height,
+		TileWidth:  xm.TileWidth,
+		TileHeight: xm.TileHeight,
+		Properties: xm.Properties.decode(),
+	}
+	for _, xt := range xm.Tilesets {
+		ts, err := l.xmlTileset(xt, l.dir)
+		if err != nil {
+			return nil, err
+		}
+		m.Tilesets = append(m.Tilesets, ts)
+	}
+	if err := l.xmlLayers(m, xm.Layers, root); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// xmlTileset loads the tileset, from the TSX file if it is external.
+func (l *loader) xmlTileset(xt xmlTileset, dir string) (*Tileset, error) {
+	firstGID := xt.FirstGID
+	if xt.Source != "" {
+		name := path.Join(dir, xt.Source)
+		b, err := fs.ReadFile(l.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := xml.Unmarshal(b, &xt); err != nil {
+			return nil, fmt.Errorf("%v: %w", xt.Source, err)
+		}
+		dir = path.Dir(name)
+	}
+	ts := &Tileset{
+		FirstGID:   firstGID,
+		Name:       xt.Name,
+		TileWidth:  xt.TileWidth,
+		TileHeight: xt.TileHeight,
+		TileCount:  xt.TileCount,
+		Columns:    xt.Columns,
+		Margin:     xt.Margin,
+		Spacing:    xt.Spacing,
+	}
+	src := ""
+	if xt.Image != nil {
+		src = xt.Image.Source
+	}
+	if err := l.tileset(ts, dir, src); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (l *loader) xmlLayers(m *Map, layers []xmlLayer, g group) error {
+	for _, xl := range layers {
+		layer := &Layer{
+			Name:       xl.Name,
+			Visible:    xl.Visible == nil || *xl.Visible != 0,
+			Opacity:    1,
+			OffsetX:    xl.OffsetX,
+			OffsetY:    xl.OffsetY,
+			ParallaxX:  1,
+			ParallaxY:  1,
+			RepeatX:    xl.RepeatX != 0,
+			RepeatY:    xl.RepeatY != 0,
+			Properties: xl.Properties.decode(),
+		}
+		if xl.Opacity != nil {
+			layer.Opacity = *xl.Opacity
+		}
+		if xl.ParallaxX != nil {
+			layer.ParallaxX = *xl.ParallaxX
+		}
+		if xl.ParallaxY != nil {
+			layer.ParallaxY = *xl.ParallaxY
+		}
+		switch xl.XMLName.Local {
+		case "group":
+			if err := l.xmlLayers(m, xl.Layers, g.child(layer)); err != nil {
+				return err
+			}
+			continue
+		case "layer":
+			layer.Type = TileLayer
+			if xl.Data == nil {
+				return fmt.Errorf("layer %q without data", xl.Name)
+			}
+			tiles, err := xl.Data.decode()
+			if err != nil {
+				return fmt.Errorf("layer %q: %w", xl.Name, err)
+			}
+			layer.Tiles = tiles
+		case "objectgroup":
+			layer.Type = ObjectLayer
+			for _, xo := range xl.Objects {
+				o := Object{
+					ID:         xo.ID,
+					Name:       xo.Name,
+					Class:      xo.Class,
+					X:          xo.X,
+					Y:          xo.Y,
+					W:          xo.Width,
+					H:          xo.Height,
+					Properties: xo.Properties.decode(),
+				}
+				// Tiled before 1.9 names the class type
+				if o.Class == "" {
+					o.Class = xo.Type
+				}
+				layer.Objects = append(layer.Objects, o)
+			}
+		case "imagelayer":
+			layer.Type = ImageLayer
+			if xl.Image == nil || xl.Image.Source == "" {
+				continue
+			}
+			img, err := l.image(l.dir, xl.Image.Source)
+			if err != nil {
+				return fmt.Errorf("layer %q: %w", xl.Name, err)
+			}
+			layer.Image = img
+		default:
+			// Other elements, like editor settings
+			continue
+		}
+		g.apply(layer)
+		m.Layers = append(m.Layers, layer)
+	}
+	return nil
+}
+
+func (d *xmlData) decode() ([]uint32, error) {
+	if len(d.Chunks) > 0 {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+	if d.Encoding == "" {
+		tiles := make([]uint32, len(d.Tiles))
+		for i, t := range d.Tiles {
+			tiles[i] = t.GID
+		}
+		return tiles, nil
+	}
+	return decodeTiles(d.Text, d.Encoding, d.Compression)
+}
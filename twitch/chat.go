@@ -30,6 +30,7 @@ type Client struct {
 
 	unreadMu sync.Mutex
 	unread   []message.Message
+	lastRead time.Time
 }
 
 func New(tokenSource oauth2.TokenSource) (c *Client, err error) {
@@ -58,6 +59,8 @@ func New(tokenSource oauth2.TokenSource) (c *Client, err error) {
 	c.send("PASS oauth:" + token.AccessToken)
 	c.send("NICK " + strings.ToLower(Channel))
 	c.send("JOIN #" + Channel)
+	// Tags include the badges used to identify moderators
+	c.send("CAP REQ :twitch.tv/tags")
 	//c.SendMessage("LiveQuest on!")
 	return c, nil
 }
@@ -67,6 +70,22 @@ func parseAuthor(src string) string {
 	return strings.ReplaceAll(parts[0], ":", "")
 }
 
+// parseTags parses the IRCv3 message tags, like:
+// @badges=broadcaster/1;mod=0;display-name=Foo
+func parseTags(src string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(strings.TrimPrefix(src, "@"), ";") {
+		k, v, _ := strings.Cut(tag, "=")
+		tags[k] = v
+	}
+	return tags
+}
+
+// isModerator checks the tags for the moderator or broadcaster badges.
+func isModerator(tags map[string]string) bool {
+	return tags["mod"] == "1" || strings.Contains(tags["badges"], "broadcaster/")
+}
+
 func (c *Client) goReadTheMessages() {
 	go func() {
 		for {
@@ -77,8 +96,16 @@ func (c *Client) goReadTheMessages() {
 				log.E("error reading new message: %v", err)
 				continue
 			}
-			// :foo!foo@foo.tmi.twitch.tv PRIVMSG #bar :bleedPurple
+			c.unreadMu.Lock()
+			c.lastRead = time.Now()
+			c.unreadMu.Unlock()
+			// @mod=0 :foo!foo@foo.tmi.twitch.tv PRIVMSG #bar :bleedPurple
 			fields := strings.Fields(msg)
+			tags := map[string]string{}
+			if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+				tags = parseTags(fields[0])
+				fields = fields[1:]
+			}
 			if len(fields) == 2 && fields[0] == "PING" {
 				// PING :tmi.twitch.tv
 				log.I("sending PONG")
@@ -104,6 +131,7 @@ func (c *Client) goReadTheMessages() {
 					Text:      msgText,
 					Timestamp: time.Now(),
 					Platform:  message.PlatformTwitch,
+					Moderator: isModerator(tags) || strings.EqualFold(author, Channel),
 				})
 				c.unreadMu.Unlock()
 				log.D("new message from '%v' at %v: %v", author, ch, msgText)
@@ -137,6 +165,17 @@ func (c *Client) FetchMessages() (msg []message.Message) {
 	return msg
 }
 
+// LastRead returns when the IRC connection was last read successfully;
+// the server sends a PING every few minutes on quiet chats.
+func (c *Client) LastRead() time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	c.unreadMu.Lock()
+	defer c.unreadMu.Unlock()
+	return c.lastRead
+}
+
 func (c *Client) SendMessage(msg string) error {
 	return c.send("PRIVMSG #" + Channel + " :" + msg)
 }
@@ -31,3 +31,24 @@ func TestParseAuthor(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTags(t *testing.T) {
+	testCases := []struct {
+		input     string
+		moderator bool
+	}{
+		{"@badges=broadcaster/1,subscriber/0;mod=0;display-name=codigolandia", true},
+		{"@badges=moderator/1;mod=1;display-name=rodinei", true},
+		{"@badges=;mod=0;display-name=ronoaldo", false},
+		{"@display-name=ronoaldo", false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case#%d", i), func(t *testing.T) {
+			tags := parseTags(tc.input)
+			t.Logf("%s => %v", tc.input, tags)
+			if isModerator(tags) != tc.moderator {
+				t.Errorf("moderador inválido: expected: %v, got: %v", tc.moderator, isModerator(tags))
+			}
+		})
+	}
+}
@@ -45,6 +45,7 @@ type Client struct {
 
 	unreadMu sync.Mutex
 	unread   []message.Message
+	lastRead time.Time
 }
 
 func New(nextPageToken string, tokenSource oauth2.TokenSource) (c *Client, err error) {
@@ -213,6 +214,9 @@ func (c *Client) goReadTheMessages() {
 						continue
 					}
 					c.nextPageToken = resp.GetNextPageToken()
+					c.unreadMu.Lock()
+					c.lastRead = time.Now()
+					c.unreadMu.Unlock()
 
 					for _, item := range resp.Items {
 						timeStamp, err := time.Parse(time.RFC3339Nano, *item.Snippet.PublishedAt)
@@ -228,6 +232,8 @@ func (c *Client) goReadTheMessages() {
 							Text:      *item.Snippet.DisplayMessage,
 							Timestamp: timeStamp,
 							Platform:  message.PlatformYoutube,
+							Moderator: item.AuthorDetails.GetIsChatOwner() ||
+								item.AuthorDetails.GetIsChatModerator(),
 						})
 						c.unreadMu.Unlock()
 					}
@@ -244,6 +250,16 @@ func (c *Client) goReadTheMessages() {
 	}()
 }
 
+// LastRead returns when the chat was last read successfully.
+func (c *Client) LastRead() time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	c.unreadMu.Lock()
+	defer c.unreadMu.Unlock()
+	return c.lastRead
+}
+
 func (c *Client) NextPageToken() string {
 	if c == nil {
 		return ""